@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+func (app *application) OpenVPNListClientConfigsHandler(w http.ResponseWriter, r *http.Request) {
+	configs, err := app.openvpnCCDService.List()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": configs}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNGetClientConfigHandler(w http.ResponseWriter, r *http.Request) {
+	config, err := app.openvpnCCDService.Get(chi.URLParam(r, "name"))
+	if err != nil {
+		app.openvpnClientConfigError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": config}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNCreateClientConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenVPNClientConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	config, err := app.openvpnCCDService.Create(r.Context(), req)
+	if err != nil {
+		app.openvpnClientConfigError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envolope{"data": config}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNUpdateClientConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenVPNClientConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	config, err := app.openvpnCCDService.Update(r.Context(), chi.URLParam(r, "name"), req)
+	if err != nil {
+		app.openvpnClientConfigError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": config}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNDeleteClientConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.openvpnCCDService.Delete(chi.URLParam(r, "name")); err != nil {
+		app.openvpnClientConfigError(w, r, err)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": "client config deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// openvpnClientConfigError maps client-config-dir failures to responses
+func (app *application) openvpnClientConfigError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidClientConfig):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, services.ErrClientConfigExists), errors.Is(err, services.ErrAddressInUse):
+		app.conflictResponse(w, r, err)
+	case errors.Is(err, services.ErrClientConfigNotFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
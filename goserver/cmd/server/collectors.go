@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/LevanPro/server/internal/config"
+	"github.com/LevanPro/server/internal/services"
+)
+
+// buildCollectors registers every known bandwidth collector with its configured
+// enable flag and interval. The management collector is also returned for the
+// control API; it is nil when disabled.
+func buildCollectors(cfg config.Collectors, defaultInterval time.Duration, logger *slog.Logger) (*services.CollectorRegistry, *services.OpenVPNManagementCollector, error) {
+	registry := services.NewCollectorRegistry()
+
+	statusCollector := services.NewOpenVPNStatusCollector(cfg.OpenVPNStatus.StatusFile, logger)
+	statusEnabled, statusInterval, err := collectorSchedule("openvpn_status", cfg.OpenVPNStatus.Collector, defaultInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mgmtCfg := cfg.OpenVPNManagement
+	mgmtEnabled, mgmtInterval, err := collectorSchedule("openvpn_management", config.Collector{Enabled: mgmtCfg.Enabled, Interval: mgmtCfg.Interval}, defaultInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var management *services.OpenVPNManagementCollector
+	if mgmtEnabled {
+		bytecountInterval, err := time.ParseDuration(mgmtCfg.BytecountInterval)
+		if err != nil {
+			return nil, nil, fmt.Errorf("collector openvpn_management: invalid bytecount_interval: %w", err)
+		}
+
+		management, err = services.NewOpenVPNManagementCollector(services.OpenVPNManagementOptions{
+			Network:           mgmtCfg.Network,
+			Address:           mgmtCfg.Address,
+			Password:          mgmtCfg.Password,
+			BytecountInterval: bytecountInterval,
+			Fallback:          statusCollector,
+		}, logger)
+		if err != nil {
+			return nil, nil, fmt.Errorf("collector openvpn_management: %w", err)
+		}
+		if err := registry.Register(management, true, mgmtInterval); err != nil {
+			return nil, nil, err
+		}
+
+		// Both would report the same sessions; the status file is read through the fallback
+		statusEnabled = false
+	}
+
+	if err := registry.Register(statusCollector, statusEnabled, statusInterval); err != nil {
+		return nil, nil, err
+	}
+
+	enabled, interval, err := collectorSchedule("docker_stats", cfg.DockerStats.Collector, defaultInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+	dockerCollector, err := services.NewDockerStatsCollector(cfg.DockerStats.Container)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := registry.Register(dockerCollector, enabled, interval); err != nil {
+		return nil, nil, err
+	}
+
+	hostCfg := cfg.HostInterfaces
+	enabled, interval, err = collectorSchedule("host_interfaces", config.Collector{Enabled: hostCfg.Enabled, Interval: hostCfg.Interval}, defaultInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostCollector, err := services.NewHostInterfacesCollector(services.HostInterfacesOptions{
+		Include:         hostCfg.Include,
+		Exclude:         hostCfg.Exclude,
+		EgressInterface: hostCfg.EgressInterface,
+		ProcPath:        hostCfg.ProcPath,
+		SysPath:         hostCfg.SysPath,
+		PPPUsersDir:     hostCfg.PPPUsersDir,
+		PPPAsIPSec:      hostCfg.PPPAsIPSec,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := registry.Register(hostCollector, enabled, interval); err != nil {
+		return nil, nil, err
+	}
+
+	return registry, management, nil
+}
+
+func collectorSchedule(name string, cfg config.Collector, defaultInterval time.Duration) (bool, time.Duration, error) {
+	enabled, err := strconv.ParseBool(cfg.Enabled)
+	if err != nil {
+		return false, 0, fmt.Errorf("collector %s: invalid enabled value %q", name, cfg.Enabled)
+	}
+
+	interval := defaultInterval
+	if cfg.Interval != "" {
+		interval, err = time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return false, 0, fmt.Errorf("collector %s: invalid interval: %w", name, err)
+		}
+	}
+
+	return enabled, interval, nil
+}
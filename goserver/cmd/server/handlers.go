@@ -7,11 +7,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/services"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/go-chi/chi/v5"
 )
 
 type ExecRequest struct {
@@ -276,3 +280,173 @@ func (app *application) BandwidthResetHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 }
+
+func (app *application) BandwidthPeriodsHandler(w http.ResponseWriter, r *http.Request) {
+	periods, err := app.bandwidthService.ListPeriods()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": periods}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) BandwidthPeriodHandler(w http.ResponseWriter, r *http.Request) {
+	period, err := app.bandwidthService.GetPeriod(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, services.ErrPeriodNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": period}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) BandwidthTopTalkersHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			app.badRequestResponse(w, r, errors.New("limit must be a number between 1 and 1000"))
+			return
+		}
+		limit = parsed
+	}
+
+	var smoothed bool
+	switch r.URL.Query().Get("by") {
+	case "", "current":
+	case "smoothed":
+		smoothed = true
+	default:
+		app.badRequestResponse(w, r, errors.New("by must be either current or smoothed"))
+		return
+	}
+
+	talkers := app.bandwidthService.TopTalkers(limit, smoothed)
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": talkers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) BandwidthCollectorsHandler(w http.ResponseWriter, r *http.Request) {
+	statuses := app.bandwidthService.CollectorStatuses()
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": statuses}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) BandwidthStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverErrorResponse(w, r, errors.New("streaming is not supported by the response writer"))
+		return
+	}
+
+	snapshots, cancel := app.bandwidthService.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Send the last known snapshot right away so new viewers don't wait a full tick
+	if latest := app.bandwidthService.LatestSnapshot(); latest != nil {
+		if err := writeSSE(w, "snapshot", latest); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case snapshot, ok := <-snapshots:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, "snapshot", snapshot); err != nil {
+				app.logger.Debug("bandwidth stream subscriber gone", "error", err.Error())
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// StreamTokenHandler issues a short-lived token for opening the bandwidth
+// stream from a browser: new EventSource("/api/v1/bandwidth/stream?token=...")
+func (app *application) StreamTokenHandler(w http.ResponseWriter, r *http.Request) {
+	expires := time.Now().Add(streamTokenTTL).Truncate(time.Second)
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": map[string]any{
+		"token":      app.streamToken(expires),
+		"expires_at": expires.UTC(),
+	}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// UpdateUserAccountHandler replaces the account state of a user. Disabled and
+// expired users are rejected by the OpenVPN auth hook and the RADIUS server,
+// which also hands out the framed IP and session timeout.
+func (app *application) UpdateUserAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var account models.UserAccount
+
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	user, err := app.fileService.SetAccount(chi.URLParam(r, "username"), account)
+	if errors.Is(err, services.ErrUserNotFound) {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if errors.Is(err, services.ErrInvalidAccount) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if user.Disabled && app.selfServiceSessions != nil {
+		app.selfServiceSessions.LogoutUser(user.Username)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -24,3 +26,13 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envolo
 	w.Write(js)
 	return nil
 }
+
+func writeSSE(w io.Writer, event string, data interface{}) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, js)
+	return err
+}
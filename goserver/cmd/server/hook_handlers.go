@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Failed OpenVPN password checks tolerated per username before further
+// attempts are refused for hookAuthLockout
+const (
+	hookAuthFailureLimit = 5
+	hookAuthLockout      = 15 * time.Minute
+	hookAuthTrackedUsers = 10000
+)
+
+// hookRoutes serves the vpnhook helper on the local hook socket. Access is
+// controlled by the socket's location, not the API token.
+func (app *application) hookRoutes() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Recoverer)
+
+	r.Post("/openvpn/auth", app.OpenVPNAuthHookHandler)
+	r.Post("/openvpn/connect", app.OpenVPNConnectHookHandler)
+	r.Post("/openvpn/disconnect", app.OpenVPNDisconnectHookHandler)
+	if ppp, _ := strconv.ParseBool(app.cfg.Hooks.PPP); ppp {
+		r.Post("/ppp/up", app.PPPUpHookHandler)
+		r.Post("/ppp/down", app.PPPDownHookHandler)
+	}
+
+	return r
+}
+
+// HookTokenMiddleware guards the hooks when they are served over HTTP
+func (app *application) HookTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(app.cfg.Hooks.Token)) != 1 {
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid hook token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OpenVPNAuthHookHandler answers auth-user-pass-verify: 200 accepts the
+// client, 403 rejects it
+func (app *application) OpenVPNAuthHookHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenVPNAuthRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	if app.hookAuthFailures.Blocked(req.Username) {
+		app.logger.Warn("OpenVPN authentication throttled", "username", req.Username, "common_name", req.CommonName,
+			"untrusted_ip", req.UntrustedIP)
+		app.errorResponse(w, r, http.StatusForbidden, services.ErrTooManyAttempts.Error())
+		return
+	}
+
+	user, err := app.fileService.Authenticate(req.Username, req.Password)
+	if errors.Is(err, services.ErrInvalidCredentials) || errors.Is(err, services.ErrUserDisabled) || errors.Is(err, services.ErrUserExpired) {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			app.hookAuthFailures.Fail(req.Username)
+		}
+		app.logger.Warn("OpenVPN authentication rejected", "username", req.Username, "common_name", req.CommonName,
+			"untrusted_ip", req.UntrustedIP, "reason", err.Error())
+		app.errorResponse(w, r, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.hookAuthFailures.Reset(req.Username)
+
+	app.logger.Info("OpenVPN authentication accepted", "username", user.Username, "common_name", req.CommonName, "untrusted_ip", req.UntrustedIP)
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// OpenVPNConnectHookHandler answers client-connect: 403 rejects disabled,
+// expired and over-quota users, checked by common name and by the username
+// of password authentication
+func (app *application) OpenVPNConnectHookHandler(w http.ResponseWriter, r *http.Request) {
+	event, ok := app.readSessionEvent(w, r)
+	if !ok {
+		return
+	}
+
+	names := []string{event.CommonName}
+	if event.Username != "" && event.Username != event.CommonName {
+		names = append(names, event.Username)
+	}
+
+	for _, name := range names {
+		err := app.accessService.Check(name)
+		if errors.Is(err, services.ErrUserDisabled) || errors.Is(err, services.ErrUserExpired) || errors.Is(err, services.ErrQuotaExceeded) {
+			app.logger.Warn("OpenVPN connection rejected", "user", name, "common_name", event.CommonName,
+				"real_address", event.RealAddress, "reason", err.Error())
+			app.errorResponse(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if err := app.bandwidthService.RecordOpenVPNConnect(*event); err != nil {
+		// The session is still accepted until goserver restarts
+		app.logger.Error("Failed to record OpenVPN connect", "common_name", event.CommonName, "error", err.Error())
+	}
+	app.logger.Info("OpenVPN client connected", "common_name", event.CommonName, "username", event.Username,
+		"real_address", event.RealAddress, "virtual_address", event.VirtualAddress)
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// OpenVPNDisconnectHookHandler credits the final session totals of
+// client-disconnect to the bandwidth accumulator
+func (app *application) OpenVPNDisconnectHookHandler(w http.ResponseWriter, r *http.Request) {
+	event, ok := app.readSessionEvent(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.bandwidthService.RecordOpenVPNSession(*event)
+	if errors.Is(err, services.ErrUnknownSession) {
+		app.logger.Warn("OpenVPN disconnect of an unknown session ignored", "common_name", event.CommonName,
+			"real_address", event.RealAddress, "connected_at", event.ConnectedAt)
+		app.conflictResponse(w, r, err)
+		return
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.Info("OpenVPN client disconnected", "common_name", event.CommonName, "real_address", event.RealAddress,
+		"bytes_sent", event.BytesSent, "bytes_received", event.BytesReceived, "duration_seconds", event.Duration)
+
+	app.journalSession(models.SessionRecord{
+		Protocol:        models.ProtocolOpenVPN,
+		User:            event.CommonName,
+		RemoteAddress:   event.RealAddress,
+		VirtualAddress:  event.VirtualAddress,
+		ConnectedAt:     event.ConnectedAt,
+		DisconnectedAt:  event.ConnectedAt.Add(time.Duration(event.Duration) * time.Second),
+		DurationSeconds: event.Duration,
+		BytesSent:       event.BytesSent,
+		BytesReceived:   event.BytesReceived,
+		Source:          models.SessionSourceOpenVPNHook,
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) readSessionEvent(w http.ResponseWriter, r *http.Request) (*models.OpenVPNSessionEvent, bool) {
+	var event models.OpenVPNSessionEvent
+
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return nil, false
+	}
+	if event.CommonName == "" || event.ConnectedAt.IsZero() {
+		app.badRequestResponse(w, r, errors.New("common_name and connected_at are required"))
+		return nil, false
+	}
+
+	return &event, true
+}
+
+// PPPUpHookHandler records the start of an L2TP session from pppd ip-up
+func (app *application) PPPUpHookHandler(w http.ResponseWriter, r *http.Request) {
+	event, ok := app.readPPPEvent(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.bandwidthService.RecordPPPConnect(*event); err != nil {
+		// The session is still accepted until goserver restarts
+		app.logger.Error("Failed to record L2TP session start", "user", event.Peer, "error", err.Error())
+	}
+	app.logger.Info("L2TP session started", "user", event.Peer, "interface", event.Interface,
+		"remote_address", event.RemoteAddress, "virtual_address", event.VirtualAddress)
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// PPPDownHookHandler credits the exact totals pppd reports on ip-down to the
+// bandwidth accumulator and the session journal
+func (app *application) PPPDownHookHandler(w http.ResponseWriter, r *http.Request) {
+	event, ok := app.readPPPEvent(w, r)
+	if !ok {
+		return
+	}
+
+	disconnectedAt := time.Now().UTC().Truncate(time.Second)
+	connectedAt := disconnectedAt.Add(-time.Duration(event.ConnectTime) * time.Second)
+
+	err := app.bandwidthService.RecordPPPSession(*event, connectedAt)
+	if errors.Is(err, services.ErrUnknownSession) {
+		app.logger.Warn("L2TP session end of an unknown session ignored", "user", event.Peer, "interface", event.Interface)
+		app.conflictResponse(w, r, err)
+		return
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.Info("L2TP session ended", "user", event.Peer, "interface", event.Interface, "remote_address", event.RemoteAddress,
+		"bytes_sent", event.BytesSent, "bytes_received", event.BytesReceived, "duration_seconds", event.ConnectTime)
+
+	app.journalSession(models.SessionRecord{
+		Protocol:        models.ProtocolL2TP,
+		User:            event.Peer,
+		RemoteAddress:   event.RemoteAddress,
+		VirtualAddress:  event.VirtualAddress,
+		Interface:       event.Interface,
+		ConnectedAt:     connectedAt,
+		DisconnectedAt:  disconnectedAt,
+		DurationSeconds: event.ConnectTime,
+		BytesSent:       event.BytesSent,
+		BytesReceived:   event.BytesReceived,
+		Source:          models.SessionSourcePPPHook,
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) readPPPEvent(w http.ResponseWriter, r *http.Request) (*models.PPPSessionEvent, bool) {
+	var event models.PPPSessionEvent
+
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return nil, false
+	}
+	if event.Interface == "" || event.Peer == "" || event.ConnectTime < 0 {
+		app.badRequestResponse(w, r, errors.New("interface and peer are required"))
+		return nil, false
+	}
+
+	return &event, true
+}
+
+// journalSession appends to the session journal. The totals are already in
+// the accumulator, so a failure is logged rather than failing the hook.
+func (app *application) journalSession(record models.SessionRecord) {
+	if err := app.sessionJournal.Append(record); err != nil {
+		app.logger.Error("Failed to write session journal", "user", record.User, "protocol", record.Protocol, "error", err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// ikev2ContentTypes are the media types of the files ikev2.sh exports
+var ikev2ContentTypes = map[string]string{
+	"p12":          "application/x-pkcs12",
+	"mobileconfig": "application/x-apple-aspen-config",
+	"sswan":        "application/vnd.strongswan.profile",
+}
+
+type IKEv2AddClientRequest struct {
+	Name string `json:"name"`
+}
+
+func (app *application) IKEv2ListClientsHandler(w http.ResponseWriter, r *http.Request) {
+	clients, err := app.ikev2Service.List(r.Context())
+	if err != nil {
+		app.ikev2ClientError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": clients}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) IKEv2AddClientHandler(w http.ResponseWriter, r *http.Request) {
+	var req IKEv2AddClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	client, err := app.ikev2Service.Add(r.Context(), req.Name)
+	if err != nil {
+		app.ikev2ClientError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envolope{"data": client}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) IKEv2RevokeClientHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.ikev2Service.Revoke(r.Context(), chi.URLParam(r, "name")); err != nil {
+		app.ikev2ClientError(w, r, err)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": "client revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// IKEv2ClientFileHandler downloads a client's .p12, .mobileconfig or .sswan file
+func (app *application) IKEv2ClientFileHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	format := chi.URLParam(r, "format")
+
+	contentType, ok := ikev2ContentTypes[format]
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	data, err := app.ikev2Service.Export(r.Context(), name, format)
+	if err != nil {
+		app.ikev2ClientError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// ikev2ClientError maps IKEv2 client failures to responses
+func (app *application) ikev2ClientError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrIKEv2Unavailable):
+		app.serviceUnavailableResponse(w, r, err)
+	default:
+		app.openvpnClientError(w, r, err)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/profiles"
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateLinkRequest struct {
+	Platform string `json:"platform"` // profile platform, empty for the credentials
+	TTL      string `json:"ttl"`      // e.g. 30m, defaults to links.ttl
+}
+
+// CreateUserLinkHandler issues a single-use link to a user's credentials or
+// profile, to hand out instead of the password itself
+func (app *application) CreateUserLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateLinkRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid request body"))
+			return
+		}
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			app.badRequestResponse(w, r, errors.New("invalid ttl"))
+			return
+		}
+	}
+
+	link, err := app.issueLink(r, chi.URLParam(r, "username"), req.Platform, ttl)
+	if err != nil {
+		app.downloadLinkError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envolope{"data": link}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// DeliveryAuditHandler lists issued, delivered, expired and rejected links,
+// most recent first, optionally filtered by ?user and ?event
+func (app *application) DeliveryAuditHandler(w http.ResponseWriter, r *http.Request) {
+	filter := services.DeliveryAuditFilter{
+		User:  r.URL.Query().Get("user"),
+		Event: r.URL.Query().Get("event"),
+		Limit: 100,
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			app.badRequestResponse(w, r, errors.New("invalid limit"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	records, err := app.downloadLinks.Audit(filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": records}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// downloadPage asks for a confirmation before a link is used up, so that
+// chat and mail link previews, which only GET, do not consume it
+var downloadPage = template.Must(template.New("download").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>VPN download</title>
+</head>
+<body>
+<p>{{if eq .Kind "profile"}}Your VPN profile{{if .Platform}} for {{.Platform}}{{end}}{{else}}Your VPN credentials{{end}} can be downloaded once, until {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}.</p>
+<form method="post"><button type="submit">Download</button></form>
+</body>
+</html>
+`))
+
+// DownloadInfoHandler describes a single-use link without using it up: a
+// confirmation page for browsers, the link's kind and expiry otherwise. The
+// download itself is a POST to the same URL.
+func (app *application) DownloadInfoHandler(w http.ResponseWriter, r *http.Request) {
+	info, err := app.downloadLinks.Peek(chi.URLParam(r, "token"), r.RemoteAddr, r.UserAgent())
+	if errors.Is(err, services.ErrLinkNotFound) {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		err = app.writeJSON(w, http.StatusOK, envolope{"data": info}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if err := downloadPage.Execute(w, info); err != nil {
+		app.logger.Error("Failed to render download page", "error", err.Error())
+	}
+}
+
+// DownloadHandler delivers what a single-use link was issued for, once. It
+// is opened on the user's device, so it does not require the API token.
+func (app *application) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	payload, err := app.downloadLinks.Consume(chi.URLParam(r, "token"), r.RemoteAddr, r.UserAgent())
+	if errors.Is(err, services.ErrLinkNotFound) {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if payload.Kind == models.LinkKindCredentials {
+		err = app.writeJSON(w, http.StatusOK, envolope{"data": json.RawMessage(payload.Data)}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", payload.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", payload.Filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload.Data)
+}
+
+// issueLink captures the credentials, or the profile for platform, of a user
+// and stores them behind a new link
+func (app *application) issueLink(r *http.Request, username, platform string, ttl time.Duration) (*models.DownloadLink, error) {
+	payload := services.LinkPayload{User: username, Platform: platform}
+
+	if platform == "" {
+		credentials, err := app.userCredentials(username)
+		if err != nil {
+			return nil, err
+		}
+		payload.Kind = models.LinkKindCredentials
+		payload.ContentType = "application/json"
+		payload.Data = []byte(profiles.QRCredentials(credentials))
+	} else {
+		profile, err := app.userProfile(r.Context(), username, platform)
+		if err != nil {
+			return nil, err
+		}
+		payload.Kind = models.LinkKindProfile
+		payload.Filename = profile.Filename
+		payload.ContentType = profile.ContentType
+		payload.Data = profile.Data
+	}
+
+	token, expiresAt, err := app.downloadLinks.Create(payload, ttl, r.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DownloadLink{
+		URL:       app.publicURL(r) + "/api/v1/downloads/" + token,
+		Kind:      payload.Kind,
+		User:      username,
+		Platform:  platform,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// downloadLinkError maps link failures to responses
+func (app *application) downloadLinkError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, services.ErrInvalidLink) || errors.Is(err, profiles.ErrUnknownPlatform) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	app.userProfileError(w, r, err)
+}
+
+// publicURL is the base URL of download links, links.public_url or else the
+// scheme and host the request came in on
+func (app *application) publicURL(r *http.Request) string {
+	if app.cfg.Links.PublicURL != "" {
+		return strings.TrimSuffix(app.cfg.Links.PublicURL, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -1,23 +1,46 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"time"
+	_ "time/tzdata" // billing timezones on images without zoneinfo
 
 	"github.com/LevanPro/server/internal/config"
 	"github.com/LevanPro/server/internal/services"
 )
 
 type application struct {
-	cfg              *config.Config
-	fileService      *services.FileService
-	userService      *services.UserService
-	bandwidthService *services.BandwidthService
-	pingService      *services.PingService
-	logger           *slog.Logger
+	cfg                  *config.Config
+	fileService          *services.FileService
+	accessService        *services.AccessService
+	userService          *services.UserService
+	bandwidthService     *services.BandwidthService
+	sessionService       *services.SessionService
+	sessionJournal       *services.SessionJournal
+	openvpnClientService *services.OpenVPNClientService
+	pkiMonitor           *services.PKIMonitor
+	openvpnCCDService    *services.OpenVPNCCDService
+	openvpnServerConfig  *services.OpenVPNServerConfigService
+	ikev2Service         *services.IKEv2Service
+	downloadLinks        *services.DownloadLinkService
+	selfServiceSessions  *services.SelfServiceSessions
+	hookAuthFailures     *services.FailureLimiter
+	pingService          *services.PingService
+	logger               *slog.Logger
+
+	// nil when the management interface is not enabled
+	openvpnManagement *services.OpenVPNManagementCollector
 }
 
 func main() {
@@ -40,11 +63,30 @@ func main() {
 		os.Exit(1)
 	}
 
-	bandwidthService, err := services.NewBandwidthService(
-		bandwidthStoragePath,
-		collectionInterval,
-		logger,
+	billingCycle, err := services.NewBillingCycle(
+		cfg.BandwidthTracking.Billing.Cycle,
+		cfg.BandwidthTracking.Billing.DayOfMonth,
+		cfg.BandwidthTracking.Billing.RollingDays,
+		cfg.BandwidthTracking.Billing.Timezone,
 	)
+	if err != nil {
+		logger.Error("Invalid billing cycle configuration", "error", err.Error())
+		os.Exit(1)
+	}
+
+	collectors, openvpnManagement, err := buildCollectors(cfg.BandwidthTracking.Collectors, collectionInterval, logger)
+	if err != nil {
+		logger.Error("Invalid bandwidth collector configuration", "error", err.Error())
+		os.Exit(1)
+	}
+
+	bandwidthService, err := services.NewBandwidthService(services.BandwidthOptions{
+		StoragePath:       bandwidthStoragePath,
+		BackupGenerations: cfg.BandwidthTracking.BackupGenerations,
+		Recovery:          cfg.BandwidthTracking.Recovery,
+		Billing:           billingCycle,
+		Collectors:        collectors,
+	}, logger)
 	if err != nil {
 		logger.Error("Failed to initialize bandwidth service", "error", err.Error())
 		os.Exit(1)
@@ -57,6 +99,65 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Live sessions come from the management interface when enabled, else from the status file
+	var openvpnStatus services.OpenVPNStatusSource = services.NewOpenVPNStatusCollector(cfg.BandwidthTracking.Collectors.OpenVPNStatus.StatusFile, logger)
+	if openvpnManagement != nil {
+		openvpnStatus = openvpnManagement
+	}
+
+	sessionService, err := services.NewSessionService(openvpnStatus, openvpnManagement, cfg.BandwidthTracking.Collectors.DockerStats.Container, logger)
+	if err != nil {
+		logger.Error("Failed to initialize session service", "error", err.Error())
+		os.Exit(1)
+	}
+	defer sessionService.Close()
+
+	clientPKI, err := buildClientPKI(cfg.OpenVPN)
+	if err != nil {
+		logger.Error("Invalid OpenVPN configuration", "error", err.Error())
+		os.Exit(1)
+	}
+
+	openvpnClientService := services.NewOpenVPNClientService(services.OpenVPNClientOptions{
+		ServerDir:  cfg.OpenVPN.ServerDir,
+		ClientDays: cfg.OpenVPN.ClientDays,
+		CRLDays:    cfg.OpenVPN.CRLDays,
+		PKI:        clientPKI,
+		Management: openvpnManagement,
+	}, logger)
+
+	pkiMonitor, err := buildPKIMonitor(cfg.OpenVPN, openvpnClientService, logger)
+	if err != nil {
+		logger.Error("Invalid OpenVPN expiry check configuration", "error", err.Error())
+		os.Exit(1)
+	}
+	defer pkiMonitor.Close()
+
+	openvpnCCDService, err := buildCCDService(cfg.OpenVPN, openvpnManagement, logger)
+	if err != nil {
+		logger.Error("Invalid OpenVPN configuration", "error", err.Error())
+		os.Exit(1)
+	}
+
+	openvpnRestarter, err := buildOpenVPNRestarter(cfg.OpenVPN, openvpnManagement)
+	if err != nil {
+		logger.Error("Invalid OpenVPN configuration", "error", err.Error())
+		os.Exit(1)
+	}
+
+	openvpnServerConfig := services.NewOpenVPNServerConfigService(services.OpenVPNServerConfigOptions{
+		ServerDir: cfg.OpenVPN.ServerDir,
+		Restarter: openvpnRestarter,
+		CCD:       openvpnCCDService,
+	}, logger)
+
+	ikev2Service, err := services.NewIKEv2Service(cfg.BandwidthTracking.Collectors.DockerStats.Container, logger)
+	if err != nil {
+		logger.Error("Failed to initialize IKEv2 service", "error", err.Error())
+		os.Exit(1)
+	}
+	defer ikev2Service.Close()
+
 	pingService, err := services.NewPingService(cfg.UDPServer.Address, logger)
 	if err != nil {
 		logger.Error("Failed to initialize ping service", "error", err.Error())
@@ -70,13 +171,90 @@ func main() {
 		os.Exit(1)
 	}
 
+	sessionJournal, err := services.NewSessionJournal(filepath.Join(cfg.StoragePath, cfg.Users.SessionJournal))
+	if err != nil {
+		logger.Error("Failed to initialize session journal", "error", err.Error())
+		os.Exit(1)
+	}
+
+	downloadLinks, err := buildDownloadLinks(cfg.StoragePath, cfg.Links, logger)
+	if err != nil {
+		logger.Error("Failed to initialize download links", "error", err.Error())
+		os.Exit(1)
+	}
+
+	fileService := services.NewFileService(cfg.StoragePath, filepath.Join(cfg.StoragePath, cfg.Users.AccountsFile))
+
 	app := &application{
-		cfg:              cfg,
-		fileService:      services.NewFileService(cfg.StoragePath),
-		userService:      services.NewUserService(),
-		bandwidthService: bandwidthService,
-		pingService:      pingService,
-		logger:           logger,
+		cfg:                  cfg,
+		fileService:          fileService,
+		accessService:        services.NewAccessService(fileService, bandwidthService),
+		userService:          services.NewUserService(),
+		bandwidthService:     bandwidthService,
+		sessionService:       sessionService,
+		sessionJournal:       sessionJournal,
+		openvpnClientService: openvpnClientService,
+		pkiMonitor:           pkiMonitor,
+		openvpnCCDService:    openvpnCCDService,
+		openvpnServerConfig:  openvpnServerConfig,
+		ikev2Service:         ikev2Service,
+		downloadLinks:        downloadLinks,
+		hookAuthFailures:     services.NewFailureLimiter(hookAuthFailureLimit, hookAuthLockout, hookAuthTrackedUsers),
+		pingService:          pingService,
+		logger:               logger,
+
+		openvpnManagement: openvpnManagement,
+	}
+
+	radiusServer, err := buildRadiusServer(cfg.Radius, cfg.Hooks, app, logger)
+	if err != nil {
+		logger.Error("Failed to start RADIUS server", "error", err.Error())
+		os.Exit(1)
+	}
+	if radiusServer != nil {
+		defer radiusServer.Close()
+	}
+
+	hooksListener, hooksHTTPListener, err := buildHooksListeners(cfg.Hooks)
+	if err != nil {
+		logger.Error("Failed to open hook listener", "error", err.Error())
+		os.Exit(1)
+	}
+	if hooksListener != nil {
+		defer hooksListener.Close()
+		logger.Info("Hook socket listening", "socket", cfg.Hooks.Socket)
+
+		go func() {
+			if err := http.Serve(hooksListener, app.hookRoutes()); err != nil && !errors.Is(err, net.ErrClosed) {
+				logger.Error("Hook socket stopped", "error", err.Error())
+			}
+		}()
+	}
+	if hooksHTTPListener != nil {
+		defer hooksHTTPListener.Close()
+		logger.Info("Hook HTTP endpoint listening", "address", cfg.Hooks.Address)
+
+		go func() {
+			if err := http.Serve(hooksHTTPListener, app.HookTokenMiddleware(app.hookRoutes())); err != nil && !errors.Is(err, net.ErrClosed) {
+				logger.Error("Hook HTTP endpoint stopped", "error", err.Error())
+			}
+		}()
+	}
+
+	selfServiceListener, err := buildSelfService(cfg.SelfService, app)
+	if err != nil {
+		logger.Error("Failed to start self-service listener", "error", err.Error())
+		os.Exit(1)
+	}
+	if selfServiceListener != nil {
+		defer selfServiceListener.Close()
+		logger.Info("Self-service API listening", "address", cfg.SelfService.Address)
+
+		go func() {
+			if err := http.Serve(selfServiceListener, app.selfServiceRoutes()); err != nil && !errors.Is(err, net.ErrClosed) {
+				logger.Error("Self-service API stopped", "error", err.Error())
+			}
+		}()
 	}
 
 	err = http.ListenAndServe(app.cfg.HTTPServer.Address, app.routes())
@@ -102,3 +280,271 @@ func getLogHandler() *slog.JSONHandler {
 
 	return handler
 }
+
+// buildClientPKI selects how OpenVPN client certificates are issued; both
+// backends work on the easy-rsa pki/ directory created by openvpn.sh
+func buildClientPKI(cfg config.OpenVPN) (services.ClientPKI, error) {
+	easyRSADir := filepath.Join(cfg.ServerDir, "easy-rsa")
+
+	switch cfg.PKIBackend {
+	case "native":
+		return services.NewNativePKI(filepath.Join(easyRSADir, "pki")), nil
+	case "easyrsa":
+		return services.NewEasyRSA(easyRSADir)
+	}
+	return nil, fmt.Errorf("invalid pki_backend %q, want native or easyrsa", cfg.PKIBackend)
+}
+
+// buildPKIMonitor creates the expiry monitor and starts it when enabled. A
+// disabled monitor still answers API requests with an on-demand check.
+func buildPKIMonitor(cfg config.OpenVPN, clients *services.OpenVPNClientService, logger *slog.Logger) (*services.PKIMonitor, error) {
+	check := cfg.ExpiryCheck
+
+	enabled, err := strconv.ParseBool(check.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enabled value %q", check.Enabled)
+	}
+	regenerate, err := strconv.ParseBool(check.CRLAutoRegenerate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid crl_auto_regenerate value %q", check.CRLAutoRegenerate)
+	}
+	interval, err := time.ParseDuration(check.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval: %w", err)
+	}
+
+	monitor, err := services.NewPKIMonitor(services.PKIMonitorOptions{
+		ServerDir:     cfg.ServerDir,
+		Interval:      interval,
+		WarningDays:   check.WarningDays,
+		CriticalDays:  check.CriticalDays,
+		RegenerateCRL: regenerate,
+		Clients:       clients,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		monitor.Start()
+	}
+	return monitor, nil
+}
+
+func buildCCDService(cfg config.OpenVPN, management *services.OpenVPNManagementCollector, logger *slog.Logger) (*services.OpenVPNCCDService, error) {
+	subnet, err := netip.ParsePrefix(cfg.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet: %w", err)
+	}
+
+	return services.NewOpenVPNCCDService(services.OpenVPNCCDOptions{
+		ServerDir:  cfg.ServerDir,
+		Subnet:     subnet,
+		Management: management,
+	}, logger)
+}
+
+func buildOpenVPNRestarter(cfg config.OpenVPN, management *services.OpenVPNManagementCollector) (services.OpenVPNRestarter, error) {
+	switch cfg.RestartMethod {
+	case "management":
+		if management == nil {
+			return nil, fmt.Errorf("restart_method management needs the openvpn_management collector")
+		}
+		return &services.ManagementRestarter{Management: management}, nil
+	case "command":
+		if len(cfg.RestartCommand) == 0 {
+			return nil, fmt.Errorf("restart_command is required for restart_method command")
+		}
+		return &services.CommandRestarter{Command: cfg.RestartCommand}, nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid restart_method %q, want management, command or none", cfg.RestartMethod)
+}
+
+// buildHooksListeners opens the hook socket, replacing a socket left behind
+// by a previous run, and the optional HTTP endpoint. OpenVPN runs its scripts
+// after dropping privileges, so the socket belongs to the group OpenVPN runs
+// as and is writable by that group only.
+func buildHooksListeners(cfg config.Hooks) (net.Listener, net.Listener, error) {
+	enabled, err := strconv.ParseBool(cfg.Enabled)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid enabled value %q", cfg.Enabled)
+	}
+	if !enabled {
+		return nil, nil, nil
+	}
+	if cfg.Address != "" && cfg.Token == "" {
+		return nil, nil, fmt.Errorf("hooks token is required when address is set")
+	}
+	if _, err := strconv.ParseBool(cfg.PPP); err != nil {
+		return nil, nil, fmt.Errorf("invalid ppp value %q", cfg.PPP)
+	}
+
+	if info, err := os.Lstat(cfg.Socket); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, nil, fmt.Errorf("%s exists and is not a socket", cfg.Socket)
+		}
+		if err := os.Remove(cfg.Socket); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	socket, err := net.Listen("unix", cfg.Socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	gid, err := lookupGroup(cfg.SocketGroup)
+	if err == nil {
+		err = os.Chown(cfg.Socket, -1, gid)
+	}
+	if err == nil {
+		err = os.Chmod(cfg.Socket, 0660)
+	}
+	if err != nil {
+		socket.Close()
+		return nil, nil, fmt.Errorf("hook socket: %w", err)
+	}
+
+	if cfg.Address == "" {
+		return socket, nil, nil
+	}
+	listener, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		socket.Close()
+		return nil, nil, err
+	}
+	return socket, listener, nil
+}
+
+// buildRadiusServer starts the RADIUS server when enabled, backed by the
+// same user, bandwidth and session stores as the API
+func buildRadiusServer(cfg config.Radius, hooks config.Hooks, app *application, logger *slog.Logger) (*services.RadiusServer, error) {
+	enabled, err := strconv.ParseBool(cfg.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enabled value %q", cfg.Enabled)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	requireAuthenticator, err := strconv.ParseBool(cfg.RequireMessageAuthenticator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid require_message_authenticator value %q", cfg.RequireMessageAuthenticator)
+	}
+
+	// UserUsage adds up both, so each L2TP session would count twice
+	hooksEnabled, _ := strconv.ParseBool(hooks.Enabled)
+	pppHooks, _ := strconv.ParseBool(hooks.PPP)
+	if cfg.AcctAddress != "" && hooksEnabled && pppHooks {
+		return nil, fmt.Errorf("radius accounting and the ppp hooks both count L2TP sessions, set hooks.ppp to false or clear radius.acct_address")
+	}
+	if !requireAuthenticator {
+		logger.Warn("RADIUS Access-Requests without Message-Authenticator are accepted")
+	}
+
+	server, err := services.NewRadiusServer(services.RadiusOptions{
+		AuthAddress:                 cfg.AuthAddress,
+		AcctAddress:                 cfg.AcctAddress,
+		Secret:                      []byte(cfg.Secret),
+		RequireMessageAuthenticator: requireAuthenticator,
+		Files:                       app.fileService,
+		Access:                      app.accessService,
+		Bandwidth:                   app.bandwidthService,
+		Journal:                     app.sessionJournal,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// buildDownloadLinks opens the link store and removes the links that expired
+// while the server was down
+func buildDownloadLinks(storagePath string, cfg config.Links, logger *slog.Logger) (*services.DownloadLinkService, error) {
+	ttl, err := time.ParseDuration(cfg.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid links.ttl: %w", err)
+	}
+	maxTTL, err := time.ParseDuration(cfg.MaxTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid links.max_ttl: %w", err)
+	}
+
+	links, err := services.NewDownloadLinkService(services.DownloadLinkOptions{
+		Dir:       filepath.Join(storagePath, cfg.Dir),
+		AuditPath: filepath.Join(storagePath, cfg.AuditLog),
+		TTL:       ttl,
+		MaxTTL:    maxTTL,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+	links.Sweep()
+	return links, nil
+}
+
+// buildSelfService opens the self-service listener when enabled
+func buildSelfService(cfg config.SelfService, app *application) (net.Listener, error) {
+	enabled, err := strconv.ParseBool(cfg.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enabled value %q", cfg.Enabled)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	ttl, err := time.ParseDuration(cfg.SessionTTL)
+	if err != nil || ttl <= 0 {
+		return nil, fmt.Errorf("invalid self_service.session_ttl %q", cfg.SessionTTL)
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, errors.New("self_service.tls_cert and tls_key must be set together")
+	}
+	if cfg.TLSCert == "" && !isLoopbackAddress(cfg.Address) {
+		return nil, fmt.Errorf("self_service.address %q is not a loopback address, set tls_cert and tls_key", cfg.Address)
+	}
+	app.selfServiceSessions = services.NewSelfServiceSessions(app.fileService, ttl)
+
+	listener, err := net.Listen("tcp", cfg.Address)
+	if err != nil || cfg.TLSCert == "" {
+		return listener, err
+	}
+	certificate, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to load self_service TLS certificate: %w", err)
+	}
+	return tls.NewListener(listener, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// isLoopbackAddress reports whether a listen address only accepts local
+// connections; an empty host listens on every interface
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// lookupGroup resolves a group name or numeric ID
+func lookupGroup(name string) (int, error) {
+	if gid, err := strconv.Atoi(name); err == nil {
+		return gid, nil
+	}
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(group.Gid)
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func (app *application) AuthMiddleware(next http.Handler) http.Handler {
@@ -33,3 +38,48 @@ func (app *application) AuthMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// streamTokenTTL is how long a stream token can be used to open the bandwidth stream
+const streamTokenTTL = time.Minute
+
+// streamToken signs an expiry with the API token, so that browsers, whose
+// EventSource cannot send an Authorization header, can pass it as ?token
+func (app *application) streamToken(expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(app.cfg.AuthPassword))
+	fmt.Fprintf(mac, "bandwidth-stream:%d", expires.Unix())
+	return fmt.Sprintf("%d.%s", expires.Unix(), base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func (app *application) validStreamToken(token string) bool {
+	unix, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return false
+	}
+	expires := time.Unix(seconds, 0)
+	if !time.Now().Before(expires) || expires.Sub(time.Now()) > streamTokenTTL {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(app.streamToken(expires)))
+}
+
+// StreamAuthMiddleware accepts the API token as a bearer token or a stream
+// token from StreamTokenHandler in ?token
+func (app *application) StreamAuthMiddleware(next http.Handler) http.Handler {
+	auth := app.AuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			auth.ServeHTTP(w, r)
+			return
+		}
+		if !app.validStreamToken(token) {
+			app.errorResponse(w, r, http.StatusUnauthorized, "stream token is not valid or has expired")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
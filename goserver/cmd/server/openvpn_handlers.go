@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LevanPro/server/internal/openvpn"
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type OpenVPNKillRequest struct {
+	// CommonName disconnects every session of a certificate
+	CommonName string `json:"common_name"`
+	// RealAddress (ip:port) disconnects a single session
+	RealAddress string `json:"real_address"`
+}
+
+type OpenVPNClientKillRequest struct {
+	ClientID *int64 `json:"client_id"`
+	Message  string `json:"message"` // optional, e.g. HALT or RESTART
+}
+
+func (app *application) OpenVPNStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if app.openvpnManagement == nil {
+		app.serviceUnavailableResponse(w, r, errors.New("OpenVPN management interface is not enabled"))
+		return
+	}
+
+	status, err := app.openvpnManagement.Status(r.Context())
+	if err != nil {
+		app.openvpnManagementError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": status}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNKillHandler(w http.ResponseWriter, r *http.Request) {
+	if app.openvpnManagement == nil {
+		app.serviceUnavailableResponse(w, r, errors.New("OpenVPN management interface is not enabled"))
+		return
+	}
+
+	var req OpenVPNKillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	target := req.CommonName
+	if (req.CommonName == "") == (req.RealAddress == "") {
+		app.badRequestResponse(w, r, errors.New("exactly one of common_name or real_address is required"))
+		return
+	}
+	if req.RealAddress != "" {
+		target = req.RealAddress
+	}
+
+	if err := app.openvpnManagement.Kill(r.Context(), target); err != nil {
+		app.openvpnManagementError(w, r, err)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": "client disconnected"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNClientKillHandler(w http.ResponseWriter, r *http.Request) {
+	if app.openvpnManagement == nil {
+		app.serviceUnavailableResponse(w, r, errors.New("OpenVPN management interface is not enabled"))
+		return
+	}
+
+	var req OpenVPNClientKillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	if req.ClientID == nil || *req.ClientID < 0 {
+		app.badRequestResponse(w, r, errors.New("client_id is required"))
+		return
+	}
+
+	if err := app.openvpnManagement.ClientKill(r.Context(), *req.ClientID, req.Message); err != nil {
+		app.openvpnManagementError(w, r, err)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": "client disconnected"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// openvpnManagementError maps management interface failures to responses
+func (app *application) openvpnManagementError(w http.ResponseWriter, r *http.Request, err error) {
+	var cmdErr *openvpn.CommandError
+	switch {
+	case errors.As(err, &cmdErr):
+		app.badRequestResponse(w, r, errors.New(cmdErr.Message))
+	case errors.Is(err, services.ErrManagementUnavailable):
+		app.serviceUnavailableResponse(w, r, err)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+type OpenVPNAddClientRequest struct {
+	Name string `json:"name"`
+}
+
+func (app *application) OpenVPNListClientsHandler(w http.ResponseWriter, r *http.Request) {
+	clients, err := app.openvpnClientService.List()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": clients}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNAddClientHandler(w http.ResponseWriter, r *http.Request) {
+	var req OpenVPNAddClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	client, err := app.openvpnClientService.Add(r.Context(), req.Name)
+	if err != nil {
+		app.openvpnClientError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envolope{"data": client}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNRevokeClientHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if err := app.openvpnClientService.Revoke(r.Context(), name); err != nil {
+		app.openvpnClientError(w, r, err)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": "client revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNClientProfileHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	profile, err := app.openvpnClientService.Profile(name)
+	if err != nil {
+		app.openvpnClientError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-openvpn-profile")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".ovpn"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(profile)
+}
+
+func (app *application) OpenVPNRegenerateCRLHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.openvpnClientService.RegenerateCRL(r.Context()); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": "crl regenerated"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// openvpnClientError maps client lifecycle failures to responses
+func (app *application) openvpnClientError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidClientName):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, services.ErrClientExists):
+		app.conflictResponse(w, r, err)
+	case errors.Is(err, services.ErrClientNotFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// OpenVPNExpiryHandler returns the last expiry check; ?refresh=true runs a new one
+func (app *application) OpenVPNExpiryHandler(w http.ResponseWriter, r *http.Request) {
+	report := app.pkiExpiryReport(r)
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// OpenVPNExpiryMetricsHandler exposes the expiry check in the Prometheus text format
+func (app *application) OpenVPNExpiryMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	report := app.pkiExpiryReport(r)
+
+	var b strings.Builder
+	b.WriteString("# HELP openvpn_pki_expiry_days Whole days until the certificate or CRL expires, negative once expired.\n")
+	b.WriteString("# TYPE openvpn_pki_expiry_days gauge\n")
+	for _, item := range report.Items {
+		fmt.Fprintf(&b, "openvpn_pki_expiry_days{%s} %d\n", expiryLabels(item), item.DaysLeft)
+	}
+
+	b.WriteString("# HELP openvpn_pki_expiry_timestamp_seconds Expiry of the certificate, or next update of the CRL.\n")
+	b.WriteString("# TYPE openvpn_pki_expiry_timestamp_seconds gauge\n")
+	for _, item := range report.Items {
+		fmt.Fprintf(&b, "openvpn_pki_expiry_timestamp_seconds{%s} %d\n", expiryLabels(item), item.ExpiresAt.Unix())
+	}
+
+	b.WriteString("# HELP openvpn_pki_check_errors Artefacts the last check could not read.\n")
+	b.WriteString("# TYPE openvpn_pki_check_errors gauge\n")
+	fmt.Fprintf(&b, "openvpn_pki_check_errors %d\n", len(report.Errors))
+
+	b.WriteString("# HELP openvpn_pki_check_timestamp_seconds Time of the last check.\n")
+	b.WriteString("# TYPE openvpn_pki_check_timestamp_seconds gauge\n")
+	fmt.Fprintf(&b, "openvpn_pki_check_timestamp_seconds %d\n", report.CheckedAt.Unix())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
+
+// pkiExpiryReport returns the cached report, checking now when asked or when none exists yet
+func (app *application) pkiExpiryReport(r *http.Request) *models.PKIExpiryReport {
+	report := app.pkiMonitor.Report()
+	if report == nil || r.URL.Query().Get("refresh") == "true" {
+		report = app.pkiMonitor.Check(r.Context())
+	}
+	return report
+}
+
+func expiryLabels(item models.PKIExpiry) string {
+	return fmt.Sprintf(`kind="%s",name="%s",level="%s"`, item.Kind, labelEscaper.Replace(item.Name), item.Level)
+}
+
+// labelEscaper applies the escapes of the Prometheus text format to label values
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
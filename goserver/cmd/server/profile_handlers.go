@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LevanPro/server/internal/profiles"
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// errProfilesNotConfigured is returned while profiles.server_address is unset
+var errProfilesNotConfigured = errors.New("profiles.server_address is not configured")
+
+// errNoIKEv2Client is returned for Android users without an IKEv2 client of their name
+var errNoIKEv2Client = errors.New("android profiles use IKEv2: add an IKEv2 client named after the user first")
+
+// UserProfileHandler downloads a ready-to-import configuration for ios,
+// macos, windows or android
+func (app *application) UserProfileHandler(w http.ResponseWriter, r *http.Request) {
+	profile, err := app.userProfile(r.Context(), chi.URLParam(r, "username"), chi.URLParam(r, "platform"))
+	if err != nil {
+		app.userProfileError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", profile.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", profile.Filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(profile.Data)
+}
+
+// userProfile renders the profile of a user for a platform. Apple devices
+// get both the L2TP and the Cisco IPsec connection; Android is served the
+// user's IKEv2 strongSwan profile, as strongSwan supports neither.
+func (app *application) userProfile(ctx context.Context, username, platform string) (*profiles.Profile, error) {
+	if platform == "android" {
+		if _, err := app.fileService.Credentials(username); err != nil {
+			return nil, err
+		}
+		data, err := app.ikev2Service.Export(ctx, username, "sswan")
+		if errors.Is(err, services.ErrClientNotFound) {
+			return nil, errNoIKEv2Client
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &profiles.Profile{
+			Filename:    username + ".sswan",
+			ContentType: ikev2ContentTypes["sswan"],
+			Data:        data,
+		}, nil
+	}
+
+	switch platform {
+	case "ios", "macos":
+		platform = profiles.PlatformApple
+	case "windows":
+		platform = profiles.PlatformWindows
+	default:
+		return nil, profiles.ErrUnknownPlatform
+	}
+
+	credentials, err := app.userCredentials(username)
+	if err != nil {
+		return nil, err
+	}
+	return profiles.Render(platform, credentials)
+}
+
+// userCredentials is what profiles and QR codes of a user are filled with
+func (app *application) userCredentials(username string) (profiles.Credentials, error) {
+	user, err := app.fileService.Credentials(username)
+	if err != nil {
+		return profiles.Credentials{}, err
+	}
+	if app.cfg.Profiles.ServerAddress == "" {
+		return profiles.Credentials{}, errProfilesNotConfigured
+	}
+
+	return profiles.Credentials{
+		Name:       app.cfg.Profiles.Name,
+		Server:     app.cfg.Profiles.ServerAddress,
+		DNSServers: app.cfg.Profiles.DNSServers,
+		Username:   user.Username,
+		Password:   user.Password,
+		PSK:        user.PSKSecret,
+	}, nil
+}
+
+// userProfileError maps profile failures to responses
+func (app *application) userProfileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, profiles.ErrUnknownPlatform):
+		app.notFoundResponse(w, r)
+	case errors.Is(err, errNoIKEv2Client):
+		app.errorResponse(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, errProfilesNotConfigured):
+		app.serviceUnavailableResponse(w, r, err)
+	default:
+		app.ikev2ClientError(w, r, err)
+	}
+}
+
+// UserQRCodeHandler returns a QR code for onboarding. With content=credentials
+// (the default) it encodes the server, username, password and PSK; with
+// content=link it encodes a single-use download link to the profile of
+// ?platform, or to the credentials without one. The image is a png or svg of
+// ?size pixels.
+func (app *application) UserQRCodeHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = "png"
+	}
+	size := profiles.QRDefaultSize
+	if value := query.Get("size"); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil {
+			app.badRequestResponse(w, r, profiles.ErrInvalidQRSize)
+			return
+		}
+	}
+	// Before issuing a link, which would otherwise be live and audited for nothing
+	if err := profiles.CheckQROptions(format, size); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var content string
+	switch query.Get("content") {
+	case "", "credentials":
+		credentials, err := app.userCredentials(username)
+		if err != nil {
+			app.userProfileError(w, r, err)
+			return
+		}
+		content = profiles.QRCredentials(credentials)
+
+	case "link":
+		link, err := app.issueLink(r, username, query.Get("platform"), 0)
+		if err != nil {
+			app.downloadLinkError(w, r, err)
+			return
+		}
+		content = link.URL
+
+	default:
+		app.badRequestResponse(w, r, errors.New("content must be credentials or link"))
+		return
+	}
+
+	image, err := profiles.QRCode(content, format, size)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image.Data)
+}
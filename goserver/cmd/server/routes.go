@@ -9,10 +9,54 @@ func (app *application) routes() *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Recoverer)
-	r.Use(app.AuthMiddleware)
 
+	// Single-use links are opened on the device, without the API token
+	r.Get("/api/v1/downloads/{token}", app.DownloadInfoHandler)
+	r.Post("/api/v1/downloads/{token}", app.DownloadHandler)
+
+	// EventSource cannot send headers, so the stream also takes ?token
+	r.With(app.StreamAuthMiddleware).Get("/api/v1/bandwidth/stream", app.BandwidthStreamHandler)
+
+	r.Group(func(r chi.Router) {
+		r.Use(app.AuthMiddleware)
+		app.adminRoutes(r)
+	})
+
+	return r
+}
+
+// selfServiceRoutes are the public-facing endpoints of the self_service
+// listener; none of the admin routes are mounted here
+func (app *application) selfServiceRoutes() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(middleware.Recoverer)
+
+	r.Get("/api/v1/downloads/{token}", app.DownloadInfoHandler)
+	r.Post("/api/v1/downloads/{token}", app.DownloadHandler)
+	r.Post("/api/v1/self/login", app.SelfServiceLoginHandler)
+
+	r.Group(func(r chi.Router) {
+		r.Use(app.SelfServiceMiddleware)
+
+		r.Post("/api/v1/self/logout", app.SelfServiceLogoutHandler)
+		r.Get("/api/v1/self/account", app.SelfServiceAccountHandler)
+		r.Get("/api/v1/self/sessions", app.SelfServiceSessionsHandler)
+		r.Put("/api/v1/self/password", app.SelfServicePasswordHandler)
+		r.Get("/api/v1/self/profiles/{platform}", app.SelfServiceProfileHandler)
+	})
+
+	return r
+}
+
+func (app *application) adminRoutes(r chi.Router) {
 	r.Get("/api/v1/users", app.ListUsersHandler)
 	r.Post("/api/v1/users", app.AddUserHandler)
+	r.Put("/api/v1/users/{username}/account", app.UpdateUserAccountHandler)
+	r.Get("/api/v1/users/{username}/profiles/{platform}", app.UserProfileHandler)
+	r.Get("/api/v1/users/{username}/qrcode", app.UserQRCodeHandler)
+	r.Post("/api/v1/users/{username}/links", app.CreateUserLinkHandler)
+	r.Get("/api/v1/links/audit", app.DeliveryAuditHandler)
 	r.Post("/api/v1/restart/container", app.RestartIPSecContainer)
 	r.Post("/api/v1/restart/service", app.RestartIPSecService)
 	r.Post("/api/v1/exec", app.ExecCommandInContainer)
@@ -20,6 +64,34 @@ func (app *application) routes() *chi.Mux {
 	r.Get("/api/v1/bandwidth/metrics", app.BandwidthMetricsHandler)
 	r.Get("/api/v1/bandwidth/accumulated", app.BandwidthAccumulatedHandler)
 	r.Post("/api/v1/bandwidth/reset", app.BandwidthResetHandler)
-
-	return r
+	r.Post("/api/v1/bandwidth/stream/token", app.StreamTokenHandler)
+	r.Get("/api/v1/bandwidth/periods", app.BandwidthPeriodsHandler)
+	r.Get("/api/v1/bandwidth/periods/{id}", app.BandwidthPeriodHandler)
+	r.Get("/api/v1/bandwidth/top", app.BandwidthTopTalkersHandler)
+	r.Get("/api/v1/bandwidth/collectors", app.BandwidthCollectorsHandler)
+	r.Get("/api/v1/sessions/active", app.ActiveSessionsHandler)
+	r.Get("/api/v1/sessions/history", app.SessionHistoryHandler)
+	r.Delete("/api/v1/sessions/{id}", app.DisconnectSessionHandler)
+	r.Get("/api/v1/openvpn/status", app.OpenVPNStatusHandler)
+	r.Post("/api/v1/openvpn/kill", app.OpenVPNKillHandler)
+	r.Post("/api/v1/openvpn/client-kill", app.OpenVPNClientKillHandler)
+	r.Get("/api/v1/openvpn/clients", app.OpenVPNListClientsHandler)
+	r.Post("/api/v1/openvpn/clients", app.OpenVPNAddClientHandler)
+	r.Delete("/api/v1/openvpn/clients/{name}", app.OpenVPNRevokeClientHandler)
+	r.Get("/api/v1/openvpn/clients/{name}/ovpn", app.OpenVPNClientProfileHandler)
+	r.Post("/api/v1/openvpn/crl", app.OpenVPNRegenerateCRLHandler)
+	r.Get("/api/v1/openvpn/ccd", app.OpenVPNListClientConfigsHandler)
+	r.Post("/api/v1/openvpn/ccd", app.OpenVPNCreateClientConfigHandler)
+	r.Get("/api/v1/openvpn/ccd/{name}", app.OpenVPNGetClientConfigHandler)
+	r.Put("/api/v1/openvpn/ccd/{name}", app.OpenVPNUpdateClientConfigHandler)
+	r.Delete("/api/v1/openvpn/ccd/{name}", app.OpenVPNDeleteClientConfigHandler)
+	r.Get("/api/v1/openvpn/server-config", app.OpenVPNServerConfigHandler)
+	r.Put("/api/v1/openvpn/server-config", app.OpenVPNUpdateServerConfigHandler)
+	r.Post("/api/v1/openvpn/server-config/diff", app.OpenVPNServerConfigDiffHandler)
+	r.Get("/api/v1/openvpn/expiry", app.OpenVPNExpiryHandler)
+	r.Get("/api/v1/openvpn/expiry/metrics", app.OpenVPNExpiryMetricsHandler)
+	r.Get("/api/v1/ikev2/clients", app.IKEv2ListClientsHandler)
+	r.Post("/api/v1/ikev2/clients", app.IKEv2AddClientHandler)
+	r.Delete("/api/v1/ikev2/clients/{name}", app.IKEv2RevokeClientHandler)
+	r.Get("/api/v1/ikev2/clients/{name}/{format}", app.IKEv2ClientFileHandler)
 }
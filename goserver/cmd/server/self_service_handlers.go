@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type selfServiceUserKey struct{}
+
+type SelfServiceLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type SelfServicePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// SelfServiceMiddleware admits requests carrying a token from
+// SelfServiceLoginHandler whose account is still usable, and passes on the
+// user it belongs to
+func (app *application) SelfServiceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			app.errorResponse(w, r, http.StatusUnauthorized, services.ErrNotLoggedIn.Error())
+			return
+		}
+		username, err := app.selfServiceSessions.User(token)
+		if errors.Is(err, services.ErrNotLoggedIn) {
+			app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), selfServiceUserKey{}, username)))
+	})
+}
+
+func selfServiceUser(r *http.Request) string {
+	return r.Context().Value(selfServiceUserKey{}).(string)
+}
+
+// SelfServiceLoginHandler exchanges chap-secrets credentials for a bearer token
+func (app *application) SelfServiceLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req SelfServiceLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	token, expiresAt, err := app.selfServiceSessions.Login(req.Username, req.Password, r.RemoteAddr)
+	if err != nil {
+		app.logger.Warn("Self-service login rejected", "username", req.Username, "remote", r.RemoteAddr, "reason", err.Error())
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrUserDisabled):
+			app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, services.ErrTooManyAttempts):
+			app.errorResponse(w, r, http.StatusTooManyRequests, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": map[string]any{"token": token, "expires_at": expiresAt}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) SelfServiceLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	app.selfServiceSessions.Logout(token)
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": "logged out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// SelfServiceAccountHandler returns the user's usage in the current period,
+// quota and expiry
+func (app *application) SelfServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	username := selfServiceUser(r)
+
+	accounts, err := app.fileService.ReadAccounts()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	account := accounts[username]
+
+	result := models.SelfServiceAccount{
+		Username:   username,
+		ExpiresAt:  account.ExpiresAt,
+		Expired:    account.Expired(app.fileService.Now()),
+		Usage:      app.bandwidthService.UserUsage(username),
+		QuotaBytes: account.QuotaBytes,
+	}
+	result.PeriodStart, result.PeriodEnd = app.bandwidthService.CurrentPeriod()
+	if account.QuotaBytes > 0 {
+		used := result.Usage.TotalBytesSent + result.Usage.TotalBytesReceived
+		remaining := account.QuotaBytes - min(used, account.QuotaBytes)
+		result.RemainingBytes = &remaining
+		result.QuotaExceeded = remaining == 0
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": result}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// SelfServiceSessionsHandler lists the user's active sessions of any protocol.
+// They come from the bandwidth collectors, so users cannot make the server
+// query OpenVPN or the IPsec container.
+func (app *application) SelfServiceSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions := app.bandwidthService.UserSessions(selfServiceUser(r))
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": sessions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// SelfServicePasswordHandler changes the user's password in chap-secrets and
+// ipsec.d/passwd, and logs out the user's other sessions
+func (app *application) SelfServicePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	username := selfServiceUser(r)
+
+	var req SelfServicePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	err := app.selfServiceSessions.CheckPassword(token, req.CurrentPassword, r.RemoteAddr)
+	if err != nil {
+		app.logger.Warn("Self-service password change rejected", "username", username, "remote", r.RemoteAddr, "reason", err.Error())
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrUserDisabled):
+		app.errorResponse(w, r, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, services.ErrTooManyAttempts):
+		app.errorResponse(w, r, http.StatusTooManyRequests, err.Error())
+		return
+	case errors.Is(err, services.ErrNotLoggedIn):
+		app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	hashed, err := services.GenerateMD5CryptHash(req.NewPassword)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.fileService.SetPassword(username, req.NewPassword, hashed)
+	if errors.Is(err, services.ErrInvalidPassword) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.selfServiceSessions.PasswordChanged(username, token); err != nil {
+		app.logger.Error("Failed to renew self-service session", "username", username, "error", err.Error())
+	}
+	app.logger.Info("Password changed through self-service", "username", username, "remote", r.RemoteAddr)
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": "password changed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// SelfServiceProfileHandler downloads the user's own profile for a platform
+func (app *application) SelfServiceProfileHandler(w http.ResponseWriter, r *http.Request) {
+	profile, err := app.userProfile(r.Context(), selfServiceUser(r), chi.URLParam(r, "platform"))
+	if err != nil {
+		app.userProfileError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", profile.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", profile.Filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(profile.Data)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/LevanPro/server/internal/openvpn"
+	"github.com/LevanPro/server/internal/services"
+)
+
+func (app *application) OpenVPNServerConfigHandler(w http.ResponseWriter, r *http.Request) {
+	config, err := app.openvpnServerConfig.Get()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": config}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) OpenVPNServerConfigDiffHandler(w http.ResponseWriter, r *http.Request) {
+	var req openvpn.ServerConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	change, err := app.openvpnServerConfig.Diff(&req)
+	if err != nil {
+		app.openvpnServerConfigError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": change}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// OpenVPNUpdateServerConfigHandler writes the config and restarts OpenVPN,
+// unless ?restart=false is given. Changes that need firewall changes are
+// refused with 409 until ?firewall_updated=true confirms them.
+func (app *application) OpenVPNUpdateServerConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var req openvpn.ServerConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid request body"))
+		return
+	}
+
+	restart := r.URL.Query().Get("restart") != "false"
+	firewallUpdated := r.URL.Query().Get("firewall_updated") == "true"
+
+	change, err := app.openvpnServerConfig.Apply(r.Context(), &req, restart, firewallUpdated)
+	if errors.Is(err, services.ErrFirewallChange) {
+		if writeErr := app.writeJSON(w, http.StatusConflict, envolope{"data": change, "error": err.Error()}, nil); writeErr != nil {
+			app.serverErrorResponse(w, r, writeErr)
+		}
+		return
+	}
+	if errors.Is(err, services.ErrRestartFailed) {
+		// The files are in place; report what changed along with the failure
+		app.logger.Error("Failed to restart OpenVPN", "error", err.Error())
+
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrManagementUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		if writeErr := app.writeJSON(w, status, envolope{"data": change, "error": err.Error()}, nil); writeErr != nil {
+			app.serverErrorResponse(w, r, writeErr)
+		}
+		return
+	}
+	if err != nil {
+		app.openvpnServerConfigError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": change}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) openvpnServerConfigError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, services.ErrInvalidServerConfig) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	app.serverErrorResponse(w, r, err)
+}
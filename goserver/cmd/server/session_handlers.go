@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LevanPro/server/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+func (app *application) ActiveSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, warnings := app.sessionService.ListActive(r.Context())
+
+	err := app.writeJSON(w, http.StatusOK, envolope{"data": sessions, "warnings": warnings}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) DisconnectSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	err := app.sessionService.Disconnect(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSessionNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, services.ErrManagementUnavailable):
+			app.serviceUnavailableResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": "session disconnected"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// SessionHistoryHandler lists ended sessions from the session journal,
+// filtered by ?user=, ?protocol= and ?since= (RFC 3339), newest first
+func (app *application) SessionHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.SessionJournalFilter{
+		User:     query.Get("user"),
+		Protocol: query.Get("protocol"),
+		Limit:    100,
+	}
+
+	if raw := query.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("since must be an RFC 3339 time"))
+			return
+		}
+		filter.Since = since
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			app.badRequestResponse(w, r, errors.New("limit must be a positive integer"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	records, err := app.sessionJournal.List(filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envolope{"data": records}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,269 @@
+// Command vpnhook forwards OpenVPN and pppd script callbacks to the goserver
+// hook socket, or to its HTTP endpoint with -url and -token. Install it on
+// the OpenVPN host, e.g. with
+//
+//	docker cp goserver:/root/vpnhook /usr/local/bin/vpnhook
+//
+// and enable it in server.conf:
+//
+//	script-security 2
+//	auth-user-pass-verify "/usr/local/bin/vpnhook openvpn-auth" via-file
+//	client-connect "/usr/local/bin/vpnhook openvpn-connect"
+//	client-disconnect "/usr/local/bin/vpnhook openvpn-disconnect"
+//
+// The pppd hooks in scripts/ppp call ppp-up and ppp-down with pppd's
+// arguments. It exits 0 when goserver accepts the callback and 1 otherwise,
+// which OpenVPN treats as a rejected client. An unreachable goserver rejects
+// too.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+const defaultSocket = "/etc/openvpn/server/goserver-hooks.sock"
+
+func main() {
+	socket := flag.String("socket", envOr("GOSERVER_HOOK_SOCKET", defaultSocket), "goserver hook socket")
+	url := flag.String("url", os.Getenv("GOSERVER_HOOK_URL"), "goserver hook HTTP endpoint, used instead of the socket")
+	token := flag.String("token", os.Getenv("GOSERVER_HOOK_TOKEN"), "bearer token for the HTTP endpoint")
+	timeout := flag.Duration("timeout", 10*time.Second, "request timeout")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: vpnhook [flags] openvpn-auth [credentials-file]\n")
+		fmt.Fprintf(os.Stderr, "       vpnhook [flags] openvpn-connect | openvpn-disconnect\n")
+		fmt.Fprintf(os.Stderr, "       vpnhook [flags] ppp-up | ppp-down interface tty speed local remote [ipparam]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := newClient(*socket, *url, *token, *timeout)
+
+	var err error
+	switch flag.Arg(0) {
+	case "openvpn-auth":
+		err = openvpnAuth(client, flag.Args()[1:])
+	case "openvpn-connect":
+		err = openvpnSession(client, "/openvpn/connect")
+	case "openvpn-disconnect":
+		err = openvpnSession(client, "/openvpn/disconnect")
+	case "ppp-up":
+		err = pppSession(client, "/ppp/up", flag.Args()[1:])
+	case "ppp-down":
+		err = pppSession(client, "/ppp/down", flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vpnhook %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+// openvpnAuth handles auth-user-pass-verify. With via-file OpenVPN passes a
+// file holding the username and password on two lines; with via-env they
+// are in the username and password variables.
+func openvpnAuth(client *hookClient, args []string) error {
+	req := models.OpenVPNAuthRequest{
+		Username:    os.Getenv("username"),
+		Password:    os.Getenv("password"),
+		CommonName:  os.Getenv("common_name"),
+		UntrustedIP: os.Getenv("untrusted_ip"),
+	}
+
+	if len(args) > 0 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		lines := make([]string, 0, 2)
+		for len(lines) < 2 && scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		if len(lines) != 2 {
+			return errors.New("credentials file must hold a username and a password")
+		}
+		req.Username, req.Password = lines[0], lines[1]
+	}
+
+	if req.Username == "" {
+		return errors.New("no username provided")
+	}
+	return client.post("/openvpn/auth", req)
+}
+
+// openvpnSession handles client-connect and client-disconnect from the
+// environment OpenVPN sets for them. The dynamic config file client-connect
+// passes is left empty.
+func openvpnSession(client *hookClient, path string) error {
+	connected, err := strconv.ParseInt(os.Getenv("time_unix"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid time_unix: %w", err)
+	}
+
+	event := models.OpenVPNSessionEvent{
+		CommonName:     os.Getenv("common_name"),
+		Username:       os.Getenv("username"),
+		VirtualAddress: os.Getenv("ifconfig_pool_remote_ip"),
+		ConnectedAt:    time.Unix(connected, 0).UTC(),
+	}
+
+	ip := envOr("trusted_ip", os.Getenv("trusted_ip6"))
+	if ip != "" {
+		event.RealAddress = net.JoinHostPort(ip, os.Getenv("trusted_port"))
+	}
+
+	// Counters are only set for client-disconnect
+	for name, value := range map[string]*uint64{"bytes_sent": &event.BytesSent, "bytes_received": &event.BytesReceived} {
+		if raw := os.Getenv(name); raw != "" {
+			if *value, err = strconv.ParseUint(raw, 10, 64); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	if raw := os.Getenv("time_duration"); raw != "" {
+		if event.Duration, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			return fmt.Errorf("invalid time_duration: %w", err)
+		}
+	}
+
+	if event.CommonName == "" {
+		return errors.New("no common_name provided")
+	}
+	return client.post(path, event)
+}
+
+// pppSession handles pppd ip-up and ip-down. pppd passes the interface,
+// tty, speed, local and remote address and ipparam as arguments and, on
+// ip-down, the totals in BYTES_SENT, BYTES_RCVD and CONNECT_TIME.
+func pppSession(client *hookClient, path string, args []string) error {
+	event := models.PPPSessionEvent{
+		Interface:      envOr("IFNAME", argOr(args, 0)),
+		Peer:           os.Getenv("PEERNAME"),
+		RemoteAddress:  argOr(args, 5),
+		VirtualAddress: envOr("IPREMOTE", argOr(args, 4)),
+	}
+
+	var err error
+	for name, value := range map[string]*uint64{"BYTES_SENT": &event.BytesSent, "BYTES_RCVD": &event.BytesReceived} {
+		if raw := os.Getenv(name); raw != "" {
+			if *value, err = strconv.ParseUint(raw, 10, 64); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	if raw := os.Getenv("CONNECT_TIME"); raw != "" {
+		if event.ConnectTime, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			return fmt.Errorf("invalid CONNECT_TIME: %w", err)
+		}
+	}
+
+	if event.Interface == "" || event.Peer == "" {
+		return errors.New("no interface or PEERNAME provided")
+	}
+	return client.post(path, event)
+}
+
+type hookClient struct {
+	http    *http.Client
+	baseURL string
+	token   string
+}
+
+func newClient(socket, url, token string, timeout time.Duration) *hookClient {
+	if url != "" {
+		return &hookClient{
+			http:    &http.Client{Timeout: timeout},
+			baseURL: strings.TrimSuffix(url, "/"),
+			token:   token,
+		}
+	}
+
+	var dialer net.Dialer
+	return &hookClient{
+		http: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+		baseURL: "http://goserver",
+	}
+}
+
+// post sends body as JSON and turns a non-2xx answer into its error message
+func (c *hookClient) post(path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var env struct {
+		Error any `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil || env.Error == nil {
+		return fmt.Errorf("goserver answered %s", resp.Status)
+	}
+	return fmt.Errorf("%v", env.Error)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func argOr(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
@@ -15,6 +15,13 @@ type Config struct {
 	HTTPServer        `yaml:"http_server"`
 	UDPServer         `yaml:"udp_server"`
 	BandwidthTracking `yaml:"bandwidth_tracking"`
+	OpenVPN           `yaml:"openvpn"`
+	Users             `yaml:"users"`
+	Hooks             `yaml:"hooks"`
+	Radius            `yaml:"radius"`
+	Profiles          `yaml:"profiles"`
+	Links             `yaml:"links"`
+	SelfService       `yaml:"self_service"`
 }
 
 type HTTPServer struct {
@@ -25,9 +32,154 @@ type UDPServer struct {
 	Address string `yaml:"address" env-default:":8081"`
 }
 
+type OpenVPN struct {
+	ServerDir  string `yaml:"server_dir" env-default:"/etc/openvpn/server"` // server.conf, keys, crl.pem and easy-rsa/
+	ClientDays int    `yaml:"client_days" env-default:"3650"`
+	CRLDays    int    `yaml:"crl_days" env-default:"3650"`
+	PKIBackend string `yaml:"pki_backend" env-default:"native"` // native or easyrsa
+	Subnet     string `yaml:"subnet" env-default:"10.8.0.0/24"` // the server directive of server.conf
+	// RestartMethod applies server.conf changes: management sends SIGHUP, command runs RestartCommand
+	RestartMethod  string         `yaml:"restart_method" env-default:"none"` // management, command or none
+	RestartCommand []string       `yaml:"restart_command" env-default:"systemctl,restart,openvpn-server@server.service"`
+	ExpiryCheck    PKIExpiryCheck `yaml:"expiry_check"`
+}
+
+// PKIExpiryCheck watches the CA, server and client certificates and crl.pem
+type PKIExpiryCheck struct {
+	Enabled           string `yaml:"enabled" env-default:"true"`
+	Interval          string `yaml:"interval" env-default:"12h"`
+	WarningDays       int    `yaml:"warning_days" env-default:"30"`
+	CriticalDays      int    `yaml:"critical_days" env-default:"7"`
+	CRLAutoRegenerate string `yaml:"crl_auto_regenerate" env-default:"true"` // reissue the CRL at warning_days
+}
+
+type Users struct {
+	AccountsFile   string `yaml:"accounts_file" env-default:"goserver/accounts.json"`    // disabled, expiry and quota state, relative to storage_path
+	SessionJournal string `yaml:"session_journal" env-default:"goserver/sessions.jsonl"` // ended sessions reported by hooks, relative to storage_path
+}
+
+// Hooks is the local socket the vpnhook helper calls from OpenVPN and pppd
+// scripts. It is not behind the API token, so keep it off the network.
+type Hooks struct {
+	Enabled string `yaml:"enabled" env-default:"false"`
+	Socket  string `yaml:"socket" env-default:"/etc/openvpn/server/goserver-hooks.sock"`
+	// SocketGroup owns the socket, name or ID of the group OpenVPN runs as.
+	// Names are looked up in this container, so the ID is the safer choice.
+	SocketGroup string `yaml:"socket_group" env-default:"65534"`
+	// Address also serves the hooks over HTTP, e.g. for pppd in the ipsec
+	// container; requests must carry Token as a bearer token
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
+	// PPP serves the pppd ip-up and ip-down hooks. RADIUS accounting counts
+	// the same L2TP sessions, so the two cannot be enabled together.
+	PPP string `yaml:"ppp" env-default:"true"`
+}
+
+// Radius is the embedded RADIUS server pppd and Libreswan can use instead of
+// the flat credential files. Either address may be empty to serve only the other.
+type Radius struct {
+	Enabled     string `yaml:"enabled" env-default:"false"`
+	AuthAddress string `yaml:"auth_address" env-default:":1812"`
+	AcctAddress string `yaml:"acct_address" env-default:":1813"`
+	Secret      string `yaml:"secret"`
+	// RequireMessageAuthenticator drops Access-Requests without a valid
+	// Message-Authenticator (Blast-RADIUS, CVE-2024-3596); only turn it off
+	// for clients that cannot send one
+	RequireMessageAuthenticator string `yaml:"require_message_authenticator" env-default:"true"`
+}
+
+// Profiles fills the client configurations served for L2TP and IPsec/XAuth users
+type Profiles struct {
+	ServerAddress string   `yaml:"server_address"` // public DNS name or IP address of the VPN server
+	Name          string   `yaml:"name" env-default:"VPN"`
+	DNSServers    []string `yaml:"dns_servers" env-default:"8.8.8.8,8.8.4.4"`
+}
+
+// Links are the single-use download links to credentials and profiles
+type Links struct {
+	// PublicURL is where devices reach this API, e.g.
+	// https://vpn.example.com:8080; empty to use the host of the request
+	PublicURL string `yaml:"public_url"`
+	Dir       string `yaml:"dir" env-default:"goserver/links"`                  // encrypted unused links, relative to storage_path
+	AuditLog  string `yaml:"audit_log" env-default:"goserver/deliveries.jsonl"` // relative to storage_path
+	TTL       string `yaml:"ttl" env-default:"15m"`
+	MaxTTL    string `yaml:"max_ttl" env-default:"24h"`
+}
+
+// SelfService is the public-facing listener where VPN users log in with their
+// chap-secrets credentials; it serves none of the admin routes. Passwords
+// cross it, so it serves TLS unless Address is a loopback address, e.g.
+// behind a reverse proxy that terminates TLS.
+type SelfService struct {
+	Enabled    string `yaml:"enabled" env-default:"false"`
+	Address    string `yaml:"address" env-default:":8090"`
+	SessionTTL string `yaml:"session_ttl" env-default:"1h"`
+	TLSCert    string `yaml:"tls_cert"` // PEM certificate chain, read at startup
+	TLSKey     string `yaml:"tls_key"`
+}
+
 type BandwidthTracking struct {
 	CollectionInterval string `yaml:"collection_interval" env-default:"60s"`
 	StoragePath        string `yaml:"storage_path" env-default:"bandwidth"`
+	BackupGenerations  int    `yaml:"backup_generations" env-default:"3"`
+	Recovery           string `yaml:"recovery" env-default:"fail"` // fail, backup or reset
+	Billing            `yaml:"billing"`
+	Collectors         `yaml:"collectors"`
+}
+
+type Billing struct {
+	Cycle       string `yaml:"cycle" env-default:"none"` // none, calendar_month, day_of_month or rolling
+	DayOfMonth  int    `yaml:"day_of_month" env-default:"1"`
+	RollingDays int    `yaml:"rolling_days" env-default:"30"`
+	Timezone    string `yaml:"timezone" env-default:"UTC"`
+}
+
+type Collectors struct {
+	OpenVPNStatus     OpenVPNStatusCollector     `yaml:"openvpn_status"`
+	OpenVPNManagement OpenVPNManagementCollector `yaml:"openvpn_management"`
+	DockerStats       DockerStatsCollector       `yaml:"docker_stats"`
+	HostInterfaces    HostInterfacesCollector    `yaml:"host_interfaces"`
+}
+
+// Collector holds the settings shared by every bandwidth collector.
+// Enabled is a string so that an explicit "false" is not replaced by the default.
+type Collector struct {
+	Enabled  string `yaml:"enabled" env-default:"true"`
+	Interval string `yaml:"interval"` // defaults to collection_interval
+}
+
+type OpenVPNStatusCollector struct {
+	Collector  `yaml:",inline"`
+	StatusFile string `yaml:"status_file" env-default:"/var/log/openvpn/status.log"`
+}
+
+// OpenVPNManagementCollector is opt-in. When enabled it replaces the
+// openvpn_status collector, whose file is then only read as a fallback.
+type OpenVPNManagementCollector struct {
+	Enabled           string `yaml:"enabled" env-default:"false"`
+	Interval          string `yaml:"interval"`                   // full status poll
+	Network           string `yaml:"network" env-default:"unix"` // unix or tcp
+	Address           string `yaml:"address" env-default:"/etc/openvpn/server/management.sock"`
+	Password          string `yaml:"password"`
+	BytecountInterval string `yaml:"bytecount_interval" env-default:"5s"`
+}
+
+type DockerStatsCollector struct {
+	Collector `yaml:",inline"`
+	Container string `yaml:"container" env-default:"ipsec-mobify-server"`
+}
+
+// HostInterfacesCollector is opt-in, so it does not embed Collector's enabled default
+type HostInterfacesCollector struct {
+	Enabled         string   `yaml:"enabled" env-default:"false"`
+	Interval        string   `yaml:"interval"`
+	Include         []string `yaml:"include" env-default:"tun*,ppp*"`
+	Exclude         []string `yaml:"exclude"`
+	EgressInterface string   `yaml:"egress_interface"` // empty: interface of the default route
+	ProcPath        string   `yaml:"proc_path" env-default:"/proc"`
+	SysPath         string   `yaml:"sys_path" env-default:"/sys"`
+	PPPUsersDir     string   `yaml:"ppp_users_dir" env-default:"/var/run/goserver/ppp"`
+	PPPAsIPSec      bool     `yaml:"ppp_as_ipsec"` // credit ppp traffic to IPSec totals when docker_stats is off
 }
 
 func MustLoad() *Config {
@@ -4,35 +4,64 @@ import "time"
 
 // BandwidthMetrics represents the combined bandwidth metrics for all VPN services
 type BandwidthMetrics struct {
-	Timestamp       time.Time       `json:"timestamp"`
-	OpenVPN         OpenVPNMetrics  `json:"openvpn"`
-	IPSec           IPSecMetrics    `json:"ipsec"`
-	CombinedTotalMB float64         `json:"combined_total_mb"`
+	Timestamp       time.Time      `json:"timestamp"`
+	OpenVPN         OpenVPNMetrics `json:"openvpn"`
+	IPSec           IPSecMetrics   `json:"ipsec"`
+	Host            *HostMetrics   `json:"host,omitempty"`
+	CombinedTotalMB float64        `json:"combined_total_mb"`
 }
 
 // OpenVPNMetrics contains bandwidth metrics for OpenVPN
 type OpenVPNMetrics struct {
-	TotalBytesSent     uint64  `json:"total_bytes_sent"`
-	TotalBytesReceived uint64  `json:"total_bytes_received"`
-	TotalBandwidthMB   float64 `json:"total_bandwidth_mb"`
-	ActiveClients      int     `json:"active_clients"`
+	TotalBytesSent     uint64          `json:"total_bytes_sent"`
+	TotalBytesReceived uint64          `json:"total_bytes_received"`
+	TotalBandwidthMB   float64         `json:"total_bandwidth_mb"`
+	ActiveClients      int             `json:"active_clients"`
+	Rate               *ThroughputRate `json:"rate,omitempty"`
 }
 
 // IPSecMetrics contains bandwidth metrics for IPSec
 type IPSecMetrics struct {
-	TotalBytesSent     uint64  `json:"total_bytes_sent"`
-	TotalBytesReceived uint64  `json:"total_bytes_received"`
-	TotalBandwidthMB   float64 `json:"total_bandwidth_mb"`
+	TotalBytesSent     uint64          `json:"total_bytes_sent"`
+	TotalBytesReceived uint64          `json:"total_bytes_received"`
+	TotalBandwidthMB   float64         `json:"total_bandwidth_mb"`
+	Rate               *ThroughputRate `json:"rate,omitempty"`
+}
+
+// HostMetrics contains host network interface metrics, including total server egress
+type HostMetrics struct {
+	EgressInterface     string             `json:"egress_interface"`
+	EgressBytesSent     uint64             `json:"egress_bytes_sent"`
+	EgressBytesReceived uint64             `json:"egress_bytes_received"`
+	EgressBandwidthMB   float64            `json:"egress_bandwidth_mb"`
+	EgressRate          *ThroughputRate    `json:"egress_rate,omitempty"`
+	Interfaces          []InterfaceMetrics `json:"interfaces"`
+}
+
+// InterfaceMetrics contains traffic of a single host interface
+type InterfaceMetrics struct {
+	Name          string          `json:"name"`
+	User          string          `json:"user,omitempty"` // ppp peer name, when known
+	BytesSent     uint64          `json:"bytes_sent"`
+	BytesReceived uint64          `json:"bytes_received"`
+	BandwidthMB   float64         `json:"bandwidth_mb"`
+	Rate          *ThroughputRate `json:"rate,omitempty"`
 }
 
 // ClientState tracks OpenVPN client bandwidth state
 type ClientState struct {
-	CommonName     string    `json:"common_name"`
-	RealAddress    string    `json:"real_address"`
-	BytesSent      uint64    `json:"bytes_sent"`
-	BytesReceived  uint64    `json:"bytes_received"`
-	ConnectedSince time.Time `json:"connected_since"`
-	LastSeenAt     time.Time `json:"last_seen_at"`
+	CommonName         string         `json:"common_name"`
+	RealAddress        string         `json:"real_address"`
+	VirtualAddress     string         `json:"virtual_address,omitempty"`
+	VirtualIPv6Address string         `json:"virtual_ipv6_address,omitempty"`
+	Username           string         `json:"username,omitempty"`
+	ClientID           int64          `json:"client_id"` // -1 when not reported by the server
+	PeerID             int64          `json:"peer_id"`   // -1 when not reported by the server
+	BytesSent          uint64         `json:"bytes_sent"`
+	BytesReceived      uint64         `json:"bytes_received"`
+	ConnectedSince     time.Time      `json:"connected_since"`
+	LastSeenAt         time.Time      `json:"last_seen_at"`
+	Rate               ThroughputRate `json:"rate"`
 }
 
 // AccumulatedData stores cumulative metrics
@@ -45,9 +74,145 @@ type AccumulatedData struct {
 
 // BandwidthAccumulator stores cumulative bandwidth across client sessions
 type BandwidthAccumulator struct {
-	LastUpdated  time.Time                `json:"last_updated"`
-	LastResetAt  time.Time                `json:"last_reset_at"`
-	OpenVPN      AccumulatedData          `json:"openvpn"`
-	IPSec        AccumulatedData          `json:"ipsec"`
-	ClientStates map[string]ClientState   `json:"client_states"` // key: common_name
+	SchemaVersion   int                        `json:"schema_version"`
+	LastUpdated     time.Time                  `json:"last_updated"`
+	LastResetAt     time.Time                  `json:"last_reset_at"`
+	OpenVPN         AccumulatedData            `json:"openvpn"`
+	IPSec           AccumulatedData            `json:"ipsec"`
+	ClientStates    map[string]ClientState     `json:"client_states"` // key: session ID
+	ClientTotals    map[string]AccumulatedData `json:"client_totals"` // key: common_name
+	IPSecState      *IPSecCounterState         `json:"ipsec_state,omitempty"`
+	Egress          AccumulatedData            `json:"egress"`
+	InterfaceTotals map[string]AccumulatedData `json:"interface_totals"` // key: interface name
+	PPPUserTotals   map[string]AccumulatedData `json:"ppp_user_totals"`  // key: ppp peer name
+	HostState       *HostCounterState          `json:"host_state,omitempty"`
+	// RADIUS accounting, kept apart from the collectors: the NAS reports exact per-session counters
+	RadiusSessions   map[string]RadiusSessionState `json:"radius_sessions,omitempty"` // key: NAS and Acct-Session-Id
+	RadiusUserTotals map[string]AccumulatedData    `json:"radius_user_totals"`        // key: User-Name
+	// Sessions the client-connect hook reported, so that their disconnect is
+	// accepted after a restart too
+	HookedOpenVPNSessions map[string]time.Time `json:"hooked_openvpn_sessions,omitempty"` // key: common name and connect time, value: when reported
+	HookedPPPSessions     map[string]string    `json:"hooked_ppp_sessions,omitempty"`     // key: interface name, value: peer
+}
+
+// RadiusSessionState is the last accounting record of an open RADIUS session,
+// the baseline the next interim update or stop is measured from
+type RadiusSessionState struct {
+	User          string    `json:"user"`
+	StartedAt     time.Time `json:"started_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	BytesSent     uint64    `json:"bytes_sent"`
+	BytesReceived uint64    `json:"bytes_received"`
+}
+
+// HostCounterState is the last observed counter baseline of host interfaces
+type HostCounterState struct {
+	ObservedAt      time.Time                    `json:"observed_at"`
+	EgressInterface string                       `json:"egress_interface"`
+	Interfaces      map[string]InterfaceCounters `json:"interfaces"` // key: interface name
+}
+
+// InterfaceCounters holds the raw cumulative counters of a network interface
+type InterfaceCounters struct {
+	RxBytes uint64 `json:"rx_bytes"`
+	TxBytes uint64 `json:"tx_bytes"`
+}
+
+// IPSecCounterState is the last observed counter baseline of the IPSec container.
+// The container ID and start time identify a counter epoch: when either changes,
+// the kernel counters have started again from zero.
+type IPSecCounterState struct {
+	ContainerID string                       `json:"container_id"`
+	StartedAt   time.Time                    `json:"started_at"`
+	ObservedAt  time.Time                    `json:"observed_at"`
+	Interfaces  map[string]InterfaceCounters `json:"interfaces"` // key: interface name
+}
+
+// ThroughputRate holds instantaneous and smoothed (EWMA) transfer rates in bytes per second
+type ThroughputRate struct {
+	SentPerSecond             float64 `json:"sent_bytes_per_second"`
+	ReceivedPerSecond         float64 `json:"received_bytes_per_second"`
+	SmoothedSentPerSecond     float64 `json:"smoothed_sent_bytes_per_second"`
+	SmoothedReceivedPerSecond float64 `json:"smoothed_received_bytes_per_second"`
+}
+
+// ClientThroughput reports an OpenVPN client's traffic over the last collection interval
+type ClientThroughput struct {
+	SessionID          string         `json:"session_id"`
+	CommonName         string         `json:"common_name"`
+	RealAddress        string         `json:"real_address"`
+	DeltaBytesSent     uint64         `json:"delta_bytes_sent"`
+	DeltaBytesReceived uint64         `json:"delta_bytes_received"`
+	Rate               ThroughputRate `json:"rate"`
+}
+
+// IPSecConnection is an established IPsec SA as reported by `ipsec trafficstatus`
+type IPSecConnection struct {
+	Serial        string         `json:"serial"`     // state serial, e.g. #3
+	Connection    string         `json:"connection"` // connection name with instance, e.g. l2tp-psk[1]
+	RemoteAddress string         `json:"remote_address"`
+	Username      string         `json:"username,omitempty"`
+	PeerID        string         `json:"peer_id,omitempty"`
+	VirtualIP     string         `json:"virtual_ip,omitempty"`
+	AddedAt       time.Time      `json:"added_at"`
+	BytesIn       uint64         `json:"bytes_in"`
+	BytesOut      uint64         `json:"bytes_out"`
+	Rate          ThroughputRate `json:"rate"`
+}
+
+// TopTalker is a client or connection ranked by its current transfer rate
+type TopTalker struct {
+	Protocol      string         `json:"protocol"` // openvpn or ipsec
+	ID            string         `json:"id"`
+	User          string         `json:"user,omitempty"`
+	RemoteAddress string         `json:"remote_address"`
+	Rate          ThroughputRate `json:"rate"`
+}
+
+// BandwidthSnapshot is the payload pushed to stream subscribers after each collection tick
+type BandwidthSnapshot struct {
+	Timestamp        time.Time          `json:"timestamp"`
+	IntervalSeconds  float64            `json:"interval_seconds"`
+	Accumulated      BandwidthMetrics   `json:"accumulated"`
+	Clients          []ClientThroughput `json:"clients"`
+	IPSecConnections []IPSecConnection  `json:"ipsec_connections"`
+}
+
+// BandwidthPeriod is a closed billing period archived before the accumulator was reset
+type BandwidthPeriod struct {
+	ID              string                     `json:"id"`
+	Start           time.Time                  `json:"start"`
+	End             time.Time                  `json:"end"`
+	ClosedAt        time.Time                  `json:"closed_at"`
+	Reason          string                     `json:"reason"` // scheduled or manual
+	OpenVPN         AccumulatedData            `json:"openvpn"`
+	IPSec           AccumulatedData            `json:"ipsec"`
+	CombinedTotalMB float64                    `json:"combined_total_mb"`
+	Clients         map[string]AccumulatedData `json:"clients"` // key: common_name
+	Egress          AccumulatedData            `json:"egress"`
+	Interfaces      map[string]AccumulatedData `json:"interfaces"` // key: interface name
+	PPPUsers        map[string]AccumulatedData `json:"ppp_users"`  // key: ppp peer name
+	RadiusUsers     map[string]AccumulatedData `json:"radius_users,omitempty"`
+}
+
+// BandwidthPeriodSummary is the list view of an archived billing period
+type BandwidthPeriodSummary struct {
+	ID              string    `json:"id"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Reason          string    `json:"reason"`
+	CombinedTotalMB float64   `json:"combined_total_mb"`
+}
+
+// CollectorStatus reports the health of a bandwidth collector
+type CollectorStatus struct {
+	Name                string     `json:"name"`
+	Enabled             bool       `json:"enabled"`
+	Interval            string     `json:"interval"`
+	Healthy             bool       `json:"healthy"`
+	LastRunAt           *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
 }
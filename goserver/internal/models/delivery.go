@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Download link kinds
+const (
+	LinkKindCredentials = "credentials"
+	LinkKindProfile     = "profile"
+)
+
+// DownloadLink is a single-use link as issued; the URL is only shown once
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	Kind      string    `json:"kind"`
+	User      string    `json:"user"`
+	Platform  string    `json:"platform,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Delivery audit events
+const (
+	DeliveryIssued    = "issued"
+	DeliveryDelivered = "delivered"
+	DeliveryExpired   = "expired"  // removed unused
+	DeliveryRejected  = "rejected" // unknown, used or expired token presented
+)
+
+// DeliveryRecord is an entry of the delivery audit log
+type DeliveryRecord struct {
+	Time          time.Time  `json:"time"`
+	Event         string     `json:"event"`
+	LinkID        string     `json:"link_id,omitempty"` // prefix of the token hash, never the token
+	Kind          string     `json:"kind,omitempty"`
+	User          string     `json:"user,omitempty"`
+	Platform      string     `json:"platform,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RemoteAddress string     `json:"remote_address,omitempty"`
+	UserAgent     string     `json:"user_agent,omitempty"`
+}
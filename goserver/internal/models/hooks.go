@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// OpenVPNAuthRequest is sent by vpnhook for auth-user-pass-verify
+type OpenVPNAuthRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	CommonName  string `json:"common_name,omitempty"`
+	UntrustedIP string `json:"untrusted_ip,omitempty"`
+}
+
+// OpenVPNSessionEvent is sent by vpnhook for client-connect and
+// client-disconnect. Byte counts are from the server's point of view and,
+// like Duration, only set on disconnect.
+type OpenVPNSessionEvent struct {
+	CommonName     string    `json:"common_name"`
+	Username       string    `json:"username,omitempty"`
+	RealAddress    string    `json:"real_address,omitempty"`
+	VirtualAddress string    `json:"virtual_address,omitempty"`
+	ConnectedAt    time.Time `json:"connected_at"`
+	BytesSent      uint64    `json:"bytes_sent"`
+	BytesReceived  uint64    `json:"bytes_received"`
+	Duration       int64     `json:"duration_seconds"`
+}
+
+// PPPSessionEvent is sent by the pppd ip-up and ip-down hooks of L2TP
+// sessions. Byte counts are from the server's point of view and, like
+// ConnectTime, only set on ip-down.
+type PPPSessionEvent struct {
+	Interface      string `json:"interface"`
+	Peer           string `json:"peer"`                     // PEERNAME, the authenticated user
+	RemoteAddress  string `json:"remote_address,omitempty"` // ipparam, the client address xl2tpd passes
+	VirtualAddress string `json:"virtual_address,omitempty"`
+	BytesSent      uint64 `json:"bytes_sent"`
+	BytesReceived  uint64 `json:"bytes_received"`
+	ConnectTime    int64  `json:"connect_time_seconds"`
+}
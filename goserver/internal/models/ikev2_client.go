@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// IKEv2Client is an IKEv2 client certificate in the NSS database of the IPsec
+// container, with the same statuses as OpenVPN clients
+type IKEv2Client struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Serial    string    `json:"serial"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
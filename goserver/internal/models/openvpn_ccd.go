@@ -0,0 +1,14 @@
+package models
+
+// OpenVPNClientConfig is the client-config-dir entry of a common name. It
+// applies from the client's next connection.
+type OpenVPNClientConfig struct {
+	CommonName   string   `json:"common_name"`
+	IfconfigPush string   `json:"ifconfig_push,omitempty"` // static VPN address, e.g. 10.8.0.50
+	IRoutes      []string `json:"iroutes"`                 // networks behind the client, CIDR
+	PushRoutes   []string `json:"push_routes"`             // CIDR
+	DNS          []string `json:"dns"`
+	Disabled     bool     `json:"disabled"`
+	Other        []string `json:"other,omitempty"` // unmanaged directives, kept on update
+	Error        string   `json:"error,omitempty"` // set instead of the fields above when the file cannot be parsed
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Certificate statuses of OpenVPN clients
+const (
+	CertificateValid   = "valid"
+	CertificateRevoked = "revoked"
+	CertificateExpired = "expired"
+)
+
+// OpenVPNClient is an OpenVPN client certificate as recorded in the PKI index
+type OpenVPNClient struct {
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Serial    string     `json:"serial"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
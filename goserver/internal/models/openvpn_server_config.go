@@ -0,0 +1,14 @@
+package models
+
+// OpenVPNServerConfigChange describes how server.conf and client-common.txt
+// change for a new server configuration
+type OpenVPNServerConfigChange struct {
+	Changed          bool   `json:"changed"`
+	ServerConfDiff   string `json:"server_conf_diff"`   // unified diff, empty when unchanged
+	ClientCommonDiff string `json:"client_common_diff"` // unified diff, empty when unchanged
+	// FirewallChanges are the host firewall rules the change needs, which
+	// goserver does not update
+	FirewallChanges []string `json:"firewall_changes,omitempty"`
+	Applied         bool     `json:"applied"`
+	Restarted       bool     `json:"restarted"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Kinds of PKI artefacts checked for expiry
+const (
+	PKIKindCA     = "ca"
+	PKIKindServer = "server"
+	PKIKindClient = "client"
+	PKIKindCRL    = "crl"
+)
+
+// Expiry levels, from best to worst
+const (
+	ExpiryOK       = "ok"
+	ExpiryWarning  = "warning"
+	ExpiryCritical = "critical"
+	ExpiryExpired  = "expired"
+)
+
+// PKIExpiry is the remaining lifetime of a certificate or of the CRL
+type PKIExpiry struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"` // common name, or crl.pem
+	Serial    string    `json:"serial,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"` // notAfter, or nextUpdate for the CRL
+	DaysLeft  int       `json:"days_left"`  // negative once expired
+	Level     string    `json:"level"`
+}
+
+// PKIExpiryReport is the result of one expiry check
+type PKIExpiryReport struct {
+	CheckedAt      time.Time   `json:"checked_at"`
+	Level          string      `json:"level"` // worst level of all items
+	WarningDays    int         `json:"warning_days"`
+	CriticalDays   int         `json:"critical_days"`
+	Items          []PKIExpiry `json:"items"`
+	CRLRegenerated bool        `json:"crl_regenerated"`
+	Errors         []string    `json:"errors,omitempty"` // artefacts that could not be read
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// SelfServiceAccount is what a VPN user sees of their own account
+type SelfServiceAccount struct {
+	Username  string     `json:"username"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+	// Usage counts the current period, from PeriodStart until PeriodEnd when a billing cycle is set
+	Usage       AccumulatedData `json:"usage"`
+	PeriodStart time.Time       `json:"period_start"`
+	PeriodEnd   *time.Time      `json:"period_end,omitempty"`
+	// QuotaBytes is 0 for no limit; RemainingBytes is only set with a quota
+	QuotaBytes     uint64  `json:"quota_bytes,omitempty"`
+	RemainingBytes *uint64 `json:"remaining_bytes,omitempty"`
+	QuotaExceeded  bool    `json:"quota_exceeded"`
+}
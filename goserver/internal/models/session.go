@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Session protocols
+const (
+	ProtocolOpenVPN    = "openvpn"
+	ProtocolIPSecXAuth = "ipsec_xauth"
+	ProtocolL2TP       = "l2tp"
+	ProtocolIKEv2      = "ikev2"
+	ProtocolIPSec      = "ipsec" // any other IPsec connection
+)
+
+// ActiveSession is a currently connected VPN session of any protocol
+type ActiveSession struct {
+	ID             string     `json:"id"` // e.g. openvpn-4 or ipsec-3
+	Protocol       string     `json:"protocol"`
+	User           string     `json:"user"`
+	RemoteAddress  string     `json:"remote_address"`
+	VirtualAddress string     `json:"virtual_address,omitempty"`
+	BytesSent      uint64     `json:"bytes_sent"`     // server to client
+	BytesReceived  uint64     `json:"bytes_received"` // client to server
+	ConnectedSince *time.Time `json:"connected_since,omitempty"`
+	Connection     string     `json:"connection,omitempty"` // IPsec connection name with instance
+}
+
+// Session record sources
+const (
+	SessionSourceOpenVPNHook = "openvpn_hook"
+	SessionSourcePPPHook     = "ppp_hook"
+	SessionSourceRadius      = "radius"
+)
+
+// SessionRecord is an ended session kept in the session journal
+type SessionRecord struct {
+	Protocol        string    `json:"protocol"`
+	User            string    `json:"user"`
+	RemoteAddress   string    `json:"remote_address,omitempty"`
+	VirtualAddress  string    `json:"virtual_address,omitempty"`
+	Interface       string    `json:"interface,omitempty"`
+	ConnectedAt     time.Time `json:"connected_at"`
+	DisconnectedAt  time.Time `json:"disconnected_at"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	BytesSent       uint64    `json:"bytes_sent"`     // server to client
+	BytesReceived   uint64    `json:"bytes_received"` // client to server
+	Source          string    `json:"source"`         // what reported the session
+}
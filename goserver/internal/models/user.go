@@ -1,8 +1,33 @@
 package models
 
+import "time"
+
 type User struct {
 	Username       string
 	Password       string
 	PasswordHashed string
 	PSKSecret      string
+	Disabled       bool
+	ExpiresAt      *time.Time
+	QuotaBytes     uint64
+	FramedIP       string
+	SessionTimeout int
+}
+
+// UserAccount is the account state kept next to the credential files. Users
+// without an entry are enabled and never expire.
+type UserAccount struct {
+	Disabled  bool       `json:"disabled"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// QuotaBytes limits the traffic sent and received per billing period, 0 for no limit
+	QuotaBytes uint64 `json:"quota_bytes,omitempty"`
+	// FramedIP is the IPv4 address the RADIUS server assigns to the user's sessions
+	FramedIP string `json:"framed_ip,omitempty"`
+	// SessionTimeout limits a RADIUS-authenticated session, in seconds, 0 for no limit
+	SessionTimeout int `json:"session_timeout,omitempty"`
+}
+
+// Expired reports whether the account expiry passed at now
+func (a UserAccount) Expired(now time.Time) bool {
+	return a.ExpiresAt != nil && !now.Before(*a.ExpiresAt)
 }
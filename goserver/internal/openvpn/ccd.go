@@ -0,0 +1,236 @@
+package openvpn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/bits"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// CCD is a per-client file of the client-config-dir. Directives this package
+// does not manage are kept verbatim in Other.
+type CCD struct {
+	// IfconfigPush is the static client address with the prefix length of
+	// the server subnet (topology subnet); invalid when unset
+	IfconfigPush netip.Prefix
+	IRoutes      []netip.Prefix // networks behind the client
+	PushRoutes   []netip.Prefix // push "route ..."
+	DNS          []netip.Addr   // push "dhcp-option DNS ..."
+	Disable      bool
+	Other        []string
+}
+
+// ParseCCD parses a client-config-dir file
+func ParseCCD(data []byte) (*CCD, error) {
+	ccd := &CCD{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if text[0] == '#' || text[0] == ';' {
+			ccd.Other = append(ccd.Other, text)
+			continue
+		}
+
+		args, err := splitDirective(text)
+		if err != nil {
+			return nil, fmt.Errorf("ccd line %d: %w", line, err)
+		}
+
+		managed, err := ccd.apply(args)
+		if err != nil {
+			return nil, fmt.Errorf("ccd line %d: %w", line, err)
+		}
+		if !managed {
+			ccd.Other = append(ccd.Other, text)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ccd: %w", err)
+	}
+
+	return ccd, nil
+}
+
+// apply records a directive, reporting false for directives left to Other
+func (c *CCD) apply(args []string) (bool, error) {
+	switch {
+	case args[0] == "disable" && len(args) == 1:
+		c.Disable = true
+
+	case args[0] == "ifconfig-push" && len(args) == 3:
+		prefix, err := parseNetwork(args[1], args[2], false)
+		if err != nil {
+			return false, fmt.Errorf("ifconfig-push: %w", err)
+		}
+		c.IfconfigPush = prefix
+
+	case args[0] == "iroute" && (len(args) == 2 || len(args) == 3):
+		prefix, err := parseNetwork(args[1], optional(args, 2, "255.255.255.255"), true)
+		if err != nil {
+			return false, fmt.Errorf("iroute: %w", err)
+		}
+		c.IRoutes = append(c.IRoutes, prefix)
+
+	case args[0] == "push" && len(args) == 2:
+		option := strings.Fields(args[1])
+		switch {
+		case len(option) >= 2 && len(option) <= 3 && option[0] == "route":
+			prefix, err := parseNetwork(option[1], optional(option, 2, "255.255.255.255"), true)
+			if err != nil {
+				// Routes may name hosts; leave those as written
+				return false, nil
+			}
+			c.PushRoutes = append(c.PushRoutes, prefix)
+		case len(option) == 3 && option[0] == "dhcp-option" && option[1] == "DNS":
+			addr, err := netip.ParseAddr(option[2])
+			if err != nil {
+				return false, fmt.Errorf("push dhcp-option DNS: %w", err)
+			}
+			c.DNS = append(c.DNS, addr)
+		default:
+			return false, nil
+		}
+
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Render writes the file in a stable order, managed directives first
+func (c *CCD) Render() []byte {
+	var buf bytes.Buffer
+
+	if c.Disable {
+		buf.WriteString("disable\n")
+	}
+	if c.IfconfigPush.IsValid() {
+		fmt.Fprintf(&buf, "ifconfig-push %s %s\n", c.IfconfigPush.Addr(), netmask(c.IfconfigPush.Bits()))
+	}
+	for _, route := range c.IRoutes {
+		fmt.Fprintf(&buf, "iroute %s %s\n", route.Addr(), netmask(route.Bits()))
+	}
+	for _, route := range c.PushRoutes {
+		fmt.Fprintf(&buf, "push \"route %s %s\"\n", route.Addr(), netmask(route.Bits()))
+	}
+	for _, dns := range c.DNS {
+		fmt.Fprintf(&buf, "push \"dhcp-option DNS %s\"\n", dns)
+	}
+	for _, line := range c.Other {
+		buf.WriteString(line + "\n")
+	}
+
+	return buf.Bytes()
+}
+
+// PoolLease is an address remembered by ifconfig-pool-persist
+type PoolLease struct {
+	CommonName string
+	Address    netip.Addr
+}
+
+// ParseIPPool parses an ifconfig-pool-persist file (ipp.txt) with lines like
+// "alice,10.8.0.2" or, since OpenVPN 2.6, "alice,10.8.0.2,fddd::1000".
+// Malformed lines are skipped, as OpenVPN does.
+func ParseIPPool(data []byte) []PoolLease {
+	leases := make([]PoolLease, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		leases = append(leases, PoolLease{CommonName: fields[0], Address: addr})
+	}
+
+	return leases
+}
+
+// splitDirective splits a config line into arguments, honouring quotes
+func splitDirective(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// parseNetwork combines an IPv4 address and a dotted netmask. Networks must
+// have no host bits set; ifconfig-push addresses keep them.
+func parseNetwork(address, mask string, network bool) (netip.Prefix, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil || !addr.Is4() {
+		return netip.Prefix{}, fmt.Errorf("invalid IPv4 address %q", address)
+	}
+
+	maskAddr, err := netip.ParseAddr(mask)
+	if err != nil || !maskAddr.Is4() {
+		return netip.Prefix{}, fmt.Errorf("invalid netmask %q", mask)
+	}
+	raw := maskAddr.As4()
+	m := uint32(raw[0])<<24 | uint32(raw[1])<<16 | uint32(raw[2])<<8 | uint32(raw[3])
+	ones := bits.LeadingZeros32(^m)
+	if ones < 32 && m<<ones != 0 {
+		return netip.Prefix{}, fmt.Errorf("non-contiguous netmask %q", mask)
+	}
+
+	prefix := netip.PrefixFrom(addr, ones)
+	if network && prefix.Masked() != prefix {
+		return netip.Prefix{}, fmt.Errorf("%s has host bits set for netmask %s", address, mask)
+	}
+	return prefix, nil
+}
+
+func netmask(ones int) string {
+	return net.IP(net.CIDRMask(ones, 32)).String()
+}
+
+func optional(args []string, i int, fallback string) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return fallback
+}
@@ -0,0 +1,64 @@
+package openvpn
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseAndRenderCCD(t *testing.T) {
+	data := "# office router\n" +
+		"ifconfig-push 10.8.0.50 255.255.255.0\n" +
+		"iroute 192.168.10.0 255.255.255.0\n" +
+		"push \"route 172.16.0.0 255.240.0.0\"\n" +
+		"push 'dhcp-option DNS 1.1.1.1'\n" +
+		"push \"route intranet.example 255.255.255.255\"\n" +
+		"disable\n" +
+		"push-reset\n"
+
+	ccd, err := ParseCCD([]byte(data))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if ccd.IfconfigPush != netip.MustParsePrefix("10.8.0.50/24") || !ccd.Disable {
+		t.Errorf("unexpected ifconfig-push or disable: %+v", ccd)
+	}
+	if len(ccd.IRoutes) != 1 || ccd.IRoutes[0] != netip.MustParsePrefix("192.168.10.0/24") {
+		t.Errorf("unexpected iroutes: %v", ccd.IRoutes)
+	}
+	if len(ccd.PushRoutes) != 1 || ccd.PushRoutes[0] != netip.MustParsePrefix("172.16.0.0/12") {
+		t.Errorf("unexpected push routes: %v", ccd.PushRoutes)
+	}
+	if len(ccd.DNS) != 1 || ccd.DNS[0] != netip.MustParseAddr("1.1.1.1") {
+		t.Errorf("unexpected dns: %v", ccd.DNS)
+	}
+	if len(ccd.Other) != 3 {
+		t.Errorf("expected comment, hostname route and push-reset to be kept, got %q", ccd.Other)
+	}
+
+	want := "disable\n" +
+		"ifconfig-push 10.8.0.50 255.255.255.0\n" +
+		"iroute 192.168.10.0 255.255.255.0\n" +
+		"push \"route 172.16.0.0 255.240.0.0\"\n" +
+		"push \"dhcp-option DNS 1.1.1.1\"\n" +
+		"# office router\n" +
+		"push \"route intranet.example 255.255.255.255\"\n" +
+		"push-reset\n"
+	if got := string(ccd.Render()); got != want {
+		t.Errorf("unexpected render:\n%s", got)
+	}
+
+	for _, bad := range []string{"ifconfig-push 10.8.0.50 255.0.255.0\n", "iroute 192.168.10.1 255.255.255.0\n", "push \"route 10.0.0.0\n"} {
+		if _, err := ParseCCD([]byte(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseIPPool(t *testing.T) {
+	leases := ParseIPPool([]byte("alice,10.8.0.2\nbob,10.8.0.3,fddd:1194:1194:1194::1001\nbroken\n,10.8.0.4\n"))
+
+	if len(leases) != 2 || leases[0].CommonName != "alice" || leases[1].Address != netip.MustParseAddr("10.8.0.3") {
+		t.Errorf("unexpected leases: %+v", leases)
+	}
+}
@@ -0,0 +1,338 @@
+package openvpn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrManagementClosed is returned for commands on a closed management connection
+var ErrManagementClosed = errors.New("management connection closed")
+
+// CommandError is an ERROR reply of the management interface
+type CommandError struct {
+	Command string
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("management command %q failed: %s", e.Command, e.Message)
+}
+
+// ByteCount is a >BYTECOUNT_CLI notification: cumulative bytes of one client
+// as seen by the server
+type ByteCount struct {
+	ClientID int64
+	BytesIn  uint64 // received from the client
+	BytesOut uint64 // sent to the client
+}
+
+// ClientEvent is a >CLIENT notification together with its ENV block
+type ClientEvent struct {
+	Event    string // CONNECT, REAUTH, ESTABLISHED, DISCONNECT or ADDRESS
+	ClientID int64
+	KeyID    int64 // -1 when the event carries none
+	Env      map[string]string
+}
+
+// ManagementHandlers receive real-time notifications. They are called from
+// the connection's reader goroutine, so they must return quickly and must not
+// issue commands on the same connection.
+type ManagementHandlers struct {
+	OnByteCount func(ByteCount)
+	OnClient    func(ClientEvent)
+}
+
+// Management is a connection to the OpenVPN management interface
+type Management struct {
+	conn     net.Conn
+	handlers ManagementHandlers
+
+	// Serializes commands; replies arrive in order on responses
+	cmdMu     sync.Mutex
+	responses chan string
+
+	done    chan struct{}
+	errMu   sync.Mutex
+	err     error
+	closing sync.Once
+}
+
+// DialManagement connects to the management interface on a tcp address or a
+// unix socket path and authenticates with password when one is set
+func DialManagement(ctx context.Context, network, address, password string, handlers ManagementHandlers) (*Management, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to OpenVPN management interface: %w", err)
+	}
+
+	m := &Management{
+		conn:      conn,
+		handlers:  handlers,
+		responses: make(chan string, 64),
+		done:      make(chan struct{}),
+	}
+	go m.readLoop()
+
+	if password != "" {
+		if _, err := m.exchange(ctx, password, false); err != nil {
+			m.Close()
+			return nil, fmt.Errorf("management authentication failed: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// Command sends a command and returns its reply. Single line replies are
+// returned without the SUCCESS: prefix; multi-line replies (status, version)
+// are returned without the terminating END line.
+func (m *Management) Command(ctx context.Context, command string) (string, error) {
+	if strings.ContainsAny(command, "\r\n") {
+		return "", fmt.Errorf("invalid management command %q", command)
+	}
+	return m.exchange(ctx, command, true)
+}
+
+func (m *Management) exchange(ctx context.Context, command string, multiline bool) (string, error) {
+	m.cmdMu.Lock()
+	defer m.cmdMu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		m.conn.SetWriteDeadline(deadline)
+	} else {
+		m.conn.SetWriteDeadline(time.Time{})
+	}
+
+	if _, err := m.conn.Write([]byte(command + "\n")); err != nil {
+		m.fail(err)
+		return "", fmt.Errorf("failed to send management command: %w", err)
+	}
+
+	var lines []string
+	for {
+		var line string
+		select {
+		case line = <-m.responses:
+		case <-m.done:
+			return "", m.Err()
+		case <-ctx.Done():
+			// The reply would be attributed to the next command; drop the connection
+			m.fail(ctx.Err())
+			return "", ctx.Err()
+		}
+
+		if len(lines) == 0 {
+			if message, ok := strings.CutPrefix(line, "SUCCESS:"); ok {
+				return strings.TrimSpace(message), nil
+			}
+			if message, ok := strings.CutPrefix(line, "ERROR:"); ok {
+				name, _, _ := strings.Cut(command, " ")
+				if !multiline {
+					name = "password"
+				}
+				return "", &CommandError{Command: name, Message: strings.TrimSpace(message)}
+			}
+		}
+
+		if line == "END" {
+			return strings.Join(lines, "\n"), nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// Status runs "status 3" and parses the reply
+func (m *Management) Status(ctx context.Context) (*Status, error) {
+	reply, err := m.Command(ctx, "status 3")
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatus(strings.NewReader(reply))
+}
+
+// SetByteCount asks the server to send >BYTECOUNT_CLI every interval; zero disables it
+func (m *Management) SetByteCount(ctx context.Context, interval time.Duration) error {
+	seconds := int(interval / time.Second)
+	if interval > 0 && seconds == 0 {
+		seconds = 1
+	}
+	_, err := m.Command(ctx, "bytecount "+strconv.Itoa(seconds))
+	return err
+}
+
+// Kill disconnects every session of a common name, or the session of a real address (ip:port)
+func (m *Management) Kill(ctx context.Context, target string) error {
+	if target == "" || strings.ContainsAny(target, " \t") {
+		return fmt.Errorf("invalid kill target %q", target)
+	}
+	_, err := m.Command(ctx, "kill "+target)
+	return err
+}
+
+// ClientKill disconnects a single session by client ID, optionally with a
+// message for the client (e.g. "HALT" or "RESTART")
+func (m *Management) ClientKill(ctx context.Context, clientID int64, message string) error {
+	command := "client-kill " + strconv.FormatInt(clientID, 10)
+	if message != "" {
+		if strings.ContainsAny(message, " \t") {
+			return fmt.Errorf("invalid client-kill message %q", message)
+		}
+		command += " " + message
+	}
+	_, err := m.Command(ctx, command)
+	return err
+}
+
+// Signal sends SIGHUP (reload the configuration), SIGUSR1 (soft restart),
+// SIGUSR2 (log statistics) or SIGTERM to the server
+func (m *Management) Signal(ctx context.Context, signal string) error {
+	switch signal {
+	case "SIGHUP", "SIGUSR1", "SIGUSR2", "SIGTERM":
+	default:
+		return fmt.Errorf("invalid signal %q", signal)
+	}
+	_, err := m.Command(ctx, "signal "+signal)
+	return err
+}
+
+// Done is closed when the connection is lost or closed
+func (m *Management) Done() <-chan struct{} {
+	return m.done
+}
+
+// Err reports why the connection ended, or nil while it is alive
+func (m *Management) Err() error {
+	m.errMu.Lock()
+	defer m.errMu.Unlock()
+	return m.err
+}
+
+func (m *Management) Close() error {
+	m.fail(ErrManagementClosed)
+	return nil
+}
+
+func (m *Management) fail(err error) {
+	m.closing.Do(func() {
+		m.errMu.Lock()
+		m.err = err
+		m.errMu.Unlock()
+		m.conn.Close()
+		close(m.done)
+	})
+}
+
+// readLoop splits the stream into command replies and notifications
+func (m *Management) readLoop() {
+	scanner := bufio.NewScanner(m.conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var pending *ClientEvent
+
+	for scanner.Scan() {
+		// The password prompt carries no newline and prefixes the reply
+		line := strings.TrimPrefix(strings.TrimRight(scanner.Text(), "\r"), "ENTER PASSWORD:")
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, ">") {
+			select {
+			case m.responses <- line:
+			case <-m.done:
+				return
+			}
+			continue
+		}
+
+		kind, payload, _ := strings.Cut(line[1:], ":")
+		switch kind {
+		case "BYTECOUNT_CLI":
+			if count, ok := parseByteCount(payload); ok && m.handlers.OnByteCount != nil {
+				m.handlers.OnByteCount(count)
+			}
+
+		case "CLIENT":
+			if env, ok := strings.CutPrefix(payload, "ENV,"); ok {
+				if pending == nil {
+					continue
+				}
+				if env == "END" {
+					m.dispatchClient(*pending)
+					pending = nil
+					continue
+				}
+				name, value, _ := strings.Cut(env, "=")
+				pending.Env[name] = value
+				continue
+			}
+
+			event := parseClientEvent(payload)
+			if event.Event == "ADDRESS" {
+				// Single line notification without an ENV block
+				m.dispatchClient(event)
+				continue
+			}
+			pending = &event
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = ErrManagementClosed
+	}
+	m.fail(err)
+}
+
+func (m *Management) dispatchClient(event ClientEvent) {
+	if m.handlers.OnClient != nil {
+		m.handlers.OnClient(event)
+	}
+}
+
+// parseByteCount parses "{CID},{BYTES_IN},{BYTES_OUT}"
+func parseByteCount(payload string) (ByteCount, bool) {
+	fields := strings.Split(payload, ",")
+	if len(fields) != 3 {
+		return ByteCount{}, false
+	}
+
+	clientID, err1 := strconv.ParseInt(fields[0], 10, 64)
+	bytesIn, err2 := strconv.ParseUint(fields[1], 10, 64)
+	bytesOut, err3 := strconv.ParseUint(fields[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return ByteCount{}, false
+	}
+
+	return ByteCount{ClientID: clientID, BytesIn: bytesIn, BytesOut: bytesOut}, true
+}
+
+// parseClientEvent parses "{EVENT},{CID}[,{KID}|,{ADDR},{PRI}]"
+func parseClientEvent(payload string) ClientEvent {
+	fields := strings.Split(payload, ",")
+
+	event := ClientEvent{
+		Event:    fields[0],
+		ClientID: parseID(field(fields, 1)),
+		KeyID:    -1,
+		Env:      make(map[string]string),
+	}
+
+	switch event.Event {
+	case "CONNECT", "REAUTH":
+		event.KeyID = parseID(field(fields, 2))
+	case "ADDRESS":
+		event.Env["address"] = field(fields, 2)
+		event.Env["primary"] = field(fields, 3)
+	}
+
+	return event
+}
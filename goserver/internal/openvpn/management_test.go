@@ -0,0 +1,117 @@
+package openvpn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeManagement serves a scripted management session on a local listener
+func fakeManagement(t *testing.T, serve func(r *bufio.Reader, w net.Conn)) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(bufio.NewReader(conn), conn)
+	}()
+
+	return listener
+}
+
+func expectLine(r *bufio.Reader, want string) bool {
+	line, err := r.ReadString('\n')
+	return err == nil && strings.TrimSpace(line) == want
+}
+
+func TestManagementCommandsAndNotifications(t *testing.T) {
+	listener := fakeManagement(t, func(r *bufio.Reader, w net.Conn) {
+		w.Write([]byte("ENTER PASSWORD:"))
+		if !expectLine(r, "secret") {
+			return
+		}
+		w.Write([]byte("SUCCESS: password is correct\n>INFO:OpenVPN Management Interface Version 5\n"))
+
+		if !expectLine(r, "status 3") {
+			return
+		}
+		w.Write([]byte(">BYTECOUNT_CLI:4,1000,2000\n" +
+			"TITLE\tOpenVPN 2.6.3\n" +
+			"TIME\t2024-05-10 12:00:00\t1715342400\n" +
+			"HEADER\tCLIENT_LIST\tCommon Name\tReal Address\tVirtual Address\tVirtual IPv6 Address\tBytes Received\tBytes Sent\tConnected Since\tConnected Since (time_t)\tUsername\tClient ID\tPeer ID\tData Channel Cipher\n" +
+			"CLIENT_LIST\talice\t203.0.113.10:50123\t10.8.0.2\t\t1000\t2000\t2024-05-10 11:00:00\t1715338800\tUNDEF\t4\t0\tAES-256-GCM\n" +
+			"END\n"))
+
+		if !expectLine(r, "client-kill 9") {
+			return
+		}
+		w.Write([]byte(">CLIENT:DISCONNECT,4\n>CLIENT:ENV,common_name=alice\n>CLIENT:ENV,bytes_received=1500\n>CLIENT:ENV,END\n"))
+		w.Write([]byte("ERROR: client-kill command failed\n"))
+
+		r.ReadString('\n')
+	})
+
+	counts := make(chan ByteCount, 1)
+	events := make(chan ClientEvent, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m, err := DialManagement(ctx, "tcp", listener.Addr().String(), "secret", ManagementHandlers{
+		OnByteCount: func(count ByteCount) { counts <- count },
+		OnClient:    func(event ClientEvent) { events <- event },
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer m.Close()
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Version != 3 || len(status.Clients) != 1 || status.Clients[0].ClientID != 4 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	if count := <-counts; count != (ByteCount{ClientID: 4, BytesIn: 1000, BytesOut: 2000}) {
+		t.Errorf("unexpected bytecount: %+v", count)
+	}
+
+	err = m.ClientKill(ctx, 9, "")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Command != "client-kill" {
+		t.Fatalf("expected a client-kill command error, got %v", err)
+	}
+
+	event := <-events
+	if event.Event != "DISCONNECT" || event.ClientID != 4 || event.Env["bytes_received"] != "1500" {
+		t.Errorf("unexpected client event: %+v", event)
+	}
+}
+
+func TestManagementRejectsInjectedCommands(t *testing.T) {
+	m := &Management{}
+	if _, err := m.Command(context.Background(), "kill alice\nsignal SIGTERM"); err == nil {
+		t.Fatal("expected multi-line commands to be rejected")
+	}
+	if err := m.Kill(context.Background(), "alice bob"); err == nil {
+		t.Fatal("expected kill targets with spaces to be rejected")
+	}
+	if err := m.Signal(context.Background(), "SIGKILL"); err == nil {
+		t.Fatal("expected unknown signals to be rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package openvpn
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ClientProfile holds the parts of an inline client configuration
+type ClientProfile struct {
+	Common   []byte // client-common.txt
+	CA       []byte
+	Cert     []byte // may carry the text dump easy-rsa puts before the PEM block
+	Key      []byte
+	TLSCrypt []byte // may carry the comment header of openvpn --genkey
+}
+
+// RenderInlineProfile renders a .ovpn file with the certificates and keys
+// inlined, the same layout as new_client() in openvpn.sh
+func RenderInlineProfile(p ClientProfile) ([]byte, error) {
+	cert := fromMarker(p.Cert, "-----BEGIN CERTIFICATE-----")
+	tlsCrypt := fromMarker(p.TLSCrypt, "-----BEGIN OpenVPN Static key")
+	if len(p.CA) == 0 || cert == nil || len(p.Key) == 0 || tlsCrypt == nil {
+		return nil, errors.New("client profile is missing ca, cert, key or tls-crypt material")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(withNewline(p.Common))
+	writeBlock(&buf, "ca", p.CA)
+	writeBlock(&buf, "cert", cert)
+	writeBlock(&buf, "key", p.Key)
+	writeBlock(&buf, "tls-crypt", tlsCrypt)
+
+	return buf.Bytes(), nil
+}
+
+func writeBlock(buf *bytes.Buffer, tag string, content []byte) {
+	buf.WriteString("<" + tag + ">\n")
+	buf.Write(withNewline(content))
+	buf.WriteString("</" + tag + ">\n")
+}
+
+// fromMarker returns data starting at marker, or nil when it is absent
+func fromMarker(data []byte, marker string) []byte {
+	i := bytes.Index(data, []byte(marker))
+	if i < 0 {
+		return nil
+	}
+	return data[i:]
+}
+
+func withNewline(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return append(data[:len(data):len(data)], '\n')
+	}
+	return data
+}
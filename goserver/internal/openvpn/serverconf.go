@@ -0,0 +1,330 @@
+package openvpn
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ServerCiphers are the ciphers accepted for the data channel
+var ServerCiphers = []string{"AES-128-GCM", "AES-192-GCM", "AES-256-GCM", "CHACHA20-POLY1305"}
+
+// AuthHookCommand is the auth-user-pass-verify script written when password
+// authentication is enabled; OpenVPN appends the path of the credentials file
+const AuthHookCommand = "/usr/local/bin/vpnhook openvpn-auth"
+
+// ConnectHookCommand and DisconnectHookCommand report sessions to goserver
+// when session hooks are enabled
+const (
+	ConnectHookCommand    = "/usr/local/bin/vpnhook openvpn-connect"
+	DisconnectHookCommand = "/usr/local/bin/vpnhook openvpn-disconnect"
+)
+
+// hostnamePattern matches DNS names usable as the remote of client profiles
+var hostnamePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// ServerConfig holds the settings create_server_config and
+// create_client_common in openvpn.sh choose at install time. Everything else
+// in server.conf and client-common.txt is fixed by the templates.
+type ServerConfig struct {
+	Local         string   `json:"local,omitempty"` // listen address, empty for all
+	PublicAddress string   `json:"public_address"`  // remote of client profiles, IP or hostname
+	Port          int      `json:"port"`
+	Protocol      string   `json:"protocol"` // udp or tcp
+	Subnet        string   `json:"subnet"`   // IPv4 client subnet, e.g. 10.8.0.0/24
+	IPv6          bool     `json:"ipv6"`     // route IPv6 through the tunnel instead of blocking it
+	DNS           []string `json:"dns"`
+	Cipher        string   `json:"cipher"`
+	Group         string   `json:"group"` // nogroup on Debian and Ubuntu, nobody elsewhere
+	// PasswordAuth additionally checks usernames and passwords against the
+	// goserver user store through AuthHookCommand
+	PasswordAuth bool `json:"password_auth"`
+	// SessionHooks reports connects and disconnects with exact totals and
+	// lets goserver reject users who are over quota
+	SessionHooks bool `json:"session_hooks"`
+}
+
+// ParseServerConfig reads the settings back from server.conf and client-common.txt
+func ParseServerConfig(serverConf, clientCommon []byte) (*ServerConfig, error) {
+	config := &ServerConfig{Protocol: "udp", Port: 1194, DNS: make([]string, 0)}
+
+	err := eachDirective(serverConf, func(args []string) error {
+		switch {
+		case args[0] == "local" && len(args) == 2:
+			config.Local = args[1]
+		case args[0] == "port" && len(args) == 2:
+			port, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid port %q", args[1])
+			}
+			config.Port = port
+		case args[0] == "proto" && len(args) == 2:
+			config.Protocol = args[1]
+		case args[0] == "server" && len(args) >= 3:
+			subnet, err := parseNetwork(args[1], args[2], true)
+			if err != nil {
+				return fmt.Errorf("server: %w", err)
+			}
+			config.Subnet = subnet.String()
+		case args[0] == "server-ipv6":
+			config.IPv6 = true
+		case args[0] == "push" && len(args) == 2:
+			option := strings.Fields(args[1])
+			if len(option) == 3 && option[0] == "dhcp-option" && option[1] == "DNS" {
+				config.DNS = append(config.DNS, option[2])
+			}
+		case args[0] == "cipher" && len(args) == 2:
+			config.Cipher = args[1]
+		case args[0] == "group" && len(args) == 2:
+			config.Group = args[1]
+		case args[0] == "auth-user-pass-verify":
+			config.PasswordAuth = true
+		case args[0] == "client-connect" || args[0] == "client-disconnect":
+			config.SessionHooks = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("server.conf: %w", err)
+	}
+
+	err = eachDirective(clientCommon, func(args []string) error {
+		if args[0] == "remote" && len(args) >= 2 && config.PublicAddress == "" {
+			config.PublicAddress = args[1]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client-common.txt: %w", err)
+	}
+
+	return config, nil
+}
+
+// Validate rejects settings OpenVPN would fail on or that break clients
+func (c *ServerConfig) Validate() error {
+	var errs []error
+
+	if c.Local != "" {
+		if _, err := netip.ParseAddr(c.Local); err != nil {
+			errs = append(errs, fmt.Errorf("local %q is not an IP address", c.Local))
+		}
+	}
+	if !validRemote(c.PublicAddress) {
+		errs = append(errs, fmt.Errorf("public_address %q is not an IP address or hostname", c.PublicAddress))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	if c.Protocol != "udp" && c.Protocol != "tcp" {
+		errs = append(errs, fmt.Errorf("protocol %q is not udp or tcp", c.Protocol))
+	}
+	if _, err := c.SubnetPrefix(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.DNS) == 0 {
+		errs = append(errs, errors.New("at least one dns server is required"))
+	}
+	for _, dns := range c.DNS {
+		if _, err := netip.ParseAddr(dns); err != nil {
+			errs = append(errs, fmt.Errorf("dns %q is not an IP address", dns))
+		}
+	}
+	if !slices.Contains(ServerCiphers, c.Cipher) {
+		errs = append(errs, fmt.Errorf("cipher %q is not one of %s", c.Cipher, strings.Join(ServerCiphers, ", ")))
+	}
+	if c.Group != "nobody" && c.Group != "nogroup" {
+		errs = append(errs, fmt.Errorf("group %q is not nobody or nogroup", c.Group))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SubnetPrefix parses the client subnet: a private IPv4 network from /16 to /29
+func (c *ServerConfig) SubnetPrefix() (netip.Prefix, error) {
+	subnet, err := netip.ParsePrefix(c.Subnet)
+	if err != nil || !subnet.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("subnet %q is not an IPv4 network", c.Subnet)
+	}
+	if subnet.Masked() != subnet {
+		return netip.Prefix{}, fmt.Errorf("subnet %s has host bits set, use %s", subnet, subnet.Masked())
+	}
+	if subnet.Bits() < 16 || subnet.Bits() > 29 {
+		return netip.Prefix{}, fmt.Errorf("subnet %s must be between /16 and /29", subnet)
+	}
+	if !subnet.Addr().IsPrivate() {
+		return netip.Prefix{}, fmt.Errorf("subnet %s is not a private network", subnet)
+	}
+	return subnet, nil
+}
+
+// renderedDirectives are the server.conf directives RenderServerConfig writes
+var renderedDirectives = []string{
+	"local", "port", "proto", "dev", "ca", "cert", "key", "dh", "auth", "tls-crypt", "topology",
+	"server", "server-ipv6", "ifconfig-pool-persist", "client-config-dir", "keepalive", "cipher",
+	"user", "group", "persist-key", "persist-tun", "verb", "crl-verify", "script-security",
+	"auth-user-pass-verify", "client-connect", "client-disconnect", "status", "status-version",
+	"management", "explicit-exit-notify",
+}
+
+// renderedPushOptions are the pushed options RenderServerConfig writes
+var renderedPushOptions = []string{"block-ipv6", "ifconfig-ipv6", "redirect-gateway", "dhcp-option DNS", "block-outside-dns"}
+
+// RenderServerConfig renders server.conf like create_server_config in openvpn.sh.
+// Comments, inline files and directives of current that are not modelled by
+// ServerConfig are appended verbatim, so that hand-made additions survive.
+func RenderServerConfig(c *ServerConfig, current []byte) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	subnet, _ := c.SubnetPrefix()
+
+	var buf bytes.Buffer
+	if c.Local != "" {
+		fmt.Fprintf(&buf, "local %s\n", c.Local)
+	}
+	fmt.Fprintf(&buf, "port %d\nproto %s\n", c.Port, c.Protocol)
+	buf.WriteString("dev tun\nca ca.crt\ncert server.crt\nkey server.key\ndh dh.pem\nauth SHA256\ntls-crypt tc.key\ntopology subnet\n")
+	fmt.Fprintf(&buf, "server %s %s\n", subnet.Addr(), netmask(subnet.Bits()))
+	if c.IPv6 {
+		buf.WriteString("server-ipv6 fddd:1194:1194:1194::/64\n")
+	} else {
+		buf.WriteString("push \"block-ipv6\"\n")
+		buf.WriteString("push \"ifconfig-ipv6 fddd:1194:1194:1194::2/64 fddd:1194:1194:1194::1\"\n")
+	}
+	buf.WriteString("push \"redirect-gateway def1 ipv6 bypass-dhcp\"\n")
+	buf.WriteString("ifconfig-pool-persist ipp.txt\n")
+	buf.WriteString("client-config-dir ccd\n")
+	for _, dns := range c.DNS {
+		fmt.Fprintf(&buf, "push \"dhcp-option DNS %s\"\n", dns)
+	}
+	buf.WriteString("push \"block-outside-dns\"\n")
+	buf.WriteString("keepalive 10 120\n")
+	fmt.Fprintf(&buf, "cipher %s\n", c.Cipher)
+	fmt.Fprintf(&buf, "user nobody\ngroup %s\n", c.Group)
+	buf.WriteString("persist-key\npersist-tun\nverb 3\ncrl-verify crl.pem\n")
+	if c.PasswordAuth || c.SessionHooks {
+		buf.WriteString("script-security 2\n")
+	}
+	if c.PasswordAuth {
+		fmt.Fprintf(&buf, "auth-user-pass-verify \"%s\" via-file\n", AuthHookCommand)
+	}
+	if c.SessionHooks {
+		fmt.Fprintf(&buf, "client-connect \"%s\"\n", ConnectHookCommand)
+		fmt.Fprintf(&buf, "client-disconnect \"%s\"\n", DisconnectHookCommand)
+	}
+	buf.WriteString("status /var/log/openvpn/status.log\nstatus-version 2\n")
+	buf.WriteString("management /etc/openvpn/server/management.sock unix\n")
+	if c.Protocol == "udp" {
+		buf.WriteString("explicit-exit-notify\n")
+	}
+	for _, line := range unmodelledLines(current) {
+		buf.WriteString(line + "\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// unmodelledLines returns the lines of server.conf that RenderServerConfig
+// does not produce itself, in their original order
+func unmodelledLines(data []byte) []string {
+	var lines []string
+	var block string // closing tag of the inline file being copied
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		text := strings.TrimSpace(line)
+
+		switch {
+		case block != "":
+			lines = append(lines, line)
+			if text == block {
+				block = ""
+			}
+			continue
+		case text == "":
+			continue
+		case text[0] == '#' || text[0] == ';':
+			lines = append(lines, line)
+			continue
+		case strings.HasPrefix(text, "<") && strings.HasSuffix(text, ">") && !strings.HasPrefix(text, "</"):
+			lines = append(lines, line)
+			block = "</" + text[1:]
+			continue
+		}
+
+		args, err := splitDirective(text)
+		if err != nil || !renderedDirective(args) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func renderedDirective(args []string) bool {
+	if args[0] != "push" {
+		return slices.Contains(renderedDirectives, args[0])
+	}
+	if len(args) != 2 {
+		return false
+	}
+	for _, option := range renderedPushOptions {
+		if args[1] == option || strings.HasPrefix(args[1], option+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderClientCommon renders client-common.txt like create_client_common in openvpn.sh
+func RenderClientCommon(c *ServerConfig) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("client\ndev tun\n")
+	fmt.Fprintf(&buf, "proto %s\nremote %s %d\n", c.Protocol, c.PublicAddress, c.Port)
+	buf.WriteString("resolv-retry infinite\nnobind\npersist-key\npersist-tun\nremote-cert-tls server\nauth SHA256\n")
+	fmt.Fprintf(&buf, "cipher %s\n", c.Cipher)
+	if c.PasswordAuth {
+		buf.WriteString("auth-user-pass\n")
+	}
+	buf.WriteString("ignore-unknown-option block-outside-dns block-ipv6\nverb 3\n")
+
+	return buf.Bytes(), nil
+}
+
+// eachDirective calls fn with the arguments of every directive line
+func eachDirective(data []byte, fn func(args []string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		args, err := splitDirective(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(args); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func validRemote(remote string) bool {
+	if _, err := netip.ParseAddr(remote); err == nil {
+		return true
+	}
+	return len(remote) <= 253 && hostnamePattern.MatchString(remote)
+}
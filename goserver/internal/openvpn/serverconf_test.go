@@ -0,0 +1,159 @@
+package openvpn
+
+import (
+	"strings"
+	"testing"
+)
+
+// serverConfFixture is what create_server_config in openvpn.sh writes
+const serverConfFixture = `local 203.0.113.10
+port 1194
+proto udp
+dev tun
+ca ca.crt
+cert server.crt
+key server.key
+dh dh.pem
+auth SHA256
+tls-crypt tc.key
+topology subnet
+server 10.8.0.0 255.255.255.0
+push "block-ipv6"
+push "ifconfig-ipv6 fddd:1194:1194:1194::2/64 fddd:1194:1194:1194::1"
+push "redirect-gateway def1 ipv6 bypass-dhcp"
+ifconfig-pool-persist ipp.txt
+client-config-dir ccd
+push "dhcp-option DNS 8.8.8.8"
+push "dhcp-option DNS 8.8.4.4"
+push "block-outside-dns"
+keepalive 10 120
+cipher AES-128-GCM
+user nobody
+group nogroup
+persist-key
+persist-tun
+verb 3
+crl-verify crl.pem
+status /var/log/openvpn/status.log
+status-version 2
+management /etc/openvpn/server/management.sock unix
+explicit-exit-notify
+`
+
+const clientCommonFixture = `client
+dev tun
+proto udp
+remote 203.0.113.10 1194
+resolv-retry infinite
+nobind
+persist-key
+persist-tun
+remote-cert-tls server
+auth SHA256
+cipher AES-128-GCM
+ignore-unknown-option block-outside-dns block-ipv6
+verb 3
+`
+
+func TestServerConfigRoundTrip(t *testing.T) {
+	config, err := ParseServerConfig([]byte(serverConfFixture), []byte(clientCommonFixture))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if config.Port != 1194 || config.Protocol != "udp" || config.Subnet != "10.8.0.0/24" || config.IPv6 ||
+		config.Cipher != "AES-128-GCM" || config.Group != "nogroup" || config.PublicAddress != "203.0.113.10" ||
+		len(config.DNS) != 2 {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+
+	serverConf, err := RenderServerConfig(config, nil)
+	if err != nil {
+		t.Fatalf("render server.conf: %v", err)
+	}
+	if string(serverConf) != serverConfFixture {
+		t.Errorf("server.conf differs from openvpn.sh:\n%s", serverConf)
+	}
+
+	// Directives that are not modelled survive a rewrite
+	custom := "# site routes\npush \"route 192.168.50.0 255.255.255.0\"\nduplicate-cn\n<extra-certs>\n-----BEGIN CERTIFICATE-----\n</extra-certs>\n"
+	rewritten, err := RenderServerConfig(config, []byte(serverConfFixture+custom))
+	if err != nil || string(rewritten) != serverConfFixture+custom {
+		t.Errorf("unmodelled directives were not kept: %v\n%s", err, rewritten)
+	}
+
+	clientCommon, err := RenderClientCommon(config)
+	if err != nil {
+		t.Fatalf("render client-common.txt: %v", err)
+	}
+	if string(clientCommon) != clientCommonFixture {
+		t.Errorf("client-common.txt differs from openvpn.sh:\n%s", clientCommon)
+	}
+
+	config.Protocol = "tcp"
+	config.IPv6 = true
+	serverConf, _ = RenderServerConfig(config, nil)
+	if strings.Contains(string(serverConf), "explicit-exit-notify") || !strings.Contains(string(serverConf), "server-ipv6 fddd:1194:1194:1194::/64\n") {
+		t.Errorf("unexpected tcp/IPv6 server.conf:\n%s", serverConf)
+	}
+
+	config.PasswordAuth = true
+	serverConf, _ = RenderServerConfig(config, nil)
+	clientCommon, _ = RenderClientCommon(config)
+	if !strings.Contains(string(serverConf), "script-security 2\nauth-user-pass-verify \""+AuthHookCommand+"\" via-file\n") ||
+		!strings.Contains(string(clientCommon), "\nauth-user-pass\n") {
+		t.Errorf("password auth not rendered:\n%s\n%s", serverConf, clientCommon)
+	}
+	reparsed, err := ParseServerConfig(serverConf, clientCommon)
+	if err != nil || !reparsed.PasswordAuth || reparsed.SessionHooks {
+		t.Errorf("password auth not parsed back: %+v, %v", reparsed, err)
+	}
+
+	config.PasswordAuth = false
+	config.SessionHooks = true
+	serverConf, _ = RenderServerConfig(config, nil)
+	if strings.Count(string(serverConf), "script-security 2\n") != 1 ||
+		!strings.Contains(string(serverConf), "client-disconnect \""+DisconnectHookCommand+"\"\n") {
+		t.Errorf("session hooks not rendered:\n%s", serverConf)
+	}
+	if reparsed, _ := ParseServerConfig(serverConf, clientCommon); !reparsed.SessionHooks {
+		t.Errorf("session hooks not parsed back: %+v", reparsed)
+	}
+}
+
+func TestServerConfigValidate(t *testing.T) {
+	valid := func() *ServerConfig {
+		return &ServerConfig{
+			PublicAddress: "vpn.example.com",
+			Port:          443,
+			Protocol:      "tcp",
+			Subnet:        "10.9.0.0/24",
+			DNS:           []string{"1.1.1.1"},
+			Cipher:        "AES-256-GCM",
+			Group:         "nobody",
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	cases := map[string]func(*ServerConfig){
+		"port":          func(c *ServerConfig) { c.Port = 70000 },
+		"protocol":      func(c *ServerConfig) { c.Protocol = "sctp" },
+		"public subnet": func(c *ServerConfig) { c.Subnet = "8.8.8.0/24" },
+		"host bits":     func(c *ServerConfig) { c.Subnet = "10.9.0.1/24" },
+		"tiny subnet":   func(c *ServerConfig) { c.Subnet = "10.9.0.0/30" },
+		"cipher":        func(c *ServerConfig) { c.Cipher = "BF-CBC" },
+		"dns":           func(c *ServerConfig) { c.DNS = []string{"resolver"} },
+		"no dns":        func(c *ServerConfig) { c.DNS = nil },
+		"remote":        func(c *ServerConfig) { c.PublicAddress = "vpn example" },
+		"group":         func(c *ServerConfig) { c.Group = "root" },
+	}
+	for name, mutate := range cases {
+		config := valid()
+		mutate(config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// Package openvpn parses OpenVPN server artefacts and talks to its management interface.
+package openvpn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is a parsed OpenVPN server status file
+type Status struct {
+	Version     int               `json:"version"`           // status-version: 1, 2 or 3
+	Title       string            `json:"title"`             // version banner (versions 2 and 3)
+	UpdatedAt   time.Time         `json:"updated_at"`        // time the file was written
+	Clients     []Client          `json:"clients"`           // CLIENT_LIST
+	Routes      []Route           `json:"routes"`            // ROUTING_TABLE
+	GlobalStats map[string]string `json:"global_stats"`      // GLOBAL_STATS, e.g. "Max bcast/mcast queue length"
+	Skipped     []string          `json:"skipped,omitempty"` // malformed CLIENT_LIST entries left out of Clients
+}
+
+// Client is a connected client from the CLIENT_LIST section
+type Client struct {
+	CommonName         string    `json:"common_name"`
+	RealAddress        string    `json:"real_address"`
+	VirtualAddress     string    `json:"virtual_address"`
+	VirtualIPv6Address string    `json:"virtual_ipv6_address"`
+	BytesReceived      uint64    `json:"bytes_received"`
+	BytesSent          uint64    `json:"bytes_sent"`
+	ConnectedSince     time.Time `json:"connected_since"`
+	Username           string    `json:"username"`
+	ClientID           int64     `json:"client_id"` // -1 when the server does not report it
+	PeerID             int64     `json:"peer_id"`   // -1 when the server does not report it
+	DataChannelCipher  string    `json:"data_channel_cipher"`
+}
+
+// Route is an entry of the ROUTING_TABLE section
+type Route struct {
+	VirtualAddress string    `json:"virtual_address"`
+	CommonName     string    `json:"common_name"`
+	RealAddress    string    `json:"real_address"`
+	LastRef        time.Time `json:"last_ref"`
+}
+
+// Column names as printed in HEADER lines (versions 2 and 3) and in the
+// column header lines of version 1
+const (
+	colCommonName        = "Common Name"
+	colRealAddress       = "Real Address"
+	colVirtualAddress    = "Virtual Address"
+	colVirtualIPv6       = "Virtual IPv6 Address"
+	colBytesReceived     = "Bytes Received"
+	colBytesSent         = "Bytes Sent"
+	colConnectedSince    = "Connected Since"
+	colConnectedSinceT   = "Connected Since (time_t)"
+	colUsername          = "Username"
+	colClientID          = "Client ID"
+	colPeerID            = "Peer ID"
+	colDataChannelCipher = "Data Channel Cipher"
+	colLastRef           = "Last Ref"
+	colLastRefT          = "Last Ref (time_t)"
+)
+
+// Column layouts assumed when a file carries no HEADER line
+var (
+	defaultClientColumns = []string{colCommonName, colRealAddress, colVirtualAddress, colVirtualIPv6, colBytesReceived, colBytesSent, colConnectedSince, colConnectedSinceT, colUsername, colClientID, colPeerID, colDataChannelCipher}
+	defaultRouteColumns  = []string{colVirtualAddress, colCommonName, colRealAddress, colLastRef, colLastRefT}
+)
+
+// Timestamp layouts used by OpenVPN for human readable times
+var timeLayouts = []string{
+	"Mon Jan _2 15:04:05 2006",
+	"2006-01-02 15:04:05",
+}
+
+// ParseStatus parses a status file written with status-version 1, 2 or 3.
+// The version is detected from the content.
+func ParseStatus(r io.Reader) (*Status, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading OpenVPN status: %w", err)
+	}
+
+	status := &Status{
+		Clients:     make([]Client, 0),
+		Routes:      make([]Route, 0),
+		GlobalStats: make(map[string]string),
+	}
+
+	if len(lines) == 0 {
+		return status, nil
+	}
+
+	switch {
+	case lines[0] == "OpenVPN CLIENT LIST":
+		status.Version = 1
+		parseV1(status, lines[1:])
+	case strings.Contains(lines[0], "\t"):
+		status.Version = 3
+		parseTagged(status, lines, "\t")
+	default:
+		status.Version = 2
+		parseTagged(status, lines, ",")
+	}
+
+	fillVirtualAddresses(status)
+	return status, nil
+}
+
+// parseTagged parses versions 2 (comma separated) and 3 (tab separated),
+// where every line starts with its record type
+func parseTagged(status *Status, lines []string, sep string) {
+	clientColumns := columnIndex(defaultClientColumns)
+	routeColumns := columnIndex(defaultRouteColumns)
+
+	for _, line := range lines {
+		fields := strings.Split(line, sep)
+
+		switch fields[0] {
+		case "TITLE":
+			status.Title = strings.Join(fields[1:], sep)
+		case "TIME":
+			status.UpdatedAt = parseTime(field(fields[1:], 0), field(fields[1:], 1))
+		case "HEADER":
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[1] {
+			case "CLIENT_LIST":
+				clientColumns = columnIndex(fields[2:])
+			case "ROUTING_TABLE":
+				routeColumns = columnIndex(fields[2:])
+			}
+		case "CLIENT_LIST":
+			status.addClient(parseClient(fields[1:], clientColumns))
+		case "ROUTING_TABLE":
+			status.Routes = append(status.Routes, parseRoute(fields[1:], routeColumns))
+		case "GLOBAL_STATS":
+			if len(fields) >= 3 {
+				status.GlobalStats[fields[1]] = fields[2]
+			}
+		case "END":
+			return
+		}
+	}
+}
+
+// parseV1 parses the sectioned, untagged layout of status-version 1
+func parseV1(status *Status, lines []string) {
+	const (
+		sectionNone = iota
+		sectionClients
+		sectionRoutes
+		sectionStats
+	)
+
+	section := sectionNone
+	var columns map[string]int
+
+	for _, line := range lines {
+		switch {
+		case line == "ROUTING TABLE":
+			section, columns = sectionRoutes, nil
+			continue
+		case line == "GLOBAL STATS":
+			section = sectionStats
+			continue
+		case line == "END":
+			return
+		case strings.HasPrefix(line, "Updated,"):
+			status.UpdatedAt = parseTime(strings.TrimPrefix(line, "Updated,"), "")
+			section, columns = sectionClients, nil
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+
+		switch section {
+		case sectionClients:
+			if columns == nil {
+				columns = columnIndex(fields)
+				continue
+			}
+			status.addClient(parseClient(fields, columns))
+		case sectionRoutes:
+			if columns == nil {
+				columns = columnIndex(fields)
+				continue
+			}
+			status.Routes = append(status.Routes, parseRoute(fields, columns))
+		case sectionStats:
+			if len(fields) >= 2 {
+				status.GlobalStats[fields[0]] = fields[1]
+			}
+		}
+	}
+}
+
+// addClient records a parsed CLIENT_LIST entry. A malformed entry is skipped
+// so that one bad line does not hide every other client.
+func (s *Status) addClient(client Client, err error) {
+	if err != nil {
+		s.Skipped = append(s.Skipped, err.Error())
+		return
+	}
+	s.Clients = append(s.Clients, client)
+}
+
+func parseClient(fields []string, columns map[string]int) (Client, error) {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return field(fields, i)
+		}
+		return ""
+	}
+
+	commonName := get(colCommonName)
+	if commonName == "" {
+		return Client{}, errors.New("CLIENT_LIST entry without common name")
+	}
+
+	bytesReceived, err := strconv.ParseUint(get(colBytesReceived), 10, 64)
+	if err != nil {
+		return Client{}, fmt.Errorf("invalid bytes received for %s: %w", commonName, err)
+	}
+	bytesSent, err := strconv.ParseUint(get(colBytesSent), 10, 64)
+	if err != nil {
+		return Client{}, fmt.Errorf("invalid bytes sent for %s: %w", commonName, err)
+	}
+
+	username := get(colUsername)
+	if username == "UNDEF" {
+		username = ""
+	}
+
+	return Client{
+		CommonName:         commonName,
+		RealAddress:        get(colRealAddress),
+		VirtualAddress:     get(colVirtualAddress),
+		VirtualIPv6Address: get(colVirtualIPv6),
+		BytesReceived:      bytesReceived,
+		BytesSent:          bytesSent,
+		ConnectedSince:     parseTime(get(colConnectedSince), get(colConnectedSinceT)),
+		Username:           username,
+		ClientID:           parseID(get(colClientID)),
+		PeerID:             parseID(get(colPeerID)),
+		DataChannelCipher:  get(colDataChannelCipher),
+	}, nil
+}
+
+func parseRoute(fields []string, columns map[string]int) Route {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return field(fields, i)
+		}
+		return ""
+	}
+
+	return Route{
+		VirtualAddress: get(colVirtualAddress),
+		CommonName:     get(colCommonName),
+		RealAddress:    get(colRealAddress),
+		LastRef:        parseTime(get(colLastRef), get(colLastRefT)),
+	}
+}
+
+// fillVirtualAddresses takes the tunnel address of clients that don't list
+// one (status-version 1) from the routing table. Learned iroute subnets
+// (with a prefix length) and MAC addresses (tap) are skipped.
+func fillVirtualAddresses(status *Status) {
+	byRealAddress := make(map[string]string)
+	for _, route := range status.Routes {
+		address := route.VirtualAddress
+		if strings.ContainsAny(address, "/") || strings.Count(address, ":") == 5 {
+			continue
+		}
+		key := route.CommonName + "|" + route.RealAddress
+		if _, ok := byRealAddress[key]; !ok {
+			byRealAddress[key] = address
+		}
+	}
+
+	for i := range status.Clients {
+		client := &status.Clients[i]
+		if client.VirtualAddress == "" {
+			client.VirtualAddress = byRealAddress[client.CommonName+"|"+client.RealAddress]
+		}
+	}
+}
+
+// parseTime prefers the unix timestamp column and falls back to the human readable one
+func parseTime(human, unix string) time.Time {
+	if unix != "" {
+		if secs, err := strconv.ParseInt(unix, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC()
+		}
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, human, time.Local); err == nil {
+			return t.UTC()
+		}
+	}
+
+	return time.Time{}
+}
+
+func parseID(value string) int64 {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+func columnIndex(names []string) map[string]int {
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		columns[strings.TrimSpace(name)] = i
+	}
+	return columns
+}
+
+func field(fields []string, i int) string {
+	if i < len(fields) {
+		return strings.TrimSpace(fields[i])
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package openvpn
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const statusV1 = `OpenVPN CLIENT LIST
+Updated,Thu Jun 18 08:12:15 2015
+Common Name,Real Address,Bytes Received,Bytes Sent,Connected Since
+alice,203.0.113.10:50123,1000,2000,Thu Jun 18 04:23:03 2015
+bob,198.51.100.7:1194,300,400,Thu Jun 18 04:24:03 2015
+ROUTING TABLE
+Virtual Address,Common Name,Real Address,Last Ref
+10.8.0.0/24,alice,203.0.113.10:50123,Thu Jun 18 08:12:09 2015
+10.8.0.2,alice,203.0.113.10:50123,Thu Jun 18 08:12:09 2015
+10.8.0.3,bob,198.51.100.7:1194,Thu Jun 18 08:12:10 2015
+GLOBAL STATS
+Max bcast/mcast queue length,0
+END
+`
+
+const statusV2 = `TITLE,OpenVPN 2.6.3 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4]
+TIME,2024-05-10 12:00:00,1715342400
+HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID,Data Channel Cipher
+CLIENT_LIST,alice,203.0.113.10:50123,10.8.0.2,,1000,2000,2024-05-10 11:00:00,1715338800,UNDEF,4,0,AES-256-GCM
+CLIENT_LIST,alice,198.51.100.7:1194,10.8.0.3,fddd:1194:1194:1194::1000,300,400,2024-05-10 11:30:00,1715340600,alice,7,1,AES-256-GCM
+HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)
+ROUTING_TABLE,10.8.0.2,alice,203.0.113.10:50123,2024-05-10 11:59:58,1715342398
+GLOBAL_STATS,Max bcast/mcast queue length,2
+GLOBAL_STATS,dco_enabled,0
+END
+`
+
+func TestParseStatusV1(t *testing.T) {
+	status, err := ParseStatus(strings.NewReader(statusV1))
+	if err != nil {
+		t.Fatalf("ParseStatus: %v", err)
+	}
+
+	if status.Version != 1 {
+		t.Errorf("version = %d, want 1", status.Version)
+	}
+	if len(status.Clients) != 2 || len(status.Routes) != 3 {
+		t.Fatalf("got %d clients and %d routes, want 2 and 3", len(status.Clients), len(status.Routes))
+	}
+
+	alice := status.Clients[0]
+	if alice.CommonName != "alice" || alice.BytesReceived != 1000 || alice.BytesSent != 2000 {
+		t.Errorf("unexpected client: %+v", alice)
+	}
+	if alice.VirtualAddress != "10.8.0.2" {
+		t.Errorf("virtual address = %q, want 10.8.0.2 from the routing table", alice.VirtualAddress)
+	}
+	if alice.ClientID != -1 || alice.PeerID != -1 {
+		t.Errorf("ids = %d/%d, want -1/-1", alice.ClientID, alice.PeerID)
+	}
+	if alice.ConnectedSince.IsZero() {
+		t.Error("connected since was not parsed")
+	}
+	if status.GlobalStats["Max bcast/mcast queue length"] != "0" {
+		t.Errorf("global stats = %v", status.GlobalStats)
+	}
+}
+
+func TestParseStatusV2AndV3(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		version int
+	}{
+		{"v2", statusV2, 2},
+		{"v3", strings.ReplaceAll(statusV2, ",", "\t"), 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, err := ParseStatus(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("ParseStatus: %v", err)
+			}
+
+			if status.Version != tc.version {
+				t.Errorf("version = %d, want %d", status.Version, tc.version)
+			}
+			if !strings.HasPrefix(status.Title, "OpenVPN 2.6.3") {
+				t.Errorf("title = %q", status.Title)
+			}
+			if want := time.Unix(1715342400, 0).UTC(); !status.UpdatedAt.Equal(want) {
+				t.Errorf("updated at = %v, want %v", status.UpdatedAt, want)
+			}
+			if len(status.Clients) != 2 {
+				t.Fatalf("got %d clients, want 2", len(status.Clients))
+			}
+
+			first, second := status.Clients[0], status.Clients[1]
+			if first.Username != "" {
+				t.Errorf("UNDEF username should be blank, got %q", first.Username)
+			}
+			if first.ClientID != 4 || first.PeerID != 0 || second.ClientID != 7 || second.PeerID != 1 {
+				t.Errorf("unexpected ids: %+v %+v", first, second)
+			}
+			if second.VirtualIPv6Address != "fddd:1194:1194:1194::1000" || second.DataChannelCipher != "AES-256-GCM" {
+				t.Errorf("unexpected client: %+v", second)
+			}
+			if want := time.Unix(1715340600, 0).UTC(); !second.ConnectedSince.Equal(want) {
+				t.Errorf("connected since = %v, want %v", second.ConnectedSince, want)
+			}
+			if len(status.Routes) != 1 || status.Routes[0].CommonName != "alice" {
+				t.Errorf("unexpected routes: %+v", status.Routes)
+			}
+			if status.GlobalStats["dco_enabled"] != "0" {
+				t.Errorf("global stats = %v", status.GlobalStats)
+			}
+		})
+	}
+}
+
+func TestParseStatusEmptyAndInvalid(t *testing.T) {
+	status, err := ParseStatus(strings.NewReader(""))
+	if err != nil || len(status.Clients) != 0 {
+		t.Fatalf("empty input: %v %+v", err, status)
+	}
+
+	status, err = ParseStatus(strings.NewReader("CLIENT_LIST,alice,1.2.3.4:1,10.8.0.2,,abc,1,x,0,UNDEF,0,0,none\n" +
+		"CLIENT_LIST,bob,1.2.3.5:1,10.8.0.3,,10,20,x,0,UNDEF,1,0,none\n"))
+	if err != nil {
+		t.Fatalf("malformed entry: %v", err)
+	}
+	if len(status.Clients) != 1 || status.Clients[0].CommonName != "bob" {
+		t.Errorf("clients = %+v, want only bob", status.Clients)
+	}
+	if len(status.Skipped) != 1 || !strings.Contains(status.Skipped[0], "alice") {
+		t.Errorf("skipped = %v", status.Skipped)
+	}
+}
@@ -0,0 +1,143 @@
+package pki
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Index entry statuses, as written by OpenSSL's ca command
+const (
+	StatusValid   = "V"
+	StatusRevoked = "R"
+	StatusExpired = "E"
+)
+
+// IndexEntry is one line of the OpenSSL CA database (pki/index.txt):
+//
+//	V	350101000000Z		0A1B...	unknown	/CN=alice
+//	R	350101000000Z	250301120000Z,keyCompromise	0C2D...	unknown	/CN=bob
+type IndexEntry struct {
+	Status           string
+	ExpiresAt        time.Time
+	RevokedAt        time.Time // zero unless revoked
+	RevocationReason string    // empty when none was recorded
+	Serial           string    // upper case hex
+	Filename         string    // always "unknown" for easy-rsa
+	Subject          string    // e.g. /CN=alice
+}
+
+// CommonName extracts the CN from the subject
+func (e IndexEntry) CommonName() string {
+	for _, part := range strings.Split(e.Subject, "/") {
+		if cn, ok := strings.CutPrefix(part, "CN="); ok {
+			return cn
+		}
+	}
+	return e.Subject
+}
+
+// ParseIndex parses an index.txt
+func ParseIndex(data []byte) ([]IndexEntry, error) {
+	entries := make([]IndexEntry, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("malformed index line %d", line)
+		}
+
+		entry := IndexEntry{
+			Status:   fields[0],
+			Serial:   strings.ToUpper(fields[3]),
+			Filename: fields[4],
+			Subject:  fields[5],
+		}
+
+		var err error
+		if entry.ExpiresAt, err = parseIndexTime(fields[1]); err != nil {
+			return nil, fmt.Errorf("index line %d: %w", line, err)
+		}
+
+		switch entry.Status {
+		case StatusValid, StatusExpired:
+		case StatusRevoked:
+			revokedAt, reason, _ := strings.Cut(fields[2], ",")
+			if entry.RevokedAt, err = parseIndexTime(revokedAt); err != nil {
+				return nil, fmt.Errorf("index line %d: %w", line, err)
+			}
+			entry.RevocationReason = reason
+		default:
+			return nil, fmt.Errorf("index line %d: unknown status %q", line, entry.Status)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading index: %w", err)
+	}
+
+	return entries, nil
+}
+
+// FormatIndex renders entries in index.txt format
+func FormatIndex(entries []IndexEntry) []byte {
+	var buf bytes.Buffer
+
+	for _, entry := range entries {
+		revocation := ""
+		if entry.Status == StatusRevoked {
+			revocation = formatIndexTime(entry.RevokedAt)
+			if entry.RevocationReason != "" {
+				revocation += "," + entry.RevocationReason
+			}
+		}
+
+		filename := entry.Filename
+		if filename == "" {
+			filename = "unknown"
+		}
+
+		fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Status, formatIndexTime(entry.ExpiresAt), revocation, entry.Serial, filename, entry.Subject)
+	}
+
+	return buf.Bytes()
+}
+
+// parseIndexTime parses UTCTime (YYMMDDHHMMSSZ) or GeneralizedTime (YYYYMMDDHHMMSSZ)
+func parseIndexTime(value string) (time.Time, error) {
+	layout := utcTimeLayout
+	if len(value) == len(generalizedTimeLayout) {
+		layout = generalizedTimeLayout
+	}
+
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid index time %q", value)
+	}
+	return t.UTC(), nil
+}
+
+// formatIndexTime uses UTCTime up to 2049 and GeneralizedTime after, as X.509 does
+func formatIndexTime(t time.Time) string {
+	t = t.UTC()
+	if t.Year() < 2050 {
+		return t.Format(utcTimeLayout)
+	}
+	return t.Format(generalizedTimeLayout)
+}
+
+const (
+	utcTimeLayout         = "060102150405Z"
+	generalizedTimeLayout = "20060102150405Z"
+)
@@ -0,0 +1,38 @@
+package pki
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	index := "V\t350101000000Z\t\t01\tunknown\t/CN=server\n" +
+		"R\t20550101000000Z\t250301120000Z,keyCompromise\t0A1B\tunknown\t/C=US/CN=bob/O=x\n" +
+		"E\t240101000000Z\t\t02\tunknown\t/CN=old\n"
+
+	entries, err := ParseIndex([]byte(index))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	bob := entries[1]
+	if bob.CommonName() != "bob" || bob.Status != StatusRevoked || bob.RevocationReason != "keyCompromise" {
+		t.Errorf("unexpected revoked entry: %+v", bob)
+	}
+	if !bob.RevokedAt.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) || bob.ExpiresAt.Year() != 2055 {
+		t.Errorf("unexpected times: %+v", bob)
+	}
+
+	if got := string(FormatIndex(entries)); got != index {
+		t.Errorf("round trip mismatch:\n%s", got)
+	}
+
+	for _, bad := range []string{"V\t350101000000Z\t\t01\tunknown\n", "X\t350101000000Z\t\t01\tunknown\t/CN=a\n", "V\tsoon\t\t01\tunknown\t/CN=a\n"} {
+		if _, err := ParseIndex([]byte(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,564 @@
+// Package pki manages an easy-rsa compatible certificate authority with
+// crypto/x509, without shelling out to easy-rsa or openssl.
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LevanPro/server/internal/fsutil"
+)
+
+var (
+	// ErrExists is returned when a valid certificate already exists for a name
+	ErrExists = errors.New("certificate already exists")
+	// ErrNotFound is returned when no valid certificate exists for a name
+	ErrNotFound = errors.New("certificate not found")
+)
+
+// CRL reason codes by the names OpenSSL writes into index.txt
+var reasonCodes = map[string]int{
+	"":                     0,
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"CACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+	"certificateHold":      6,
+}
+
+// Authority is a CA kept in easy-rsa's pki/ layout:
+//
+//	ca.crt, private/ca.key, index.txt, crlnumber, crl.pem,
+//	issued/<name>.crt, private/<name>.key, reqs/<name>.req,
+//	certs_by_serial/<serial>.pem, revoked/{certs,private,reqs}_by_serial/
+type Authority struct {
+	dir    string
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+
+	// Now is the clock used for validity periods; tests may replace it
+	Now func() time.Time
+
+	mu sync.Mutex
+}
+
+// Load opens an existing pki/ directory. The CA key must not be encrypted
+// (easy-rsa's build-ca nopass).
+func Load(dir string) (*Authority, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	caCert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA certificate: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "private", "ca.key"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	caKey, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA key: %w", err)
+	}
+
+	return &Authority{dir: dir, caCert: caCert, caKey: caKey, Now: time.Now}, nil
+}
+
+// Create initializes a new pki/ directory with a self-signed CA, like
+// easy-rsa's init-pki followed by build-ca nopass
+func Create(dir, commonName string, days int) (*Authority, error) {
+	if _, err := os.Stat(filepath.Join(dir, "ca.crt")); err == nil {
+		return nil, fmt.Errorf("%s already contains a CA", dir)
+	}
+
+	if err := makeDirs(dir); err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now,
+		NotAfter:              now.AddDate(0, 0, days),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		path string
+		data []byte
+		perm os.FileMode
+	}{
+		{filepath.Join(dir, "ca.crt"), pemBlock("CERTIFICATE", der), 0644},
+		{filepath.Join(dir, "private", "ca.key"), pemBlock("PRIVATE KEY", keyDER), 0600},
+		{filepath.Join(dir, "index.txt"), nil, 0600},
+		{filepath.Join(dir, "crlnumber"), []byte("01\n"), 0600},
+	}
+	for _, file := range files {
+		if err := fsutil.WriteFileAtomic(file.path, file.data, file.perm); err != nil {
+			return nil, err
+		}
+	}
+
+	return Load(dir)
+}
+
+// pkiDirs are the subdirectories easy-rsa creates
+var pkiDirs = []string{
+	"issued",
+	"private",
+	"reqs",
+	"certs_by_serial",
+	filepath.Join("revoked", "certs_by_serial"),
+	filepath.Join("revoked", "private_by_serial"),
+	filepath.Join("revoked", "reqs_by_serial"),
+}
+
+// makeDirs creates the subdirectories missing from older or hand-made layouts
+func makeDirs(dir string) error {
+	for _, sub := range pkiDirs {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", sub, err)
+		}
+	}
+	return nil
+}
+
+// Dir returns the pki/ directory
+func (a *Authority) Dir() string {
+	return a.dir
+}
+
+// CACertificate returns the CA certificate
+func (a *Authority) CACertificate() *x509.Certificate {
+	return a.caCert
+}
+
+// Index returns the entries of index.txt
+func (a *Authority) Index() ([]IndexEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.readIndex()
+}
+
+// IssueClient creates a key and a client certificate valid for days, like
+// easy-rsa's build-client-full <name> nopass. The key uses the CA's algorithm.
+func (a *Authority) IssueClient(name string, days int) (*x509.Certificate, error) {
+	if name == "" || strings.ContainsAny(name, "/\\\x00") || name == "ca" {
+		return nil, fmt.Errorf("invalid certificate name %q", name)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	certPath := filepath.Join(a.dir, "issued", name+".crt")
+	if _, err := os.Stat(certPath); err == nil {
+		return nil, ErrExists
+	}
+
+	if err := makeDirs(a.dir); err != nil {
+		return nil, err
+	}
+
+	entries, err := a.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := a.uniqueSerial(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := a.generateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	subjectKeyID := sha1.Sum(publicKeyDER)
+
+	now := a.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now,
+		NotAfter:              now.AddDate(0, 0, days),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		SubjectKeyId:          subjectKeyID[:],
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.caCert, key.Public(), a.caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	serialHex := FormatSerial(serial)
+	certPEM := pemBlock("CERTIFICATE", der)
+
+	// Key first: a certificate without its key would block reissuing the name
+	if err := fsutil.WriteFileAtomic(filepath.Join(a.dir, "private", name+".key"), pemBlock("PRIVATE KEY", keyDER), 0600); err != nil {
+		return nil, err
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(a.dir, "certs_by_serial", serialHex+".pem"), certPEM, 0644); err != nil {
+		return nil, err
+	}
+	if err := fsutil.WriteFileAtomic(certPath, certPEM, 0644); err != nil {
+		return nil, err
+	}
+
+	entries = append(entries, IndexEntry{
+		Status:    StatusValid,
+		ExpiresAt: cert.NotAfter,
+		Serial:    serialHex,
+		Filename:  "unknown",
+		Subject:   "/CN=" + name,
+	})
+	if err := a.writeIndex(entries); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// Revoke marks the issued certificate of name as revoked and moves its files
+// to revoked/, like easy-rsa's revoke. reason is an OpenSSL reason name and may be empty.
+func (a *Authority) Revoke(name, reason string) error {
+	if _, ok := reasonCodes[reason]; !ok {
+		return fmt.Errorf("unknown revocation reason %q", reason)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	certPath := filepath.Join(a.dir, "issued", name+".crt")
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return fmt.Errorf("invalid certificate %s: %w", certPath, err)
+	}
+	serialHex := FormatSerial(cert.SerialNumber)
+
+	entries, err := a.readIndex()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range entries {
+		if entries[i].Serial == serialHex && entries[i].Status != StatusRevoked {
+			entries[i].Status = StatusRevoked
+			entries[i].RevokedAt = a.Now().UTC()
+			entries[i].RevocationReason = reason
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: serial %s of %s is not in the index", ErrNotFound, serialHex, name)
+	}
+
+	if err := a.writeIndex(entries); err != nil {
+		return err
+	}
+
+	moves := []struct{ from, to string }{
+		{certPath, filepath.Join(a.dir, "revoked", "certs_by_serial", serialHex+".crt")},
+		{filepath.Join(a.dir, "private", name+".key"), filepath.Join(a.dir, "revoked", "private_by_serial", serialHex+".key")},
+		{filepath.Join(a.dir, "reqs", name+".req"), filepath.Join(a.dir, "revoked", "reqs_by_serial", serialHex+".req")},
+	}
+	if err := makeDirs(a.dir); err != nil {
+		return err
+	}
+	for _, move := range moves {
+		if err := os.Rename(move.from, move.to); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move revoked file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GenerateCRL signs a CRL of every revoked certificate, valid for days,
+// writes it to crl.pem and returns the PEM
+func (a *Authority) GenerateCRL(days int) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries, err := a.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make([]x509.RevocationListEntry, 0)
+	for _, entry := range entries {
+		if entry.Status != StatusRevoked {
+			continue
+		}
+
+		serial, ok := new(big.Int).SetString(entry.Serial, 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial %q in index", entry.Serial)
+		}
+
+		revoked = append(revoked, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: entry.RevokedAt,
+			ReasonCode:     reasonCodes[entry.RevocationReason],
+		})
+	}
+
+	number, err := a.nextCRLNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.Now().UTC()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.AddDate(0, 0, days),
+		RevokedCertificateEntries: revoked,
+	}, a.caCert, a.caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign CRL: %w", err)
+	}
+
+	crl := pemBlock("X509 CRL", der)
+	if err := fsutil.WriteFileAtomic(filepath.Join(a.dir, "crl.pem"), crl, 0644); err != nil {
+		return nil, err
+	}
+
+	return crl, nil
+}
+
+// nextCRLNumber reads and advances the crlnumber file. Caller must hold a.mu.
+func (a *Authority) nextCRLNumber() (*big.Int, error) {
+	path := filepath.Join(a.dir, "crlnumber")
+
+	number := big.NewInt(1)
+	if data, err := os.ReadFile(path); err == nil {
+		if _, ok := number.SetString(strings.TrimSpace(string(data)), 16); !ok {
+			return nil, fmt.Errorf("invalid crlnumber %q", strings.TrimSpace(string(data)))
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read crlnumber: %w", err)
+	}
+
+	next := new(big.Int).Add(number, big.NewInt(1))
+	if err := fsutil.WriteFileAtomic(path, []byte(FormatSerial(next)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+
+	return number, nil
+}
+
+// readIndex must be called with a.mu held
+func (a *Authority) readIndex() ([]IndexEntry, error) {
+	data, err := os.ReadFile(filepath.Join(a.dir, "index.txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []IndexEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	return ParseIndex(data)
+}
+
+// writeIndex must be called with a.mu held
+func (a *Authority) writeIndex(entries []IndexEntry) error {
+	return fsutil.WriteFileAtomic(filepath.Join(a.dir, "index.txt"), FormatIndex(entries), 0600)
+}
+
+// uniqueSerial draws random serials until one is not in the index
+func (a *Authority) uniqueSerial(entries []IndexEntry) (*big.Int, error) {
+	used := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		used[entry.Serial] = true
+	}
+
+	for range 10 {
+		serial, err := randomSerial()
+		if err != nil {
+			return nil, err
+		}
+		if !used[FormatSerial(serial)] {
+			return serial, nil
+		}
+	}
+	return nil, errors.New("failed to draw an unused serial")
+}
+
+// generateKey creates a client key of the same kind as the CA key
+func (a *Authority) generateKey() (crypto.Signer, error) {
+	switch caKey := a.caKey.(type) {
+	case *rsa.PrivateKey:
+		return rsa.GenerateKey(rand.Reader, max(caKey.N.BitLen(), 2048))
+	case *ecdsa.PrivateKey:
+		return ecdsa.GenerateKey(caKey.Curve, rand.Reader)
+	case ed25519.PrivateKey:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unsupported CA key type %T", a.caKey)
+}
+
+// randomSerial returns a positive 127-bit serial, as easy-rsa's random serials
+func randomSerial() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 127)
+	serial, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial: %w", err)
+	}
+	return serial.Add(serial, big.NewInt(1)), nil
+}
+
+// FormatSerial renders a serial the way index.txt records it: upper case hex, even length
+func FormatSerial(serial *big.Int) string {
+	hex := strings.ToUpper(serial.Text(16))
+	if len(hex)%2 == 1 {
+		hex = "0" + hex
+	}
+	return hex
+}
+
+// parseCertificate decodes the first certificate block; easy-rsa puts a text dump before it
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// parsePrivateKey accepts PKCS#8, PKCS#1 and SEC 1 keys
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM key found")
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") {
+		return nil, errors.New("encrypted keys are not supported")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+func pemBlock(kind string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+}
+
+// ReadCertificate reads a PEM certificate file, such as ca.crt or an issued certificate
+func ReadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate %s: %w", path, err)
+	}
+	return cert, nil
+}
+
+// ReadCRL reads a PEM or DER CRL file, such as crl.pem
+func ReadCRL(path string) (*x509.RevocationList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRL %s: %w", path, err)
+	}
+	return crl, nil
+}
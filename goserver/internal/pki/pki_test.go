@@ -0,0 +1,156 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIssueRevokeAndCRL(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pki")
+	if _, err := Create(dir, "Test CA", 30); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Work on a reloaded CA, as with a directory created by easy-rsa
+	authority, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	cert, err := authority.IssueClient("alice", 10)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if cert.Subject.CommonName != "alice" || cert.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth || cert.IsCA {
+		t.Errorf("unexpected certificate: %+v", cert.Subject)
+	}
+	if err := cert.CheckSignatureFrom(authority.CACertificate()); err != nil {
+		t.Errorf("certificate not signed by the CA: %v", err)
+	}
+	if _, err := authority.IssueClient("alice", 10); !errors.Is(err, ErrExists) {
+		t.Errorf("expected duplicate to be rejected, got %v", err)
+	}
+
+	serial := FormatSerial(cert.SerialNumber)
+	for _, path := range []string{"issued/alice.crt", "private/alice.key", "certs_by_serial/" + serial + ".pem"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("missing %s: %v", path, err)
+		}
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "private", "alice.key")); info != nil && info.Mode().Perm() != 0600 {
+		t.Errorf("client key mode %v", info.Mode().Perm())
+	}
+
+	if _, err := authority.IssueClient("bob", 10); err != nil {
+		t.Fatalf("issue bob: %v", err)
+	}
+
+	if err := authority.Revoke("alice", "keyCompromise"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := authority.Revoke("alice", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected second revoke to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "revoked", "certs_by_serial", serial+".crt")); err != nil {
+		t.Errorf("revoked certificate not moved: %v", err)
+	}
+
+	entries, err := authority.Index()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("unexpected index: %+v %v", entries, err)
+	}
+	if entries[0].Status != StatusRevoked || entries[0].Serial != serial || entries[1].Status != StatusValid {
+		t.Errorf("unexpected index entries: %+v", entries)
+	}
+
+	// Reissuing a revoked name is allowed
+	if _, err := authority.IssueClient("alice", 10); err != nil {
+		t.Errorf("reissue: %v", err)
+	}
+
+	for number := int64(1); number <= 2; number++ {
+		crlPEM, err := authority.GenerateCRL(7)
+		if err != nil {
+			t.Fatalf("crl: %v", err)
+		}
+
+		block, _ := pem.Decode(crlPEM)
+		if block == nil || block.Type != "X509 CRL" {
+			t.Fatalf("unexpected CRL PEM:\n%s", crlPEM)
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			t.Fatalf("parse CRL: %v", err)
+		}
+		if err := crl.CheckSignatureFrom(authority.CACertificate()); err != nil {
+			t.Errorf("CRL not signed by the CA: %v", err)
+		}
+		if crl.Number.Int64() != number {
+			t.Errorf("expected CRL number %d, got %v", number, crl.Number)
+		}
+		if len(crl.RevokedCertificateEntries) != 1 ||
+			crl.RevokedCertificateEntries[0].SerialNumber.Cmp(cert.SerialNumber) != 0 ||
+			crl.RevokedCertificateEntries[0].ReasonCode != 1 {
+			t.Errorf("unexpected revoked entries: %+v", crl.RevokedCertificateEntries)
+		}
+	}
+
+	written, err := os.ReadFile(filepath.Join(dir, "crl.pem"))
+	if err != nil || len(written) == 0 {
+		t.Errorf("crl.pem not written: %v", err)
+	}
+}
+
+func TestLoadECCAWithSEC1Key(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "private"), 0700)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "EC CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// easy-rsa prefixes certificates with a text dump
+	os.WriteFile(filepath.Join(dir, "ca.crt"), append([]byte("Certificate:\n    Data:\n"), pemBlock("CERTIFICATE", der)...), 0644)
+	os.WriteFile(filepath.Join(dir, "private", "ca.key"), pemBlock("EC PRIVATE KEY", keyDER), 0600)
+
+	authority, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	cert, err := authority.IssueClient("carol", 1)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if _, ok := cert.PublicKey.(*ecdsa.PublicKey); !ok {
+		t.Errorf("expected an EC client key, got %T", cert.PublicKey)
+	}
+}
@@ -0,0 +1,95 @@
+// Package profiles renders ready-to-import client configurations for the
+// L2TP/IPsec and IPsec/XAuth ("Cisco IPsec") modes of the IPsec server.
+package profiles
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Platforms with a rendered profile. Android is served the IKEv2 strongSwan
+// profile instead: strongSwan speaks neither L2TP nor IKEv1 XAuth.
+const (
+	PlatformApple   = "apple"
+	PlatformWindows = "windows"
+)
+
+// ErrUnknownPlatform is returned for platforms without a template
+var ErrUnknownPlatform = errors.New("unknown platform")
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"xml":    xmlEscape,
+	"ps":     powershellQuote,
+	"base64": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"uuid":   stableUUID,
+}).ParseFS(templateFS, "templates/*.tmpl"))
+
+// Credentials is what a profile is filled with
+type Credentials struct {
+	Name       string // connection name shown on the device
+	Server     string // DNS name or IP address of the VPN server
+	DNSServers []string
+	Username   string
+	Password   string
+	PSK        string
+}
+
+// Profile is a rendered configuration file
+type Profile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Render fills the template of a platform
+func Render(platform string, c Credentials) (*Profile, error) {
+	if c.Server == "" || c.Username == "" || c.Password == "" || c.PSK == "" {
+		return nil, errors.New("profile needs a server, username, password and PSK")
+	}
+
+	var name, filename, contentType string
+	switch platform {
+	case PlatformApple:
+		name, filename, contentType = "mobileconfig.tmpl", c.Username+".mobileconfig", "application/x-apple-aspen-config"
+	case PlatformWindows:
+		name, filename, contentType = "windows.ps1.tmpl", c.Username+".ps1", "text/plain; charset=utf-8"
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownPlatform, platform)
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, c); err != nil {
+		return nil, fmt.Errorf("failed to render %s profile: %w", platform, err)
+	}
+	return &Profile{Filename: filename, ContentType: contentType, Data: buf.Bytes()}, nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// powershellQuote returns s as a single-quoted PowerShell string
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// stableUUID derives a UUID from its parts, so that importing a profile
+// again replaces the earlier one instead of adding a copy
+func stableUUID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	sum[6] = sum[6]&0x0f | 0x50 // version 5 layout
+	sum[8] = sum[8]&0x3f | 0x80
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16]))
+}
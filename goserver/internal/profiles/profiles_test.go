@@ -0,0 +1,100 @@
+package profiles
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var testCredentials = Credentials{
+	Name:       "Office VPN",
+	Server:     "vpn.example.com",
+	DNSServers: []string{"1.1.1.1", "1.0.0.1"},
+	Username:   "alice",
+	Password:   "p<a>ss'&word",
+	PSK:        "shared'secret",
+}
+
+func TestRenderApple(t *testing.T) {
+	profile, err := Render(PlatformApple, testCredentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Filename != "alice.mobileconfig" || profile.ContentType != "application/x-apple-aspen-config" {
+		t.Errorf("unexpected file %q %q", profile.Filename, profile.ContentType)
+	}
+
+	// The plist must stay well-formed whatever the credentials contain
+	decoder := xml.NewDecoder(strings.NewReader(string(profile.Data)))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("invalid XML: %v\n%s", err, profile.Data)
+			}
+			break
+		}
+	}
+
+	data := string(profile.Data)
+	for _, want := range []string{
+		"<string>L2TP</string>",
+		"<string>IPSec</string>",
+		"<string>p&lt;a&gt;ss&#39;&amp;word</string>",
+		"<data>" + base64.StdEncoding.EncodeToString([]byte("shared'secret")) + "</data>",
+		"<string>vpn.example.com</string>",
+		"<string>1.0.0.1</string>",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("profile is missing %s", want)
+		}
+	}
+
+	again, _ := Render(PlatformApple, testCredentials)
+	if string(again.Data) != data {
+		t.Error("payload UUIDs are not stable")
+	}
+}
+
+func TestRenderWindows(t *testing.T) {
+	profile, err := Render(PlatformWindows, testCredentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := string(profile.Data)
+	for _, want := range []string{
+		"-L2tpPsk 'shared''secret'",
+		"$password = 'p<a>ss''&word'",
+		"$server = 'vpn.example.com'",
+		"$dns = @('1.1.1.1', '1.0.0.1')",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("script is missing %s:\n%s", want, data)
+		}
+	}
+
+	noDNS := testCredentials
+	noDNS.DNSServers = nil
+	profile, err = Render(PlatformWindows, noDNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(profile.Data), "rasphone.pbk") {
+		t.Error("DNS servers set without any configured")
+	}
+}
+
+func TestRenderRejects(t *testing.T) {
+	if _, err := Render("android", testCredentials); !errors.Is(err, ErrUnknownPlatform) {
+		t.Errorf("expected ErrUnknownPlatform, got %v", err)
+	}
+
+	missing := testCredentials
+	missing.Server = ""
+	if _, err := Render(PlatformApple, missing); err == nil {
+		t.Error("expected a profile without server to be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code sizes in pixels, the side of the square image
+const (
+	QRDefaultSize = 256
+	QRMinSize     = 64
+	QRMaxSize     = 2048
+)
+
+var (
+	// ErrUnknownQRFormat is returned for formats other than png and svg
+	ErrUnknownQRFormat = errors.New("unknown QR code format")
+	// ErrInvalidQRSize is returned for sizes outside QRMinSize and QRMaxSize
+	ErrInvalidQRSize = fmt.Errorf("size must be between %d and %d", QRMinSize, QRMaxSize)
+)
+
+// QRCredentials is the content of a credentials QR code, compact JSON the
+// onboarding app parses
+func QRCredentials(c Credentials) string {
+	data, _ := json.Marshal(struct {
+		Server   string `json:"server"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		PSK      string `json:"psk"`
+	}{c.Server, c.Username, c.Password, c.PSK})
+	return string(data)
+}
+
+// CheckQROptions validates a QR code format and size before any content is
+// prepared for it
+func CheckQROptions(format string, size int) error {
+	if size < QRMinSize || size > QRMaxSize {
+		return ErrInvalidQRSize
+	}
+	if format != "png" && format != "svg" {
+		return fmt.Errorf("%w %q", ErrUnknownQRFormat, format)
+	}
+	return nil
+}
+
+// QRCode encodes content as a png or svg image of size pixels
+func QRCode(content, format string, size int) (*Profile, error) {
+	if err := CheckQROptions(format, size); err != nil {
+		return nil, err
+	}
+
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	switch format {
+	case "png":
+		data, err := code.PNG(size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render QR code: %w", err)
+		}
+		return &Profile{Filename: "qrcode.png", ContentType: "image/png", Data: data}, nil
+	case "svg":
+		return &Profile{Filename: "qrcode.svg", ContentType: "image/svg+xml", Data: qrSVG(code.Bitmap(), size)}, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownQRFormat, format)
+}
+
+// qrSVG draws the modules of a bitmap, quiet zone included, as one path
+// scaled to size
+func qrSVG(bitmap [][]bool, size int) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, len(bitmap), len(bitmap))
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return []byte(b.String())
+}
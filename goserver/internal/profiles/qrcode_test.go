@@ -0,0 +1,63 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestQRCredentials(t *testing.T) {
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(QRCredentials(testCredentials)), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["server"] != "vpn.example.com" || decoded["username"] != "alice" ||
+		decoded["password"] != testCredentials.Password || decoded["psk"] != testCredentials.PSK {
+		t.Errorf("unexpected content %v", decoded)
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	content := QRCredentials(testCredentials)
+
+	code, err := QRCode(content, "png", 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(code.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 300 || bounds.Dy() != 300 {
+		t.Errorf("unexpected png size %v", bounds)
+	}
+
+	code, err = QRCode(content, "svg", 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var svg struct {
+		Width string `xml:"width,attr"`
+		Path  struct {
+			D string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal(code.Data, &svg); err != nil {
+		t.Fatalf("invalid svg: %v", err)
+	}
+	// The top-left finder pattern starts after the four module quiet zone
+	if svg.Width != "300" || !strings.HasPrefix(svg.Path.D, "M4 4h7v1h-7z") {
+		t.Errorf("unexpected svg %+v", svg)
+	}
+
+	if _, err := QRCode(content, "gif", 300); !errors.Is(err, ErrUnknownQRFormat) {
+		t.Errorf("expected ErrUnknownQRFormat, got %v", err)
+	}
+	if _, err := QRCode(content, "png", QRMaxSize+1); !errors.Is(err, ErrInvalidQRSize) {
+		t.Errorf("expected ErrInvalidQRSize, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrQuotaExceeded = errors.New("traffic quota exceeded")
+
+// AccessService decides whether a user may start a VPN session
+type AccessService struct {
+	files     *FileService
+	bandwidth *BandwidthService
+}
+
+func NewAccessService(files *FileService, bandwidth *BandwidthService) *AccessService {
+	return &AccessService{
+		files:     files,
+		bandwidth: bandwidth,
+	}
+}
+
+// Check rejects disabled and expired accounts and users whose traffic in the
+// current billing period reached their quota. Names without an account, such
+// as certificate-only OpenVPN clients, are allowed.
+func (a *AccessService) Check(name string) error {
+	accounts, err := a.files.ReadAccounts()
+	if err != nil {
+		return err
+	}
+
+	account, ok := accounts[name]
+	if !ok {
+		return nil
+	}
+	if account.Disabled {
+		return ErrUserDisabled
+	}
+	if account.Expired(a.files.Now()) {
+		return ErrUserExpired
+	}
+
+	if account.QuotaBytes > 0 {
+		usage := a.bandwidth.UserUsage(name)
+		if used := usage.TotalBytesSent + usage.TotalBytesReceived; used >= account.QuotaBytes {
+			return fmt.Errorf("%w: %d of %d bytes used", ErrQuotaExceeded, used, account.QuotaBytes)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/LevanPro/server/internal/fsutil"
+	"github.com/LevanPro/server/internal/models"
+)
+
+// accumulatorSchemaVersion is the schema version written by this build.
+// Bump it together with a new entry in accumulatorMigrations whenever the
+// on-disk shape of models.BandwidthAccumulator changes.
+const accumulatorSchemaVersion = 2
+
+// Recovery modes applied when the accumulator file exists but cannot be loaded
+const (
+	RecoveryFail   = "fail"   // refuse to start
+	RecoveryBackup = "backup" // restore the newest readable backup generation
+	RecoveryReset  = "reset"  // quarantine the broken file and start from zero
+)
+
+// errAccumulatorMissing is returned by load when neither the accumulator nor any backup exists
+var errAccumulatorMissing = errors.New("accumulator does not exist")
+
+// accumulatorMigrations upgrade a raw accumulator document from the keyed
+// schema version to the next one.
+var accumulatorMigrations = map[int]func(doc map[string]json.RawMessage) error{
+	0: migrateAccumulatorV0,
+	1: migrateAccumulatorV1,
+}
+
+// migrateAccumulatorV0 upgrades files written before schema versioning existed.
+// Those could carry a null client_states map.
+func migrateAccumulatorV0(doc map[string]json.RawMessage) error {
+	if raw, ok := doc["client_states"]; !ok || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		doc["client_states"] = json.RawMessage("{}")
+	}
+	return nil
+}
+
+// migrateAccumulatorV1 re-keys client_states from common name to session ID.
+// Version 1 did not record client or peer IDs, so those are marked unknown.
+// The key is derived here rather than with openVPNSessionID, so that later
+// changes to live session IDs do not alter what this migration produces.
+func migrateAccumulatorV1(doc map[string]json.RawMessage) error {
+	raw, ok := doc["client_states"]
+	if !ok {
+		return nil
+	}
+
+	var byCommonName map[string]models.ClientState
+	if err := json.Unmarshal(raw, &byCommonName); err != nil {
+		return err
+	}
+
+	bySession := make(map[string]models.ClientState, len(byCommonName))
+	for commonName, state := range byCommonName {
+		if state.CommonName == "" {
+			state.CommonName = commonName
+		}
+		state.ClientID, state.PeerID = -1, -1
+		bySession[fmt.Sprintf("%s@%s/%d", state.CommonName, state.RealAddress, state.ConnectedSince.Unix())] = state
+	}
+
+	encoded, err := json.Marshal(bySession)
+	if err != nil {
+		return err
+	}
+	doc["client_states"] = encoded
+	return nil
+}
+
+// accumulatorStore persists the bandwidth accumulator. Writes go to a temp file
+// that is fsynced and renamed over the previous version, so a crash leaves
+// either the old or the new file in place, never a truncated one. The replaced
+// version is kept as accumulator.json.1 and older ones shift up to the configured
+// number of generations.
+type accumulatorStore struct {
+	dir         string
+	generations int
+}
+
+func newAccumulatorStore(dir string, generations int) *accumulatorStore {
+	if generations < 0 {
+		generations = 0
+	}
+	return &accumulatorStore{dir: dir, generations: generations}
+}
+
+func (st *accumulatorStore) path() string {
+	return filepath.Join(st.dir, accumulatorFile)
+}
+
+func (st *accumulatorStore) backupPath(generation int) string {
+	return fmt.Sprintf("%s.%d", st.path(), generation)
+}
+
+// load reads and migrates the primary accumulator file
+func (st *accumulatorStore) load() (*models.BandwidthAccumulator, error) {
+	var acc *models.BandwidthAccumulator
+
+	err := st.withLock(syscall.LOCK_SH, func() error {
+		data, err := os.ReadFile(st.path())
+		if err != nil {
+			if os.IsNotExist(err) && !st.hasBackups() {
+				return errAccumulatorMissing
+			}
+			return fmt.Errorf("failed to read accumulator: %w", err)
+		}
+
+		acc, err = decodeAccumulator(data)
+		return err
+	})
+
+	return acc, err
+}
+
+// loadBackup returns the newest backup generation that decodes cleanly
+func (st *accumulatorStore) loadBackup() (*models.BandwidthAccumulator, string, error) {
+	var acc *models.BandwidthAccumulator
+	var source string
+
+	err := st.withLock(syscall.LOCK_SH, func() error {
+		var lastErr error = errAccumulatorMissing
+		for generation := 1; generation <= st.generations; generation++ {
+			path := st.backupPath(generation)
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					lastErr = err
+				}
+				continue
+			}
+
+			decoded, err := decodeAccumulator(data)
+			if err != nil {
+				lastErr = fmt.Errorf("%s: %w", filepath.Base(path), err)
+				continue
+			}
+
+			acc, source = decoded, path
+			return nil
+		}
+		return fmt.Errorf("no usable accumulator backup: %w", lastErr)
+	})
+
+	return acc, source, err
+}
+
+// quarantine moves an unreadable accumulator aside so it can be inspected later
+func (st *accumulatorStore) quarantine() (string, error) {
+	target := fmt.Sprintf("%s.corrupt-%d", st.path(), time.Now().Unix())
+
+	err := st.withLock(syscall.LOCK_EX, func() error {
+		if err := os.Rename(st.path(), target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to quarantine accumulator: %w", err)
+		}
+		return nil
+	})
+
+	return target, err
+}
+
+// save atomically replaces the accumulator file and rotates backups
+func (st *accumulatorStore) save(acc *models.BandwidthAccumulator) error {
+	acc.SchemaVersion = accumulatorSchemaVersion
+
+	data, err := json.MarshalIndent(acc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode accumulator: %w", err)
+	}
+	data = append(data, '\n')
+
+	return st.withLock(syscall.LOCK_EX, func() error {
+		tmp, err := fsutil.WriteTempFile(st.dir, accumulatorFile, data, 0644)
+		if err != nil {
+			return err
+		}
+
+		if err := st.rotate(); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+
+		if err := os.Rename(tmp, st.path()); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to replace accumulator: %w", err)
+		}
+
+		return fsutil.SyncDir(st.dir)
+	})
+}
+
+// rotate shifts backup generations up by one and hard-links the current file
+// as generation 1. The primary file stays in place throughout.
+func (st *accumulatorStore) rotate() error {
+	if st.generations == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(st.path()); os.IsNotExist(err) {
+		return nil
+	}
+
+	for generation := st.generations - 1; generation >= 1; generation-- {
+		if err := os.Rename(st.backupPath(generation), st.backupPath(generation+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate accumulator backup: %w", err)
+		}
+	}
+
+	os.Remove(st.backupPath(1))
+	if err := os.Link(st.path(), st.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to back up accumulator: %w", err)
+	}
+
+	return nil
+}
+
+func (st *accumulatorStore) hasBackups() bool {
+	for generation := 1; generation <= st.generations; generation++ {
+		if _, err := os.Stat(st.backupPath(generation)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// withLock serialises access across processes through a sidecar lock file,
+// since the data file itself is replaced on every write
+func (st *accumulatorStore) withLock(how int, fn func() error) error {
+	lockPath := st.path() + ".lock"
+
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open accumulator lock: %w", err)
+	}
+	defer file.Close()
+
+	maxRetries := 3
+	for i := 0; i < maxRetries; i++ {
+		err = syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if i == maxRetries-1 {
+			return fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+// decodeAccumulator decodes a raw document, applying schema migrations in order
+func decodeAccumulator(data []byte) (*models.BandwidthAccumulator, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode accumulator: %w", err)
+	}
+	if doc == nil {
+		return nil, errors.New("failed to decode accumulator: empty document")
+	}
+
+	version := 0
+	if raw, ok := doc["schema_version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("invalid accumulator schema version: %w", err)
+		}
+	}
+
+	if version > accumulatorSchemaVersion {
+		return nil, fmt.Errorf("accumulator schema version %d is newer than supported version %d", version, accumulatorSchemaVersion)
+	}
+
+	for ; version < accumulatorSchemaVersion; version++ {
+		migrate, ok := accumulatorMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from accumulator schema version %d", version)
+		}
+		if err := migrate(doc); err != nil {
+			return nil, fmt.Errorf("failed to migrate accumulator from schema version %d: %w", version, err)
+		}
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encode migrated accumulator: %w", err)
+	}
+
+	var acc models.BandwidthAccumulator
+	if err := json.Unmarshal(migrated, &acc); err != nil {
+		return nil, fmt.Errorf("failed to decode accumulator: %w", err)
+	}
+
+	acc.SchemaVersion = accumulatorSchemaVersion
+	if acc.ClientStates == nil {
+		acc.ClientStates = make(map[string]models.ClientState)
+	}
+	if acc.ClientTotals == nil {
+		acc.ClientTotals = make(map[string]models.AccumulatedData)
+	}
+	if acc.InterfaceTotals == nil {
+		acc.InterfaceTotals = make(map[string]models.AccumulatedData)
+	}
+	if acc.PPPUserTotals == nil {
+		acc.PPPUserTotals = make(map[string]models.AccumulatedData)
+	}
+	if acc.RadiusSessions == nil {
+		acc.RadiusSessions = make(map[string]models.RadiusSessionState)
+	}
+	if acc.RadiusUserTotals == nil {
+		acc.RadiusUserTotals = make(map[string]models.AccumulatedData)
+	}
+	if acc.HookedOpenVPNSessions == nil {
+		acc.HookedOpenVPNSessions = make(map[string]time.Time)
+	}
+	if acc.HookedPPPSessions == nil {
+		acc.HookedPPPSessions = make(map[string]string)
+	}
+
+	return &acc, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAccumulatorStoreRoundTripAndBackups(t *testing.T) {
+	st := newAccumulatorStore(t.TempDir(), 2)
+
+	if _, err := st.load(); !errors.Is(err, errAccumulatorMissing) {
+		t.Fatalf("expected errAccumulatorMissing on empty dir, got %v", err)
+	}
+
+	for i := uint64(1); i <= 3; i++ {
+		acc := newAccumulator(time.Now().UTC())
+		acc.OpenVPN.TotalBytesSent = i
+		if err := st.save(acc); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+
+	acc, err := st.load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if acc.OpenVPN.TotalBytesSent != 3 || acc.SchemaVersion != accumulatorSchemaVersion {
+		t.Fatalf("unexpected accumulator: %+v", acc)
+	}
+
+	// Simulate a torn write of the primary file
+	if err := os.WriteFile(st.path(), []byte(`{"openvpn": {"total_by`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.load(); err == nil || errors.Is(err, errAccumulatorMissing) {
+		t.Fatalf("expected a decode error for a truncated file, got %v", err)
+	}
+
+	backup, source, err := st.loadBackup()
+	if err != nil {
+		t.Fatalf("loadBackup: %v", err)
+	}
+	if backup.OpenVPN.TotalBytesSent != 2 || source != st.backupPath(1) {
+		t.Fatalf("expected generation 1 with 2 bytes, got %d from %s", backup.OpenVPN.TotalBytesSent, source)
+	}
+}
+
+func TestDecodeAccumulatorMigratesLegacyFiles(t *testing.T) {
+	legacy := []byte(`{"last_updated":"2025-01-01T00:00:00Z","openvpn":{"total_bytes_sent":42},"client_states":null}`)
+
+	acc, err := decodeAccumulator(legacy)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if acc.SchemaVersion != accumulatorSchemaVersion || acc.ClientStates == nil || acc.OpenVPN.TotalBytesSent != 42 {
+		t.Fatalf("unexpected migration result: %+v", acc)
+	}
+
+	v1 := []byte(`{"schema_version":1,"client_states":{"alice":{"real_address":"203.0.113.10:50123","bytes_sent":5,"connected_since":"2024-05-10T11:30:00Z"}}}`)
+	acc, err = decodeAccumulator(v1)
+	if err != nil {
+		t.Fatalf("decode v1: %v", err)
+	}
+	state, ok := acc.ClientStates["alice@203.0.113.10:50123/1715340600"]
+	if !ok || state.CommonName != "alice" || state.ClientID != -1 || state.BytesSent != 5 {
+		t.Fatalf("client states were not re-keyed by session: %+v", acc.ClientStates)
+	}
+
+	future := []byte(`{"schema_version": 999}`)
+	if _, err := decodeAccumulator(future); err == nil {
+		t.Fatalf("expected newer schema versions to be rejected")
+	}
+}
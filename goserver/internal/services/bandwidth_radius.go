@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// RadiusAccounting is what the bandwidth store keeps of a RADIUS
+// Accounting-Request. Counters are cumulative for the session and seen from
+// the server: sent is Acct-Output-Octets, received is Acct-Input-Octets.
+type RadiusAccounting struct {
+	NAS           string // NAS-Identifier, or the NAS address without one
+	SessionID     string // Acct-Session-Id
+	User          string
+	Stop          bool
+	BytesSent     uint64
+	BytesReceived uint64
+	SessionTime   time.Duration
+}
+
+func radiusSessionKey(nas, sessionID string) string {
+	return nas + "/" + sessionID
+}
+
+// RecordRadiusAccounting credits the traffic of a RADIUS session since its
+// previous accounting record to the user's per-period totals. A session
+// first seen with interim counters that started before the current period is
+// only taken as a baseline, as with collectors. Records for a session that
+// already stopped are ignored and reported as not new.
+func (s *BandwidthService) RecordRadiusAccounting(record RadiusAccounting) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	if err := s.closeDuePeriods(now); err != nil {
+		return false, fmt.Errorf("failed to close billing period: %w", err)
+	}
+	for key, endedAt := range s.endedRadius {
+		if now.Sub(endedAt) > endedSessionRetention {
+			delete(s.endedRadius, key)
+		}
+	}
+
+	key := radiusSessionKey(record.NAS, record.SessionID)
+	if _, ended := s.endedRadius[key]; ended {
+		return false, nil
+	}
+
+	startedAt := now.Add(-record.SessionTime)
+	baseline, ok := s.accumulator.RadiusSessions[key]
+	if !ok || baseline.User != record.User {
+		baseline = models.RadiusSessionState{User: record.User, StartedAt: startedAt}
+		if startedAt.Before(s.accumulator.LastResetAt) {
+			baseline.BytesSent = record.BytesSent
+			baseline.BytesReceived = record.BytesReceived
+		}
+	}
+
+	sent := sessionCounterDelta(record.BytesSent, baseline.BytesSent)
+	received := sessionCounterDelta(record.BytesReceived, baseline.BytesReceived)
+	addTotals(s.accumulator.RadiusUserTotals, record.User, sent, received, record.Stop)
+
+	if record.Stop {
+		delete(s.accumulator.RadiusSessions, key)
+		if s.endedRadius == nil {
+			s.endedRadius = make(map[string]time.Time)
+		}
+		s.endedRadius[key] = now
+	} else {
+		baseline.UpdatedAt = now
+		baseline.BytesSent = record.BytesSent
+		baseline.BytesReceived = record.BytesReceived
+		s.accumulator.RadiusSessions[key] = baseline
+	}
+
+	if err := s.saveAccumulator(); err != nil {
+		return false, fmt.Errorf("failed to save accumulator: %w", err)
+	}
+	return true, nil
+}
+
+// ForgetRadiusSessions drops the open sessions of a NAS that sent
+// Accounting-On or Accounting-Off: it restarted, and traffic it had not
+// reported yet is lost
+func (s *BandwidthService) ForgetRadiusSessions(nas string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	forgotten := 0
+	for key := range s.accumulator.RadiusSessions {
+		if strings.HasPrefix(key, nas+"/") {
+			delete(s.accumulator.RadiusSessions, key)
+			forgotten++
+		}
+	}
+	if forgotten == 0 {
+		return nil
+	}
+
+	if err := s.saveAccumulator(); err != nil {
+		return fmt.Errorf("failed to save accumulator: %w", err)
+	}
+	return nil
+}
@@ -1,22 +1,18 @@
 package services
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/LevanPro/server/internal/models"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 )
 
 const (
@@ -25,326 +21,734 @@ const (
 	accumulatorFile    = "accumulator.json"
 )
 
+// BandwidthOptions configures the bandwidth tracking service
+type BandwidthOptions struct {
+	StoragePath       string
+	BackupGenerations int
+	Recovery          string
+	Billing           *BillingCycle
+	Collectors        *CollectorRegistry
+}
+
 type BandwidthService struct {
-	dockerClient       *client.Client
-	storagePath        string
-	collectionInterval time.Duration
-	logger             *slog.Logger
+	store      *accumulatorStore
+	periods    *periodStore
+	billing    *BillingCycle
+	collectors *CollectorRegistry
+	logger     *slog.Logger
 
 	// Tracking state
-	accumulator    *models.BandwidthAccumulator
-	lastIPSecBytes uint64
-	mu             sync.RWMutex
+	accumulator *models.BandwidthAccumulator
+	mu          sync.RWMutex
+
+	// Current rates, refreshed on every tick
+	openvpnRate  models.ThroughputRate
+	ipsecRate    models.ThroughputRate
+	ipsecConns   map[string]models.IPSecConnection // key: connection name with instance
+	clientDeltas map[string]clientDelta            // last OpenVPN deltas, key: session ID
+	egressRate   models.ThroughputRate
+	hostIfaces   map[string]models.InterfaceMetrics // last host interface view, key: interface name
+
+	// When the source produced the last OpenVPN client list
+	openvpnObservedAt time.Time
+
+	// OpenVPN sessions that ended recently with the counters already
+	// credited, so that hook callbacks and collector samples count each
+	// session once
+	endedSessions map[string]endedSession // key: openVPNEndKey
+	// Likewise for ppp sessions, between the ip-down hook and the host
+	// interface collector
+	endedPPP map[string]endedPPPSession // key: interface name
+	// RADIUS sessions that stopped, so a retransmitted or late record is
+	// not taken for a new session
+	endedRadius map[string]time.Time // key: radiusSessionKey
+	// Stream subscribers
+	hub *snapshotHub
 
 	// Lifecycle
-	ticker *time.Ticker
-	done   chan struct{}
-	wg     sync.WaitGroup
+	done chan struct{}
+	wg   sync.WaitGroup
 }
 
-func NewBandwidthService(storagePath string, collectionInterval time.Duration, logger *slog.Logger) (*BandwidthService, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+func NewBandwidthService(opts BandwidthOptions, logger *slog.Logger) (*BandwidthService, error) {
+	periods, err := newPeriodStore(opts.StoragePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create docker client: %w", err)
+		return nil, err
+	}
+
+	collectors := opts.Collectors
+	if collectors == nil {
+		collectors = NewCollectorRegistry()
 	}
 
 	s := &BandwidthService{
-		dockerClient:       cli,
-		storagePath:        storagePath,
-		collectionInterval: collectionInterval,
-		logger:             logger,
-		done:               make(chan struct{}),
+		store:        newAccumulatorStore(opts.StoragePath, opts.BackupGenerations),
+		periods:      periods,
+		billing:      opts.Billing,
+		collectors:   collectors,
+		logger:       logger,
+		hub:          newSnapshotHub(),
+		ipsecConns:   make(map[string]models.IPSecConnection),
+		clientDeltas: make(map[string]clientDelta),
+		hostIfaces:   make(map[string]models.InterfaceMetrics),
+		done:         make(chan struct{}),
+
+		endedSessions: make(map[string]endedSession),
+		endedPPP:      make(map[string]endedPPPSession),
+		endedRadius:   make(map[string]time.Time),
 	}
 
-	// Try to load existing accumulator, or initialize new one
-	if err := s.loadAccumulator(); err != nil {
-		logger.Warn("Failed to load accumulator, initializing new one", "error", err.Error())
-		s.accumulator = &models.BandwidthAccumulator{
-			LastResetAt:  time.Now().UTC(),
-			LastUpdated:  time.Now().UTC(),
-			ClientStates: make(map[string]models.ClientState),
-		}
+	if err := s.restoreAccumulator(opts.Recovery); err != nil {
+		return nil, err
 	}
 
 	return s, nil
 }
 
-// Start launches the background tracking goroutine
+// restoreAccumulator loads the persisted accumulator. A missing file starts a
+// fresh accumulator; an unreadable one is handled according to the recovery
+// mode and never silently replaced.
+func (s *BandwidthService) restoreAccumulator(recovery string) error {
+	acc, err := s.store.load()
+	if err == nil {
+		s.accumulator = acc
+		return nil
+	}
+
+	if errors.Is(err, errAccumulatorMissing) {
+		s.logger.Info("No accumulator found, initializing new one")
+		s.accumulator = newAccumulator(time.Now().UTC())
+		return nil
+	}
+
+	switch recovery {
+	case RecoveryBackup:
+		acc, source, backupErr := s.store.loadBackup()
+		if backupErr != nil {
+			return fmt.Errorf("accumulator is unreadable (%v) and recovery from backup failed: %w", err, backupErr)
+		}
+		quarantined, qErr := s.store.quarantine()
+		if qErr != nil {
+			return qErr
+		}
+		s.logger.Warn("Recovered accumulator from backup",
+			"error", err.Error(),
+			"backup", source,
+			"quarantined", quarantined,
+		)
+		s.accumulator = acc
+		return s.saveAccumulator()
+
+	case RecoveryReset:
+		quarantined, qErr := s.store.quarantine()
+		if qErr != nil {
+			return qErr
+		}
+		s.logger.Warn("Accumulator unreadable, starting from zero",
+			"error", err.Error(),
+			"quarantined", quarantined,
+		)
+		s.accumulator = newAccumulator(time.Now().UTC())
+		return s.saveAccumulator()
+
+	default:
+		return fmt.Errorf("accumulator is unreadable: %w (set bandwidth_tracking.recovery to %q or %q to start anyway)", err, RecoveryBackup, RecoveryReset)
+	}
+}
+
+func newAccumulator(now time.Time) *models.BandwidthAccumulator {
+	return &models.BandwidthAccumulator{
+		SchemaVersion:   accumulatorSchemaVersion,
+		LastResetAt:     now,
+		LastUpdated:     now,
+		ClientStates:    make(map[string]models.ClientState),
+		ClientTotals:    make(map[string]models.AccumulatedData),
+		InterfaceTotals: make(map[string]models.AccumulatedData),
+		PPPUserTotals:   make(map[string]models.AccumulatedData),
+
+		RadiusSessions:   make(map[string]models.RadiusSessionState),
+		RadiusUserTotals: make(map[string]models.AccumulatedData),
+
+		HookedOpenVPNSessions: make(map[string]time.Time),
+		HookedPPPSessions:     make(map[string]string),
+	}
+}
+
+// Start launches one background goroutine per enabled collector
 func (s *BandwidthService) Start() error {
-	s.ticker = time.NewTicker(s.collectionInterval)
-	s.wg.Add(1)
-	go s.trackingLoop()
-	s.logger.Info("Bandwidth tracking started", "interval", s.collectionInterval)
+	for _, entry := range s.collectors.enabledEntries() {
+		s.wg.Add(1)
+		go s.trackingLoop(entry)
+		s.logger.Info("Bandwidth collector started", "collector", entry.collector.Name(), "interval", entry.interval)
+	}
 	return nil
 }
 
-// trackingLoop runs the periodic bandwidth collection
-func (s *BandwidthService) trackingLoop() {
+// trackingLoop runs the periodic collection for a single collector
+func (s *BandwidthService) trackingLoop(entry *collectorEntry) {
 	defer s.wg.Done()
 
+	ticker := time.NewTicker(entry.interval)
+	defer ticker.Stop()
+
+	// Collectors without pushed samples leave this nil, which never fires
+	var updates <-chan *CollectorSample
+	if streaming, ok := entry.collector.(StreamingCollector); ok {
+		updates = streaming.Updates()
+	}
+
 	for {
 		select {
-		case <-s.ticker.C:
-			if err := s.collectAndAccumulate(); err != nil {
-				s.logger.Error("Failed to collect and accumulate bandwidth", "error", err.Error())
+		case <-ticker.C:
+			if err := s.collectAndAccumulate(entry); err != nil {
+				s.logger.Error("Failed to collect and accumulate bandwidth",
+					"collector", entry.collector.Name(),
+					"error", err.Error(),
+				)
+			}
+		case sample := <-updates:
+			if err := s.accumulate(entry, sample, nil); err != nil {
+				s.logger.Error("Failed to accumulate pushed bandwidth sample",
+					"collector", entry.collector.Name(),
+					"error", err.Error(),
+				)
 			}
 		case <-s.done:
-			s.logger.Info("Bandwidth tracking stopped")
+			s.logger.Info("Bandwidth collector stopped", "collector", entry.collector.Name())
 			return
 		}
 	}
 }
 
-// collectAndAccumulate collects current metrics and updates the accumulator
-func (s *BandwidthService) collectAndAccumulate() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// collectAndAccumulate runs a collector and folds its sample into the accumulator
+func (s *BandwidthService) collectAndAccumulate(entry *collectorEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), entry.interval)
+	defer cancel()
 
-	// Parse current OpenVPN clients
-	currentClients, err := s.parseOpenVPNClients()
+	sample, err := entry.collector.Collect(ctx)
+	return s.accumulate(entry, sample, err)
+}
+
+// accumulate records a collector run and folds its sample into the accumulator
+func (s *BandwidthService) accumulate(entry *collectorEntry, sample *CollectorSample, err error) error {
+	now := time.Now().UTC()
+	elapsed := s.collectors.record(entry, now, err)
 	if err != nil {
-		s.logger.Warn("Failed to parse OpenVPN clients", "error", err.Error())
-		currentClients = make(map[string]models.ClientState)
+		return err
 	}
 
-	// Calculate OpenVPN deltas
-	previousClients := s.accumulator.ClientStates
-	s.calculateOpenVPNDeltas(currentClients, previousClients)
-
-	// Update client states
-	s.accumulator.ClientStates = currentClients
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Collect IPSec metrics
-	ipsecBytes, err := s.collectIPSecBytes()
-	if err != nil {
-		s.logger.Warn("Failed to collect IPSec metrics", "error", err.Error())
-		ipsecBytes = 0
+	if sample.OpenVPN != nil {
+		s.applyOpenVPNSample(sample.OpenVPN, elapsed)
 	}
-
-	// Calculate IPSec delta
-	if s.lastIPSecBytes > 0 && ipsecBytes >= s.lastIPSecBytes {
-		delta := ipsecBytes - s.lastIPSecBytes
-		s.accumulator.IPSec.TotalBytesSent += delta / 2 // Approximate split
-		s.accumulator.IPSec.TotalBytesReceived += delta / 2
+	if sample.IPSec != nil {
+		s.applyIPSecSample(sample.IPSec, elapsed)
+	}
+	if sample.Host != nil {
+		s.applyHostSample(sample.Host, elapsed)
 	}
-	s.lastIPSecBytes = ipsecBytes
 
 	// Update totals
 	s.accumulator.IPSec.TotalBandwidthMB = float64(s.accumulator.IPSec.TotalBytesSent+s.accumulator.IPSec.TotalBytesReceived) / (1024 * 1024)
 	s.accumulator.OpenVPN.TotalBandwidthMB = float64(s.accumulator.OpenVPN.TotalBytesSent+s.accumulator.OpenVPN.TotalBytesReceived) / (1024 * 1024)
-	s.accumulator.LastUpdated = time.Now().UTC()
+	s.accumulator.LastUpdated = now
+
+	// Close any billing periods that have ended
+	if err := s.closeDuePeriods(now); err != nil {
+		return fmt.Errorf("failed to close billing period: %w", err)
+	}
 
 	// Persist to disk
 	if err := s.saveAccumulator(); err != nil {
 		return fmt.Errorf("failed to save accumulator: %w", err)
 	}
 
+	// Fan out to stream subscribers; never blocks on slow consumers
+	s.hub.publish(s.buildSnapshot(now, elapsed))
+
 	return nil
 }
 
-// parseOpenVPNClients parses the OpenVPN status file and returns current client states
-func (s *BandwidthService) parseOpenVPNClients() (map[string]models.ClientState, error) {
-	clients := make(map[string]models.ClientState)
+// applyOpenVPNSample accounts OpenVPN client deltas. Caller must hold s.mu.
+func (s *BandwidthService) applyOpenVPNSample(sample *OpenVPNSample, elapsed time.Duration) {
+	observedAt := sample.UpdatedAt
+	if observedAt.IsZero() {
+		observedAt = time.Now().UTC()
+	}
 
-	file, err := os.Open(openVPNStatusFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return clients, nil
-		}
-		return nil, fmt.Errorf("failed to open OpenVPN status file: %w", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Parse CLIENT_LIST lines
-		// Format: CLIENT_LIST,common_name,real_address,virtual_ip,virtual_ipv6,bytes_received,bytes_sent,connected_since,username,client_id,peer_id
-		if strings.HasPrefix(line, "CLIENT_LIST") {
-			fields := strings.Split(line, ",")
-			if len(fields) >= 8 {
-				commonName := fields[1]
-				realAddress := fields[2]
-				bytesReceived, err1 := strconv.ParseUint(fields[5], 10, 64)
-				bytesSent, err2 := strconv.ParseUint(fields[6], 10, 64)
-				connectedSince, err3 := time.Parse("2006-01-02 15:04:05", fields[7])
-
-				if err1 == nil && err2 == nil {
-					if err3 != nil {
-						connectedSince = time.Now().UTC()
-					}
-
-					clients[commonName] = models.ClientState{
-						CommonName:     commonName,
-						RealAddress:    realAddress,
-						BytesSent:      bytesSent,
-						BytesReceived:  bytesReceived,
-						ConnectedSince: connectedSince,
-						LastSeenAt:     time.Now().UTC(),
-					}
-				}
-			}
-		}
+	// Sessions that connected after the previous list (or, after a restart,
+	// after the accumulator was last written) were never observed before
+	since := s.openvpnObservedAt
+	if since.IsZero() {
+		since = s.accumulator.LastUpdated
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading OpenVPN status file: %w", err)
+	// Sessions a client-disconnect hook already finalised may linger in a
+	// status file written before the disconnect
+	s.pruneEndedSessions(observedAt)
+	clients := s.withoutEndedSessions(sample.Clients)
+
+	before := s.accumulator.OpenVPN
+	s.clientDeltas = s.calculateOpenVPNDeltas(clients, s.withoutEndedSessions(sample.Ended), s.accumulator.ClientStates, since, elapsed)
+	s.openvpnRate = updateRate(s.openvpnRate,
+		s.accumulator.OpenVPN.TotalBytesSent-before.TotalBytesSent,
+		s.accumulator.OpenVPN.TotalBytesReceived-before.TotalBytesReceived,
+		elapsed,
+	)
+
+	// Update client states
+	s.accumulator.ClientStates = clients
+	s.openvpnObservedAt = observedAt
+}
+
+// applyIPSecSample accounts IPsec counter deltas. Caller must hold s.mu.
+func (s *BandwidthService) applyIPSecSample(sample *IPSecSample, elapsed time.Duration) {
+	if sample.Counters == nil {
+		// Server not running: nothing flows, keep the baseline for reset detection
+		s.ipsecRate = models.ThroughputRate{}
+		s.ipsecConns = make(map[string]models.IPSecConnection)
+		return
 	}
 
-	return clients, nil
+	sent, received, reset := ipsecDelta(s.accumulator.IPSecState, sample.Counters, s.accumulator.LastResetAt)
+	if reset {
+		s.logger.Info("IPSec counter reset detected",
+			"container_id", sample.Counters.ContainerID,
+			"started_at", sample.Counters.StartedAt,
+		)
+	}
+	s.accumulator.IPSec.TotalBytesSent += sent
+	s.accumulator.IPSec.TotalBytesReceived += received
+	s.accumulator.IPSecState = sample.Counters
+	s.ipsecRate = updateRate(s.ipsecRate, sent, received, elapsed)
+	if sample.ConnectionsErr != nil {
+		// Keep the last known connections until they can be listed again
+		s.logger.Warn("Failed to list IPSec connections", "error", sample.ConnectionsErr.Error())
+		return
+	}
+	s.ipsecConns = updateIPSecConnectionRates(sample.Connections, s.ipsecConns, elapsed)
 }
 
-// calculateOpenVPNDeltas calculates bandwidth deltas and updates the accumulator
-func (s *BandwidthService) calculateOpenVPNDeltas(current, previous map[string]models.ClientState) {
-	// Track deltas for existing clients
-	for commonName, currentState := range current {
-		if prevState, exists := previous[commonName]; exists {
-			// Client continued session - calculate delta
-			deltaSent := int64(currentState.BytesSent) - int64(prevState.BytesSent)
-			deltaReceived := int64(currentState.BytesReceived) - int64(prevState.BytesReceived)
+// applyHostSample accounts host interface deltas per interface, per ppp user
+// and for the egress interface. Caller must hold s.mu.
+func (s *BandwidthService) applyHostSample(sample *HostSample, elapsed time.Duration) {
+	deltas := interfaceDeltas(s.accumulator.HostState, sample.Counters)
 
-			// Handle counter rollover (treat as new connection)
-			if deltaSent < 0 {
-				deltaSent = int64(currentState.BytesSent)
-			}
-			if deltaReceived < 0 {
-				deltaReceived = int64(currentState.BytesReceived)
+	s.pruneEndedPPP(time.Now().UTC())
+
+	// A ppp interface that went away ended its user's session, unless the
+	// ip-down hook already reported it
+	for name, previous := range s.hostIfaces {
+		if _, ok := sample.Counters.Interfaces[name]; !ok && previous.User != "" {
+			if ended, ok := s.endedPPP[name]; ok && ended.byHook && ended.user == previous.User {
+				continue
 			}
+			addTotals(s.accumulator.PPPUserTotals, previous.User, 0, 0, true)
+			s.recordEndedPPP(name, previous.User, s.accumulator.HostState.Interfaces[name], false)
+		}
+	}
+
+	interfaces := make(map[string]models.InterfaceMetrics, len(sample.Counters.Interfaces))
+	for name, delta := range deltas {
+		addTotals(s.accumulator.InterfaceTotals, name, delta.sent, delta.received, false)
 
-			s.accumulator.OpenVPN.TotalBytesSent += uint64(deltaSent)
-			s.accumulator.OpenVPN.TotalBytesReceived += uint64(deltaReceived)
+		user := sample.PPPUsers[name]
+		if user != "" && !s.pppEndedByHook(name, user, sample.Counters.Interfaces[name]) {
+			addTotals(s.accumulator.PPPUserTotals, user, delta.sent, delta.received, false)
+		}
+
+		if sample.PPPAsIPSec && strings.HasPrefix(name, "ppp") {
+			s.accumulator.IPSec.TotalBytesSent += delta.sent
+			s.accumulator.IPSec.TotalBytesReceived += delta.received
+		}
+
+		var previousRate models.ThroughputRate
+		if previous := s.hostIfaces[name].Rate; previous != nil {
+			previousRate = *previous
+		}
+		rate := updateRate(previousRate, delta.sent, delta.received, elapsed)
+
+		counters := sample.Counters.Interfaces[name]
+		interfaces[name] = models.InterfaceMetrics{
+			Name:          name,
+			User:          user,
+			BytesSent:     counters.TxBytes,
+			BytesReceived: counters.RxBytes,
+			BandwidthMB:   float64(counters.TxBytes+counters.RxBytes) / (1024 * 1024),
+			Rate:          &rate,
 		}
-		// New clients: wait for next poll to get delta
 	}
 
-	// Capture final bandwidth for disconnected clients
-	for commonName, prevState := range previous {
-		if _, stillConnected := current[commonName]; !stillConnected {
-			// Client disconnected - capture final contribution
-			s.accumulator.OpenVPN.TotalBytesSent += prevState.BytesSent
-			s.accumulator.OpenVPN.TotalBytesReceived += prevState.BytesReceived
-			s.accumulator.OpenVPN.SessionCount++
+	egress := deltas[sample.Counters.EgressInterface]
+	s.accumulator.Egress.TotalBytesSent += egress.sent
+	s.accumulator.Egress.TotalBytesReceived += egress.received
+	s.accumulator.Egress.TotalBandwidthMB = float64(s.accumulator.Egress.TotalBytesSent+s.accumulator.Egress.TotalBytesReceived) / (1024 * 1024)
+	s.egressRate = updateRate(s.egressRate, egress.sent, egress.received, elapsed)
+
+	s.hostIfaces = interfaces
+	s.accumulator.HostState = sample.Counters
+}
+
+// interfaceDeltas computes per-interface TX (sent) and RX (received) deltas.
+// Without a baseline every interface only establishes one, so counters
+// accumulated since boot are not attributed to the current period. Interfaces
+// that appeared since the last observation, or whose counters went backwards
+// (recreated interface, reboot), are counted in full.
+func interfaceDeltas(prev, cur *models.HostCounterState) map[string]clientDelta {
+	deltas := make(map[string]clientDelta, len(cur.Interfaces))
+
+	for name, counters := range cur.Interfaces {
+		if prev == nil {
+			deltas[name] = clientDelta{}
+			continue
+		}
+
+		before, ok := prev.Interfaces[name]
+		if !ok {
+			deltas[name] = clientDelta{sent: counters.TxBytes, received: counters.RxBytes}
+			continue
+		}
+
+		var delta clientDelta
+		if counters.TxBytes >= before.TxBytes {
+			delta.sent = counters.TxBytes - before.TxBytes
+		} else {
+			delta.sent = counters.TxBytes
 		}
+		if counters.RxBytes >= before.RxBytes {
+			delta.received = counters.RxBytes - before.RxBytes
+		} else {
+			delta.received = counters.RxBytes
+		}
+		deltas[name] = delta
 	}
+
+	return deltas
 }
 
-// collectIPSecBytes returns total network bytes for IPSec container
-func (s *BandwidthService) collectIPSecBytes() (uint64, error) {
-	ctx := context.Background()
+// buildSnapshot assembles the stream payload for the current tick. Caller must hold s.mu.
+func (s *BandwidthService) buildSnapshot(now time.Time, elapsed time.Duration) *models.BandwidthSnapshot {
+	clients := s.accumulator.ClientStates
 
-	stats, err := s.dockerClient.ContainerStats(ctx, ipsecContainerName, false)
-	if err != nil {
-		return 0, nil // Container not running
+	snapshot := &models.BandwidthSnapshot{
+		Timestamp:        now,
+		IntervalSeconds:  elapsed.Seconds(),
+		Accumulated:      *s.accumulatedMetrics(),
+		Clients:          make([]models.ClientThroughput, 0, len(clients)),
+		IPSecConnections: s.ipsecConnections(),
 	}
-	defer stats.Body.Close()
 
-	var containerStats container.StatsResponse
-	if err := json.NewDecoder(stats.Body).Decode(&containerStats); err != nil {
-		return 0, fmt.Errorf("failed to decode container stats: %w", err)
+	for sessionID, state := range clients {
+		delta := s.clientDeltas[sessionID]
+		snapshot.Clients = append(snapshot.Clients, models.ClientThroughput{
+			SessionID:          sessionID,
+			CommonName:         state.CommonName,
+			RealAddress:        state.RealAddress,
+			DeltaBytesSent:     delta.sent,
+			DeltaBytesReceived: delta.received,
+			Rate:               state.Rate,
+		})
 	}
 
-	var totalBytes uint64
-	for _, network := range containerStats.Networks {
-		totalBytes += network.RxBytes + network.TxBytes
+	sort.Slice(snapshot.Clients, func(i, j int) bool {
+		if snapshot.Clients[i].CommonName != snapshot.Clients[j].CommonName {
+			return snapshot.Clients[i].CommonName < snapshot.Clients[j].CommonName
+		}
+		return snapshot.Clients[i].SessionID < snapshot.Clients[j].SessionID
+	})
+
+	return snapshot
+}
+
+// ipsecConnections returns the last seen IPsec connections sorted by name. Caller must hold s.mu.
+func (s *BandwidthService) ipsecConnections() []models.IPSecConnection {
+	connections := make([]models.IPSecConnection, 0, len(s.ipsecConns))
+	for _, conn := range s.ipsecConns {
+		connections = append(connections, conn)
 	}
 
-	return totalBytes, nil
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].Connection < connections[j].Connection
+	})
+
+	return connections
 }
 
-// loadAccumulator loads the accumulator from disk
-func (s *BandwidthService) loadAccumulator() error {
-	filePath := filepath.Join(s.storagePath, accumulatorFile)
+// TopTalkers returns OpenVPN clients and IPsec connections ordered by their
+// current combined rate, or by the smoothed rate when smoothed is set
+func (s *BandwidthService) TopTalkers(limit int, smoothed bool) []models.TopTalker {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// Try to open with shared lock
-	var file *os.File
-	var err error
-	maxRetries := 3
+	talkers := make([]models.TopTalker, 0, len(s.accumulator.ClientStates)+len(s.ipsecConns))
 
-	for i := 0; i < maxRetries; i++ {
-		file, err = os.OpenFile(filePath, os.O_RDONLY, 0)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return err
-			}
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to open accumulator file: %w", err)
-			}
-			time.Sleep(100 * time.Millisecond)
-			continue
+	for sessionID, state := range s.accumulator.ClientStates {
+		talkers = append(talkers, models.TopTalker{
+			Protocol:      "openvpn",
+			ID:            sessionID,
+			User:          state.CommonName,
+			RemoteAddress: state.RealAddress,
+			Rate:          state.Rate,
+		})
+	}
+
+	for _, conn := range s.ipsecConns {
+		user := conn.Username
+		if user == "" {
+			user = conn.PeerID
 		}
+		talkers = append(talkers, models.TopTalker{
+			Protocol:      "ipsec",
+			ID:            conn.Connection,
+			User:          user,
+			RemoteAddress: conn.RemoteAddress,
+			Rate:          conn.Rate,
+		})
+	}
 
-		err = syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
-		if err != nil {
-			file.Close()
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to acquire lock: %w", err)
-			}
-			time.Sleep(100 * time.Millisecond)
-			continue
+	total := func(rate models.ThroughputRate) float64 {
+		if smoothed {
+			return rate.SmoothedSentPerSecond + rate.SmoothedReceivedPerSecond
 		}
-		break
+		return rate.SentPerSecond + rate.ReceivedPerSecond
 	}
-	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
-	defer file.Close()
 
-	var acc models.BandwidthAccumulator
-	if err := json.NewDecoder(file).Decode(&acc); err != nil {
-		return fmt.Errorf("failed to decode accumulator: %w", err)
+	sort.SliceStable(talkers, func(i, j int) bool {
+		return total(talkers[i].Rate) > total(talkers[j].Rate)
+	})
+
+	if limit > 0 && len(talkers) > limit {
+		talkers = talkers[:limit]
 	}
 
-	if acc.ClientStates == nil {
-		acc.ClientStates = make(map[string]models.ClientState)
+	return talkers
+}
+
+// UserSessions returns the connected sessions of a user as last seen by the
+// collectors, OpenVPN first, without querying OpenVPN or the IPsec container.
+// Sessions of a protocol whose collector is disabled are not listed.
+func (s *BandwidthService) UserSessions(username string) []models.ActiveSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]models.ActiveSession, 0)
+	for _, state := range s.accumulator.ClientStates {
+		if state.CommonName != username {
+			continue
+		}
+		session := models.ActiveSession{
+			Protocol:       models.ProtocolOpenVPN,
+			User:           state.CommonName,
+			RemoteAddress:  state.RealAddress,
+			VirtualAddress: state.VirtualAddress,
+			BytesSent:      state.BytesSent,
+			BytesReceived:  state.BytesReceived,
+		}
+		if state.ClientID >= 0 {
+			session.ID = openvpnSessionPrefix + strconv.FormatInt(state.ClientID, 10)
+		}
+		if !state.ConnectedSince.IsZero() {
+			connectedSince := state.ConnectedSince
+			session.ConnectedSince = &connectedSince
+		}
+		sessions = append(sessions, session)
 	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].RemoteAddress < sessions[j].RemoteAddress
+	})
+
+	var connections []models.IPSecConnection
+	for _, conn := range s.ipsecConnections() {
+		if conn.Username == username || (conn.Username == "" && conn.PeerID == username) {
+			connections = append(connections, conn)
+		}
+	}
+	return append(sessions, ipsecSessions(connections)...)
+}
 
-	s.accumulator = &acc
-	return nil
+// Subscribe registers a stream subscriber. The returned channel receives a snapshot
+// after every collection tick and is closed when the service shuts down or cancel is called.
+func (s *BandwidthService) Subscribe() (<-chan *models.BandwidthSnapshot, func()) {
+	return s.hub.subscribe()
 }
 
-// saveAccumulator persists the accumulator to disk
-func (s *BandwidthService) saveAccumulator() error {
-	filePath := filepath.Join(s.storagePath, accumulatorFile)
+// LatestSnapshot returns the most recently published snapshot, or nil before the first tick
+func (s *BandwidthService) LatestSnapshot() *models.BandwidthSnapshot {
+	return s.hub.latest()
+}
 
-	// Open with exclusive lock
-	var file *os.File
-	var err error
-	maxRetries := 3
+// clientDelta holds the bytes a client moved since the previous collection
+type clientDelta struct {
+	sent     uint64
+	received uint64
+}
 
-	for i := 0; i < maxRetries; i++ {
-		file, err = os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-		if err != nil {
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to open accumulator file: %w", err)
-			}
-			time.Sleep(100 * time.Millisecond)
+// calculateOpenVPNDeltas calculates bandwidth deltas per session, updates the
+// accumulator and client rates, and returns the per-session deltas. Sessions
+// are keyed by session ID, so several sessions of one common name are counted
+// independently; per-user totals still roll up by common name.
+//
+// A session seen for the first time is counted in full when it connected after
+// since, the time of the previous observation. Otherwise its counters only
+// establish a baseline, as they may include traffic from before this period.
+// A session that disappeared only ends: its traffic up to the last observation
+// has already been counted through deltas, and ended carries whatever final
+// counters the source reported for it.
+func (s *BandwidthService) calculateOpenVPNDeltas(current, ended, previous map[string]models.ClientState, since time.Time, elapsed time.Duration) map[string]clientDelta {
+	deltas := make(map[string]clientDelta, len(current))
+
+	for sessionID, finalState := range ended {
+		if _, ok := current[sessionID]; ok {
 			continue
 		}
 
-		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
-		if err != nil {
-			file.Close()
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to acquire lock: %w", err)
-			}
-			time.Sleep(100 * time.Millisecond)
+		var delta clientDelta
+		if prevState, exists := previous[sessionID]; exists {
+			delta.sent = sessionCounterDelta(finalState.BytesSent, prevState.BytesSent)
+			delta.received = sessionCounterDelta(finalState.BytesReceived, prevState.BytesReceived)
+		} else if !since.IsZero() && finalState.ConnectedSince.After(since) {
+			// Connected and disconnected between two observations
+			delta.sent = finalState.BytesSent
+			delta.received = finalState.BytesReceived
+			s.accumulator.OpenVPN.SessionCount++
+			s.addClientTotals(finalState.CommonName, 0, 0, true)
+		}
+
+		s.accumulator.OpenVPN.TotalBytesSent += delta.sent
+		s.accumulator.OpenVPN.TotalBytesReceived += delta.received
+		s.addClientTotals(finalState.CommonName, delta.sent, delta.received, false)
+		s.recordEndedSession(finalState)
+	}
+
+	for sessionID, currentState := range current {
+		var delta clientDelta
+
+		prevState, exists := previous[sessionID]
+		switch {
+		case exists:
+			// Continued session. A lower counter means the source lags behind
+			// an earlier observation (e.g. status file after a bytecount push).
+			delta.sent = sessionCounterDelta(currentState.BytesSent, prevState.BytesSent)
+			delta.received = sessionCounterDelta(currentState.BytesReceived, prevState.BytesReceived)
+			currentState.BytesSent = max(currentState.BytesSent, prevState.BytesSent)
+			currentState.BytesReceived = max(currentState.BytesReceived, prevState.BytesReceived)
+			currentState.Rate = updateRate(prevState.Rate, delta.sent, delta.received, elapsed)
+		case !since.IsZero() && !currentState.ConnectedSince.IsZero() && currentState.ConnectedSince.After(since):
+			// New session: everything it moved happened since the last observation
+			delta.sent = currentState.BytesSent
+			delta.received = currentState.BytesReceived
+		default:
+			// Session predates what we observed: baseline only
 			continue
 		}
-		break
+
+		s.accumulator.OpenVPN.TotalBytesSent += delta.sent
+		s.accumulator.OpenVPN.TotalBytesReceived += delta.received
+		s.addClientTotals(currentState.CommonName, delta.sent, delta.received, false)
+
+		deltas[sessionID] = delta
+		current[sessionID] = currentState
 	}
-	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(s.accumulator); err != nil {
-		return fmt.Errorf("failed to encode accumulator: %w", err)
+	// Count sessions that ended
+	for sessionID, prevState := range previous {
+		if _, stillConnected := current[sessionID]; !stillConnected {
+			s.accumulator.OpenVPN.SessionCount++
+			s.addClientTotals(prevState.CommonName, 0, 0, true)
+			s.recordEndedSession(prevState)
+		}
 	}
 
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to sync file: %w", err)
+	return deltas
+}
+
+// sessionCounterDelta returns the growth of a session counter. Session IDs
+// include the connection time, so a counter never legitimately goes backwards
+// within one session; a lower value is a stale reading and adds nothing.
+func sessionCounterDelta(current, previous uint64) uint64 {
+	if current > previous {
+		return current - previous
 	}
+	return 0
+}
 
-	return nil
+// addClientTotals credits traffic to a client's per-period totals
+func (s *BandwidthService) addClientTotals(commonName string, sent, received uint64, sessionEnded bool) {
+	addTotals(s.accumulator.ClientTotals, commonName, sent, received, sessionEnded)
+}
+
+// addTotals credits traffic to a keyed set of per-period totals
+func addTotals(totalsByKey map[string]models.AccumulatedData, key string, sent, received uint64, sessionEnded bool) {
+	totals := totalsByKey[key]
+	totals.TotalBytesSent += sent
+	totals.TotalBytesReceived += received
+	totals.TotalBandwidthMB = float64(totals.TotalBytesSent+totals.TotalBytesReceived) / (1024 * 1024)
+	if sessionEnded {
+		totals.SessionCount++
+	}
+	totalsByKey[key] = totals
+}
+
+// ipsecDelta computes the bytes sent (TX) and received (RX) between two counter
+// observations, per interface and per direction.
+//
+// When the container ID or start time differs from the baseline the container
+// has restarted and its counters began again from zero, so the current values
+// are counted in full. The same applies to a single interface whose counter went
+// backwards. Without a baseline (first run, or an accumulator written by an older
+// version) the current values are counted only if the container started after
+// the accumulator was last reset; otherwise they are taken as the new baseline.
+func ipsecDelta(prev, cur *models.IPSecCounterState, lastResetAt time.Time) (sent, received uint64, reset bool) {
+	if prev == nil {
+		if cur.StartedAt.After(lastResetAt) {
+			for _, counters := range cur.Interfaces {
+				sent += counters.TxBytes
+				received += counters.RxBytes
+			}
+		}
+		return sent, received, false
+	}
+
+	if prev.ContainerID != cur.ContainerID || !prev.StartedAt.Equal(cur.StartedAt) {
+		for _, counters := range cur.Interfaces {
+			sent += counters.TxBytes
+			received += counters.RxBytes
+		}
+		return sent, received, true
+	}
+
+	for name, counters := range cur.Interfaces {
+		before, ok := prev.Interfaces[name]
+		if !ok {
+			// Interface appeared since the last tick; all of its traffic is new
+			sent += counters.TxBytes
+			received += counters.RxBytes
+			continue
+		}
+
+		if counters.TxBytes >= before.TxBytes {
+			sent += counters.TxBytes - before.TxBytes
+		} else {
+			sent += counters.TxBytes
+			reset = true
+		}
+
+		if counters.RxBytes >= before.RxBytes {
+			received += counters.RxBytes - before.RxBytes
+		} else {
+			received += counters.RxBytes
+			reset = true
+		}
+	}
+
+	return sent, received, reset
+}
+
+// saveAccumulator persists the accumulator to disk. Caller must hold s.mu.
+func (s *BandwidthService) saveAccumulator() error {
+	return s.store.save(s.accumulator)
 }
 
 // GetAccumulatedMetrics returns the accumulated bandwidth metrics
@@ -352,7 +756,14 @@ func (s *BandwidthService) GetAccumulatedMetrics() (*models.BandwidthMetrics, er
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	return s.accumulatedMetrics(), nil
+}
+
+// accumulatedMetrics builds metrics from the accumulator. Caller must hold s.mu.
+func (s *BandwidthService) accumulatedMetrics() *models.BandwidthMetrics {
 	combinedTotalMB := s.accumulator.OpenVPN.TotalBandwidthMB + s.accumulator.IPSec.TotalBandwidthMB
+	openvpnRate := s.openvpnRate
+	ipsecRate := s.ipsecRate
 
 	return &models.BandwidthMetrics{
 		Timestamp: s.accumulator.LastUpdated,
@@ -361,28 +772,64 @@ func (s *BandwidthService) GetAccumulatedMetrics() (*models.BandwidthMetrics, er
 			TotalBytesReceived: s.accumulator.OpenVPN.TotalBytesReceived,
 			TotalBandwidthMB:   s.accumulator.OpenVPN.TotalBandwidthMB,
 			ActiveClients:      len(s.accumulator.ClientStates),
+			Rate:               &openvpnRate,
 		},
 		IPSec: models.IPSecMetrics{
 			TotalBytesSent:     s.accumulator.IPSec.TotalBytesSent,
 			TotalBytesReceived: s.accumulator.IPSec.TotalBytesReceived,
 			TotalBandwidthMB:   s.accumulator.IPSec.TotalBandwidthMB,
+			Rate:               &ipsecRate,
 		},
+		Host:            s.accumulatedHostMetrics(),
 		CombinedTotalMB: combinedTotalMB,
-	}, nil
+	}
 }
 
-// ResetAccumulator resets the bandwidth accumulator to zero
+// accumulatedHostMetrics reports per-period host interface totals, or nil when
+// no host interface collector has run. Caller must hold s.mu.
+func (s *BandwidthService) accumulatedHostMetrics() *models.HostMetrics {
+	if s.accumulator.HostState == nil {
+		return nil
+	}
+
+	egressRate := s.egressRate
+	host := &models.HostMetrics{
+		EgressInterface:     s.accumulator.HostState.EgressInterface,
+		EgressBytesSent:     s.accumulator.Egress.TotalBytesSent,
+		EgressBytesReceived: s.accumulator.Egress.TotalBytesReceived,
+		EgressBandwidthMB:   s.accumulator.Egress.TotalBandwidthMB,
+		EgressRate:          &egressRate,
+		Interfaces:          make([]models.InterfaceMetrics, 0, len(s.accumulator.InterfaceTotals)),
+	}
+
+	for name, totals := range s.accumulator.InterfaceTotals {
+		current := s.hostIfaces[name]
+		host.Interfaces = append(host.Interfaces, models.InterfaceMetrics{
+			Name:          name,
+			User:          current.User,
+			BytesSent:     totals.TotalBytesSent,
+			BytesReceived: totals.TotalBytesReceived,
+			BandwidthMB:   totals.TotalBandwidthMB,
+			Rate:          current.Rate,
+		})
+	}
+
+	sort.Slice(host.Interfaces, func(i, j int) bool {
+		return host.Interfaces[i].Name < host.Interfaces[j].Name
+	})
+
+	return host
+}
+
+// ResetAccumulator archives the current period and resets the accumulator to zero
 func (s *BandwidthService) ResetAccumulator() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now().UTC()
-	s.accumulator = &models.BandwidthAccumulator{
-		LastResetAt:  now,
-		LastUpdated:  now,
-		ClientStates: make(map[string]models.ClientState),
+	if err := s.closePeriod(now, now, "manual"); err != nil {
+		return err
 	}
-	s.lastIPSecBytes = 0
 
 	if err := s.saveAccumulator(); err != nil {
 		return fmt.Errorf("failed to save reset accumulator: %w", err)
@@ -392,139 +839,202 @@ func (s *BandwidthService) ResetAccumulator() error {
 	return nil
 }
 
-// GetMetrics collects and returns aggregated bandwidth metrics from both OpenVPN and IPSec
-// This is the original snapshot method, kept for backward compatibility
-func (s *BandwidthService) GetMetrics() (*models.BandwidthMetrics, error) {
-	openvpnMetrics, err := s.collectOpenVPNMetrics()
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect OpenVPN metrics: %w", err)
+// closeDuePeriods archives every billing period whose boundary has passed.
+// Periods are aligned to the cycle, so after a long downtime the gap is
+// closed as consecutive (possibly empty) periods. Caller must hold s.mu.
+func (s *BandwidthService) closeDuePeriods(now time.Time) error {
+	if !s.billing.Enabled() {
+		return nil
 	}
 
-	ipsecMetrics, err := s.collectIPSecMetrics()
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect IPSec metrics: %w", err)
+	for {
+		boundary := s.billing.NextBoundary(s.accumulator.LastResetAt)
+		if boundary.IsZero() || boundary.After(now) {
+			return nil
+		}
+
+		if err := s.closePeriod(boundary, now, "scheduled"); err != nil {
+			return err
+		}
+		s.logger.Info("Billing period closed", "end", boundary)
 	}
+}
 
-	// Calculate combined total in MB
-	combinedTotalMB := openvpnMetrics.TotalBandwidthMB + ipsecMetrics.TotalBandwidthMB
+// closePeriod archives the accumulator as a period ending at end and starts a
+// new one from there. Session and counter baselines are carried over so that
+// traffic continuing across the boundary is measured from this point.
+// Caller must hold s.mu.
+func (s *BandwidthService) closePeriod(end, closedAt time.Time, reason string) error {
+	acc := s.accumulator
+
+	period := &models.BandwidthPeriod{
+		Start:           acc.LastResetAt,
+		End:             end,
+		ClosedAt:        closedAt,
+		Reason:          reason,
+		OpenVPN:         acc.OpenVPN,
+		IPSec:           acc.IPSec,
+		CombinedTotalMB: acc.OpenVPN.TotalBandwidthMB + acc.IPSec.TotalBandwidthMB,
+		Clients:         acc.ClientTotals,
+		Egress:          acc.Egress,
+		Interfaces:      acc.InterfaceTotals,
+		PPPUsers:        acc.PPPUserTotals,
+		RadiusUsers:     acc.RadiusUserTotals,
+	}
+	if err := s.periods.save(period); err != nil {
+		return fmt.Errorf("failed to archive billing period: %w", err)
+	}
 
-	return &models.BandwidthMetrics{
-		Timestamp:       time.Now().UTC(),
-		OpenVPN:         *openvpnMetrics,
-		IPSec:           *ipsecMetrics,
-		CombinedTotalMB: combinedTotalMB,
-	}, nil
+	next := newAccumulator(end)
+	next.LastUpdated = closedAt
+	next.ClientStates = acc.ClientStates
+	next.IPSecState = acc.IPSecState
+	next.HostState = acc.HostState
+	next.RadiusSessions = acc.RadiusSessions
+	next.HookedOpenVPNSessions = acc.HookedOpenVPNSessions
+	next.HookedPPPSessions = acc.HookedPPPSessions
+	s.accumulator = next
+
+	return nil
 }
 
-// collectOpenVPNMetrics parses the OpenVPN status log and extracts bandwidth metrics
-func (s *BandwidthService) collectOpenVPNMetrics() (*models.OpenVPNMetrics, error) {
-	file, err := os.Open(openVPNStatusFile)
-	if err != nil {
-		// If OpenVPN is not installed or status file doesn't exist, return zeros
-		if os.IsNotExist(err) {
-			return &models.OpenVPNMetrics{}, nil
-		}
-		return nil, fmt.Errorf("failed to open OpenVPN status file: %w", err)
-	}
-	defer file.Close()
-
-	var totalBytesSent uint64
-	var totalBytesReceived uint64
-	var activeClients int
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Parse CLIENT_LIST lines which contain bandwidth data
-		// Format: CLIENT_LIST,client_name,real_ip:port,virtual_ip,virtual_ipv6,bytes_received,bytes_sent,connected_since,username,client_id,peer_id
-		if strings.HasPrefix(line, "CLIENT_LIST") {
-			fields := strings.Split(line, ",")
-			if len(fields) >= 7 {
-				// bytes_received is at index 5, bytes_sent is at index 6
-				bytesReceived, err1 := strconv.ParseUint(fields[5], 10, 64)
-				bytesSent, err2 := strconv.ParseUint(fields[6], 10, 64)
-
-				if err1 == nil && err2 == nil {
-					totalBytesReceived += bytesReceived
-					totalBytesSent += bytesSent
-					activeClients++
-				}
+// ListPeriods returns summaries of archived billing periods, newest first
+func (s *BandwidthService) ListPeriods() ([]models.BandwidthPeriodSummary, error) {
+	return s.periods.list()
+}
+
+// GetPeriod returns an archived billing period by ID
+func (s *BandwidthService) GetPeriod(id string) (*models.BandwidthPeriod, error) {
+	return s.periods.get(id)
+}
+
+// GetMetrics runs every enabled collector once and returns the current
+// cumulative counters as reported by the sources, without touching the accumulator.
+// This is the original snapshot method, kept for backward compatibility; a
+// failing collector contributes zeros rather than failing the request
+func (s *BandwidthService) GetMetrics() (*models.BandwidthMetrics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var openvpnMetrics models.OpenVPNMetrics
+	var ipsecMetrics models.IPSecMetrics
+	var hostMetrics *models.HostMetrics
+
+	for _, entry := range s.collectors.enabledEntries() {
+		sample, err := entry.collector.Collect(ctx)
+		if err != nil {
+			// A failing source counts as zero, as before collectors existed
+			s.logger.Warn("Failed to collect metrics", "collector", entry.collector.Name(), "error", err.Error())
+			continue
+		}
+
+		if sample.OpenVPN != nil {
+			for _, client := range sample.OpenVPN.Clients {
+				openvpnMetrics.TotalBytesSent += client.BytesSent
+				openvpnMetrics.TotalBytesReceived += client.BytesReceived
+				openvpnMetrics.ActiveClients++
 			}
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading OpenVPN status file: %w", err)
+		if sample.IPSec != nil && sample.IPSec.Counters != nil {
+			// Docker provides network stats per interface
+			for _, counters := range sample.IPSec.Counters.Interfaces {
+				ipsecMetrics.TotalBytesReceived += counters.RxBytes
+				ipsecMetrics.TotalBytesSent += counters.TxBytes
+			}
+		}
+
+		if sample.Host != nil {
+			hostMetrics = currentHostMetrics(sample.Host)
+		}
 	}
 
 	// Convert bytes to MB
-	totalBytes := totalBytesSent + totalBytesReceived
-	totalBandwidthMB := float64(totalBytes) / (1024 * 1024)
-
-	return &models.OpenVPNMetrics{
-		TotalBytesSent:     totalBytesSent,
-		TotalBytesReceived: totalBytesReceived,
-		TotalBandwidthMB:   totalBandwidthMB,
-		ActiveClients:      activeClients,
+	openvpnMetrics.TotalBandwidthMB = float64(openvpnMetrics.TotalBytesSent+openvpnMetrics.TotalBytesReceived) / (1024 * 1024)
+	ipsecMetrics.TotalBandwidthMB = float64(ipsecMetrics.TotalBytesSent+ipsecMetrics.TotalBytesReceived) / (1024 * 1024)
+
+	// Attach the rates measured by the tracking loop
+	s.mu.RLock()
+	openvpnRate := s.openvpnRate
+	ipsecRate := s.ipsecRate
+	s.mu.RUnlock()
+	openvpnMetrics.Rate = &openvpnRate
+	ipsecMetrics.Rate = &ipsecRate
+	if hostMetrics != nil {
+		s.mu.RLock()
+		egressRate := s.egressRate
+		for i, iface := range hostMetrics.Interfaces {
+			hostMetrics.Interfaces[i].Rate = s.hostIfaces[iface.Name].Rate
+		}
+		s.mu.RUnlock()
+		hostMetrics.EgressRate = &egressRate
+	}
+
+	// Calculate combined total in MB
+	combinedTotalMB := openvpnMetrics.TotalBandwidthMB + ipsecMetrics.TotalBandwidthMB
+
+	return &models.BandwidthMetrics{
+		Timestamp:       time.Now().UTC(),
+		OpenVPN:         openvpnMetrics,
+		IPSec:           ipsecMetrics,
+		Host:            hostMetrics,
+		CombinedTotalMB: combinedTotalMB,
 	}, nil
 }
 
-// collectIPSecMetrics uses Docker Stats API to get IPSec container network metrics
-func (s *BandwidthService) collectIPSecMetrics() (*models.IPSecMetrics, error) {
-	ctx := context.Background()
+// currentHostMetrics converts raw host counters into metrics
+func currentHostMetrics(sample *HostSample) *models.HostMetrics {
+	egress := sample.Counters.Interfaces[sample.Counters.EgressInterface]
 
-	// Get container stats (oneshot, not streaming)
-	stats, err := s.dockerClient.ContainerStats(ctx, ipsecContainerName, false)
-	if err != nil {
-		// If container doesn't exist or is not running, return zeros
-		return &models.IPSecMetrics{}, nil
+	host := &models.HostMetrics{
+		EgressInterface:     sample.Counters.EgressInterface,
+		EgressBytesSent:     egress.TxBytes,
+		EgressBytesReceived: egress.RxBytes,
+		EgressBandwidthMB:   float64(egress.TxBytes+egress.RxBytes) / (1024 * 1024),
+		Interfaces:          make([]models.InterfaceMetrics, 0, len(sample.Counters.Interfaces)),
 	}
-	defer stats.Body.Close()
 
-	// Parse the stats JSON
-	var containerStats container.StatsResponse
-	if err := json.NewDecoder(stats.Body).Decode(&containerStats); err != nil {
-		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	for name, counters := range sample.Counters.Interfaces {
+		host.Interfaces = append(host.Interfaces, models.InterfaceMetrics{
+			Name:          name,
+			User:          sample.PPPUsers[name],
+			BytesSent:     counters.TxBytes,
+			BytesReceived: counters.RxBytes,
+			BandwidthMB:   float64(counters.TxBytes+counters.RxBytes) / (1024 * 1024),
+		})
 	}
 
-	// Extract network metrics
-	var totalBytesReceived uint64
-	var totalBytesSent uint64
-
-	// Docker provides network stats per interface
-	for _, network := range containerStats.Networks {
-		totalBytesReceived += network.RxBytes
-		totalBytesSent += network.TxBytes
-	}
+	sort.Slice(host.Interfaces, func(i, j int) bool {
+		return host.Interfaces[i].Name < host.Interfaces[j].Name
+	})
 
-	// Convert bytes to MB
-	totalBytes := totalBytesSent + totalBytesReceived
-	totalBandwidthMB := float64(totalBytes) / (1024 * 1024)
+	return host
+}
 
-	return &models.IPSecMetrics{
-		TotalBytesSent:     totalBytesSent,
-		TotalBytesReceived: totalBytesReceived,
-		TotalBandwidthMB:   totalBandwidthMB,
-	}, nil
+// CollectorStatuses reports the health of every registered collector
+func (s *BandwidthService) CollectorStatuses() []models.CollectorStatus {
+	return s.collectors.Statuses()
 }
 
-// Close stops the background tracking and closes the Docker client connection
+// Close stops the background tracking and releases collector resources
 func (s *BandwidthService) Close() error {
-	// Stop the ticker if running
-	if s.ticker != nil {
-		s.ticker.Stop()
-	}
-
-	// Signal the goroutine to stop
+	// Signal the goroutines to stop
 	close(s.done)
 
-	// Wait for goroutine to finish
+	// Wait for goroutines to finish
 	s.wg.Wait()
 
-	// Close Docker client
-	if s.dockerClient != nil {
-		return s.dockerClient.Close()
+	// Release stream subscribers
+	s.hub.close()
+
+	// Close collectors that hold connections
+	var closeErr error
+	for _, collector := range s.collectors.collectors() {
+		if closer, ok := collector.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				closeErr = errors.Join(closeErr, err)
+			}
+		}
 	}
-	return nil
+	return closeErr
 }
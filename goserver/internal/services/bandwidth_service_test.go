@@ -0,0 +1,404 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+func counterState(id string, started time.Time, rx, tx uint64) *models.IPSecCounterState {
+	return &models.IPSecCounterState{
+		ContainerID: id,
+		StartedAt:   started,
+		Interfaces: map[string]models.InterfaceCounters{
+			"eth0": {RxBytes: rx, TxBytes: tx},
+		},
+	}
+}
+
+func TestIPSecDelta(t *testing.T) {
+	started := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastReset := started.Add(-time.Hour)
+
+	tests := []struct {
+		name         string
+		prev, cur    *models.IPSecCounterState
+		lastResetAt  time.Time
+		wantSent     uint64
+		wantReceived uint64
+		wantReset    bool
+	}{
+		{
+			name:         "directional delta",
+			prev:         counterState("a", started, 100, 50),
+			cur:          counterState("a", started, 400, 70),
+			lastResetAt:  lastReset,
+			wantSent:     20,
+			wantReceived: 300,
+		},
+		{
+			name:         "container restart counts post-reset bytes",
+			prev:         counterState("a", started, 1000, 1000),
+			cur:          counterState("a", started.Add(time.Minute), 30, 10),
+			lastResetAt:  lastReset,
+			wantSent:     10,
+			wantReceived: 30,
+			wantReset:    true,
+		},
+		{
+			name:         "recreated container",
+			prev:         counterState("a", started, 10, 10),
+			cur:          counterState("b", started, 5, 7),
+			lastResetAt:  lastReset,
+			wantSent:     7,
+			wantReceived: 5,
+			wantReset:    true,
+		},
+		{
+			name:         "no baseline, container started after reset",
+			cur:          counterState("a", started, 5, 7),
+			lastResetAt:  lastReset,
+			wantSent:     7,
+			wantReceived: 5,
+		},
+		{
+			name:        "no baseline, container predates reset",
+			cur:         counterState("a", started, 5, 7),
+			lastResetAt: started.Add(time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent, received, reset := ipsecDelta(tt.prev, tt.cur, tt.lastResetAt)
+			if sent != tt.wantSent || received != tt.wantReceived || reset != tt.wantReset {
+				t.Fatalf("got sent=%d received=%d reset=%v, want sent=%d received=%d reset=%v",
+					sent, received, reset, tt.wantSent, tt.wantReceived, tt.wantReset)
+			}
+		})
+	}
+}
+
+func TestCalculateOpenVPNDeltasDuplicateCommonName(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	session := func(clientID int64, connected time.Time, sent, received uint64) models.ClientState {
+		return models.ClientState{
+			CommonName:     "alice",
+			ClientID:       clientID,
+			PeerID:         clientID,
+			ConnectedSince: connected,
+			BytesSent:      sent,
+			BytesReceived:  received,
+		}
+	}
+	keyed := func(states ...models.ClientState) map[string]models.ClientState {
+		m := make(map[string]models.ClientState, len(states))
+		for _, state := range states {
+			m[openVPNSessionID(state)] = state
+		}
+		return m
+	}
+
+	s := &BandwidthService{accumulator: newAccumulator(base)}
+
+	// Two simultaneous sessions of one certificate that predate the first observation
+	previous := keyed(session(1, base.Add(-time.Hour), 100, 10), session(2, base.Add(-time.Minute), 500, 50))
+	s.calculateOpenVPNDeltas(previous, nil, map[string]models.ClientState{}, base, time.Minute)
+	if s.accumulator.OpenVPN.TotalBytesSent != 0 {
+		t.Fatalf("sessions older than the baseline must not be counted, got %d", s.accumulator.OpenVPN.TotalBytesSent)
+	}
+
+	// Session 1 grows, session 2 disconnects, session 3 connects after the last observation
+	current := keyed(session(1, base.Add(-time.Hour), 160, 30), session(3, base.Add(30*time.Second), 40, 4))
+	deltas := s.calculateOpenVPNDeltas(current, nil, previous, base, time.Minute)
+
+	if got := s.accumulator.OpenVPN.TotalBytesSent; got != 60+40 {
+		t.Errorf("total sent = %d, want 100 (no double count for the ended session)", got)
+	}
+	if got := s.accumulator.OpenVPN.TotalBytesReceived; got != 20+4 {
+		t.Errorf("total received = %d, want 24", got)
+	}
+	if got := s.accumulator.OpenVPN.SessionCount; got != 1 {
+		t.Errorf("session count = %d, want 1", got)
+	}
+	if len(deltas) != 2 {
+		t.Errorf("got %d session deltas, want 2", len(deltas))
+	}
+
+	totals := s.accumulator.ClientTotals["alice"]
+	if totals.TotalBytesSent != 100 || totals.TotalBytesReceived != 24 || totals.SessionCount != 1 {
+		t.Errorf("unexpected per-user rollup: %+v", totals)
+	}
+}
+
+func TestOpenVPNSessionID(t *testing.T) {
+	connected := time.Unix(1715340600, 0)
+	withIDs := models.ClientState{CommonName: "alice", ClientID: 7, PeerID: 1, ConnectedSince: connected}
+	withoutIDs := models.ClientState{CommonName: "alice", RealAddress: "203.0.113.10:50123", ClientID: -1, PeerID: -1, ConnectedSince: connected}
+
+	if got := openVPNSessionID(withIDs); got != "7/1/1715340600" {
+		t.Errorf("session ID = %q", got)
+	}
+	if got := openVPNSessionID(withoutIDs); got != "alice@203.0.113.10:50123/1715340600" {
+		t.Errorf("session ID = %q", got)
+	}
+}
+
+func TestCalculateOpenVPNDeltasEndedSessions(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := &BandwidthService{accumulator: newAccumulator(base)}
+
+	known := models.ClientState{CommonName: "alice", ClientID: 1, PeerID: 1, ConnectedSince: base.Add(-time.Hour), BytesSent: 100, BytesReceived: 10}
+	previous := map[string]models.ClientState{openVPNSessionID(known): known}
+
+	// alice disconnected with final counters; bob came and went between observations
+	final := known
+	final.BytesSent, final.BytesReceived = 150, 15
+	brief := models.ClientState{CommonName: "bob", ClientID: 2, PeerID: -1, ConnectedSince: base.Add(10 * time.Second), BytesSent: 7, BytesReceived: 3}
+	ended := map[string]models.ClientState{
+		openVPNSessionID(final): final,
+		openVPNSessionID(brief): brief,
+	}
+
+	s.calculateOpenVPNDeltas(map[string]models.ClientState{}, ended, previous, base, time.Minute)
+
+	if got := s.accumulator.OpenVPN.TotalBytesSent; got != 50+7 {
+		t.Errorf("total sent = %d, want 57", got)
+	}
+	if got := s.accumulator.OpenVPN.SessionCount; got != 2 {
+		t.Errorf("session count = %d, want 2", got)
+	}
+	if bob := s.accumulator.ClientTotals["bob"]; bob.TotalBytesReceived != 3 || bob.SessionCount != 1 {
+		t.Errorf("unexpected totals for bob: %+v", bob)
+	}
+}
+
+func TestRecordOpenVPNSession(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	s := &BandwidthService{
+		store:        newAccumulatorStore(t.TempDir(), 0),
+		accumulator:  newAccumulator(base),
+		clientDeltas: make(map[string]clientDelta),
+	}
+
+	// Neither a hook nor a collector saw the session start
+	forged := models.OpenVPNSessionEvent{CommonName: "mallory", ConnectedAt: base.Add(time.Minute), BytesSent: 1 << 40}
+	if err := s.RecordOpenVPNSession(forged); !errors.Is(err, ErrUnknownSession) {
+		t.Errorf("expected an unknown session to be rejected, got %v", err)
+	}
+
+	// Shorter than a collection interval: never observed, counted in full
+	brief := models.OpenVPNSessionEvent{CommonName: "bob", ConnectedAt: base.Add(time.Minute), BytesSent: 70, BytesReceived: 30}
+	s.RecordOpenVPNConnect(brief)
+	if err := s.RecordOpenVPNSession(brief); err != nil {
+		t.Fatal(err)
+	}
+	if bob := s.accumulator.ClientTotals["bob"]; bob.TotalBytesSent != 70 || bob.TotalBytesReceived != 30 || bob.SessionCount != 1 {
+		t.Errorf("unexpected totals for bob: %+v", bob)
+	}
+
+	// A retried callback adds nothing
+	s.RecordOpenVPNSession(brief)
+	if got := s.accumulator.OpenVPN.TotalBytesSent; got != 70 {
+		t.Errorf("total sent after retry = %d, want 70", got)
+	}
+
+	// Observed by a collector: only the part after the last observation is added
+	observed := models.ClientState{CommonName: "alice", ClientID: 1, PeerID: 1, ConnectedSince: base.Add(2 * time.Minute), BytesSent: 100, BytesReceived: 10}
+	s.accumulator.ClientStates[openVPNSessionID(observed)] = observed
+	s.accumulator.OpenVPN.TotalBytesSent += 100
+	s.accumulator.OpenVPN.TotalBytesReceived += 10
+	s.addClientTotals("alice", 100, 10, false)
+
+	if err := s.RecordOpenVPNSession(models.OpenVPNSessionEvent{CommonName: "alice", ConnectedAt: observed.ConnectedSince, BytesSent: 180, BytesReceived: 12}); err != nil {
+		t.Fatal(err)
+	}
+	if alice := s.accumulator.ClientTotals["alice"]; alice.TotalBytesSent != 180 || alice.TotalBytesReceived != 12 || alice.SessionCount != 1 {
+		t.Errorf("unexpected totals for alice: %+v", alice)
+	}
+	if len(s.accumulator.ClientStates) != 0 {
+		t.Errorf("finalised session still tracked: %v", s.accumulator.ClientStates)
+	}
+
+	// A status file written before the disconnect still lists alice
+	stale := observed
+	stale.BytesSent = 150
+	s.applyOpenVPNSample(&OpenVPNSample{Clients: map[string]models.ClientState{openVPNSessionID(stale): stale}}, time.Minute)
+	s.applyOpenVPNSample(&OpenVPNSample{Clients: map[string]models.ClientState{}}, time.Minute)
+	if got := s.accumulator.OpenVPN; got.TotalBytesSent != 250 || got.SessionCount != 2 {
+		t.Errorf("stale status counted again: %+v", got)
+	}
+}
+
+func TestRecordOpenVPNConnectSurvivesRestart(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	store := newAccumulatorStore(t.TempDir(), 0)
+	s := &BandwidthService{store: store, accumulator: newAccumulator(base), clientDeltas: make(map[string]clientDelta)}
+
+	event := models.OpenVPNSessionEvent{CommonName: "bob", ConnectedAt: base.Add(time.Minute), BytesSent: 70, BytesReceived: 30}
+	if err := s.RecordOpenVPNConnect(event); err != nil {
+		t.Fatal(err)
+	}
+
+	acc, err := store.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := &BandwidthService{store: store, accumulator: acc, clientDeltas: make(map[string]clientDelta)}
+	if err := restarted.RecordOpenVPNSession(event); err != nil {
+		t.Fatalf("disconnect after restart: %v", err)
+	}
+	if bob := restarted.accumulator.ClientTotals["bob"]; bob.TotalBytesSent != 70 || bob.SessionCount != 1 {
+		t.Errorf("unexpected totals for bob: %+v", bob)
+	}
+	if len(restarted.accumulator.HookedOpenVPNSessions) != 0 {
+		t.Errorf("finalised session still hooked: %v", restarted.accumulator.HookedOpenVPNSessions)
+	}
+}
+
+func TestRecordOpenVPNSessionAfterCollectorEnded(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	s := &BandwidthService{
+		store:        newAccumulatorStore(t.TempDir(), 0),
+		accumulator:  newAccumulator(base),
+		clientDeltas: make(map[string]clientDelta),
+	}
+
+	known := models.ClientState{CommonName: "alice", ClientID: 1, PeerID: 1, ConnectedSince: base.Add(time.Minute), BytesSent: 100, BytesReceived: 10}
+	previous := map[string]models.ClientState{openVPNSessionID(known): known}
+
+	// The status collector notices the disconnect before the hook arrives
+	s.calculateOpenVPNDeltas(map[string]models.ClientState{}, nil, previous, base, time.Minute)
+	if err := s.RecordOpenVPNSession(models.OpenVPNSessionEvent{CommonName: "alice", ConnectedAt: known.ConnectedSince, BytesSent: 130, BytesReceived: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if alice := s.accumulator.ClientTotals["alice"]; alice.TotalBytesSent != 30 || alice.SessionCount != 1 {
+		t.Errorf("unexpected totals for alice: %+v", alice)
+	}
+	if got := s.accumulator.OpenVPN.SessionCount; got != 1 {
+		t.Errorf("session count = %d, want 1", got)
+	}
+}
+
+func TestRecordPPPSession(t *testing.T) {
+	s := &BandwidthService{
+		store:       newAccumulatorStore(t.TempDir(), 0),
+		accumulator: newAccumulator(time.Now().UTC().Add(-time.Hour)),
+		hostIfaces:  make(map[string]models.InterfaceMetrics),
+	}
+	hostSample := func(ifaces map[string]models.InterfaceCounters, users map[string]string) *HostSample {
+		return &HostSample{Counters: &models.HostCounterState{Interfaces: ifaces}, PPPUsers: users}
+	}
+
+	if err := s.RecordPPPSession(models.PPPSessionEvent{Interface: "ppp9", Peer: "mallory", BytesSent: 1 << 40}, time.Now()); !errors.Is(err, ErrUnknownSession) {
+		t.Errorf("expected an unknown ppp session to be rejected, got %v", err)
+	}
+
+	// Without the host collector the hook totals count in full, also when
+	// goserver restarted in between
+	if err := s.RecordPPPConnect(models.PPPSessionEvent{Interface: "ppp0", Peer: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	acc, err := s.store.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.accumulator = acc
+	if err := s.RecordPPPSession(models.PPPSessionEvent{Interface: "ppp0", Peer: "alice", BytesSent: 500, BytesReceived: 50, ConnectTime: 30}, time.Now().Add(-30*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if alice := s.accumulator.PPPUserTotals["alice"]; alice.TotalBytesSent != 500 || alice.TotalBytesReceived != 50 || alice.SessionCount != 1 {
+		t.Fatalf("unexpected totals for alice: %+v", alice)
+	}
+
+	// With the host collector only the unobserved remainder is added
+	s.applyHostSample(hostSample(map[string]models.InterfaceCounters{}, nil), time.Minute)
+	s.applyHostSample(hostSample(map[string]models.InterfaceCounters{"ppp1": {TxBytes: 300, RxBytes: 30}}, map[string]string{"ppp1": "bob"}), time.Minute)
+	connected := time.Now().Add(-time.Minute)
+	if err := s.RecordPPPSession(models.PPPSessionEvent{Interface: "ppp1", Peer: "bob", BytesSent: 420, BytesReceived: 35}, connected); err != nil {
+		t.Fatal(err)
+	}
+	if bob := s.accumulator.PPPUserTotals["bob"]; bob.TotalBytesSent != 420 || bob.TotalBytesReceived != 35 || bob.SessionCount != 1 {
+		t.Fatalf("unexpected totals for bob: %+v", bob)
+	}
+
+	// The interface lingers for one more sample, then goes away; a retried
+	// callback adds nothing either
+	s.applyHostSample(hostSample(map[string]models.InterfaceCounters{"ppp1": {TxBytes: 420, RxBytes: 35}}, map[string]string{"ppp1": "bob"}), time.Minute)
+	s.applyHostSample(hostSample(map[string]models.InterfaceCounters{}, nil), time.Minute)
+	s.RecordPPPSession(models.PPPSessionEvent{Interface: "ppp1", Peer: "bob", BytesSent: 420, BytesReceived: 35}, connected)
+	if bob := s.accumulator.PPPUserTotals["bob"]; bob.TotalBytesSent != 420 || bob.SessionCount != 1 {
+		t.Errorf("session counted twice: %+v", bob)
+	}
+
+	// A new session on the same interface is attributed again
+	s.applyHostSample(hostSample(map[string]models.InterfaceCounters{"ppp1": {TxBytes: 10, RxBytes: 1}}, map[string]string{"ppp1": "bob"}), time.Minute)
+	if bob := s.accumulator.PPPUserTotals["bob"]; bob.TotalBytesSent != 430 {
+		t.Errorf("new session not attributed: %+v", bob)
+	}
+}
+
+func TestApplyIPSecSampleWithoutConnections(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Hour)
+	s := &BandwidthService{
+		accumulator: newAccumulator(base),
+		ipsecConns:  map[string]models.IPSecConnection{"l2tp-psk[1]": {Connection: "l2tp-psk[1]"}},
+		logger:      slog.New(slog.DiscardHandler),
+	}
+	counters := func(rx, tx uint64) *models.IPSecCounterState {
+		return &models.IPSecCounterState{
+			ContainerID: "c1",
+			StartedAt:   base.Add(-time.Hour),
+			Interfaces:  map[string]models.InterfaceCounters{"eth0": {RxBytes: rx, TxBytes: tx}},
+		}
+	}
+
+	s.applyIPSecSample(&IPSecSample{Counters: counters(100, 200)}, time.Minute)
+	s.applyIPSecSample(&IPSecSample{Counters: counters(150, 260), ConnectionsErr: errors.New("exec failed")}, time.Minute)
+
+	if got := s.accumulator.IPSec; got.TotalBytesReceived != 50 || got.TotalBytesSent != 60 {
+		t.Errorf("counters dropped with the connections: %+v", got)
+	}
+	if len(s.ipsecConns) != 0 {
+		t.Fatalf("first sample should have replaced the connections: %v", s.ipsecConns)
+	}
+
+	s.ipsecConns = map[string]models.IPSecConnection{"l2tp-psk[1]": {Connection: "l2tp-psk[1]"}}
+	s.applyIPSecSample(&IPSecSample{Counters: counters(160, 270), ConnectionsErr: errors.New("exec failed")}, time.Minute)
+	if len(s.ipsecConns) != 1 {
+		t.Errorf("known connections lost on a failed listing: %v", s.ipsecConns)
+	}
+}
+
+func TestUserSessions(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	s := &BandwidthService{
+		accumulator: newAccumulator(base),
+		ipsecConns: map[string]models.IPSecConnection{
+			"xauth-psk[2]": {Serial: "#7", Connection: "xauth-psk[2]", Username: "alice", RemoteAddress: "198.51.100.4"},
+			"l2tp-psk[1]":  {Serial: "#3", Connection: "l2tp-psk[1]", Username: "bob"},
+		},
+	}
+	s.accumulator.ClientStates = map[string]models.ClientState{
+		"alice@192.0.2.1:1194": {CommonName: "alice", RealAddress: "192.0.2.1:1194", ClientID: 4, BytesSent: 10, ConnectedSince: base},
+		"bob@192.0.2.2:1194":   {CommonName: "bob", RealAddress: "192.0.2.2:1194", ClientID: 5},
+	}
+
+	sessions := s.UserSessions("alice")
+	if len(sessions) != 2 {
+		t.Fatalf("expected two sessions of alice, got %+v", sessions)
+	}
+	if got := sessions[0]; got.ID != "openvpn-4" || got.Protocol != models.ProtocolOpenVPN || got.BytesSent != 10 || got.ConnectedSince == nil {
+		t.Errorf("unexpected OpenVPN session %+v", got)
+	}
+	if got := sessions[1]; got.ID != "ipsec-7" || got.Protocol != models.ProtocolIPSecXAuth || got.User != "alice" {
+		t.Errorf("unexpected IPsec session %+v", got)
+	}
+	if sessions := s.UserSessions("carol"); sessions == nil || len(sessions) != 0 {
+		t.Errorf("expected no sessions of carol, got %+v", sessions)
+	}
+}
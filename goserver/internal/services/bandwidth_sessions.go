@@ -0,0 +1,311 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// ErrUnknownSession is returned for disconnect reports of sessions that no
+// connect hook or collector saw start
+var ErrUnknownSession = errors.New("session was never seen connecting")
+
+// connectedSessionRetention bounds how long a connect report waits for its
+// disconnect, in case the disconnect hook never ran
+const connectedSessionRetention = 31 * 24 * time.Hour
+
+// endedSessionRetention is how long a finalised session is remembered. It
+// must outlast the status file refresh and a delayed disconnect hook.
+const endedSessionRetention = time.Hour
+
+// endedSession holds the counters already credited for a session that ended
+type endedSession struct {
+	state   models.ClientState
+	endedAt time.Time
+}
+
+// openVPNEndKey identifies a session by what both the status sources and the
+// client-disconnect environment (common_name, time_unix) carry
+func openVPNEndKey(commonName string, connectedSince time.Time) string {
+	return fmt.Sprintf("%s/%d", commonName, connectedSince.Unix())
+}
+
+// recordEndedSession remembers the credited counters of an ended session,
+// keeping the highest seen. Caller must hold s.mu.
+func (s *BandwidthService) recordEndedSession(state models.ClientState) {
+	if s.endedSessions == nil {
+		s.endedSessions = make(map[string]endedSession)
+	}
+
+	key := openVPNEndKey(state.CommonName, state.ConnectedSince)
+	if ended, ok := s.endedSessions[key]; ok {
+		state.BytesSent = max(state.BytesSent, ended.state.BytesSent)
+		state.BytesReceived = max(state.BytesReceived, ended.state.BytesReceived)
+	}
+	s.endedSessions[key] = endedSession{state: state, endedAt: time.Now().UTC()}
+}
+
+// pruneEndedSessions forgets sessions that ended before the retention window. Caller must hold s.mu.
+func (s *BandwidthService) pruneEndedSessions(now time.Time) {
+	for key, ended := range s.endedSessions {
+		if now.Sub(ended.endedAt) > endedSessionRetention {
+			delete(s.endedSessions, key)
+		}
+	}
+}
+
+// withoutEndedSessions drops sessions that were already finalised. Caller must hold s.mu.
+func (s *BandwidthService) withoutEndedSessions(states map[string]models.ClientState) map[string]models.ClientState {
+	if len(s.endedSessions) == 0 {
+		return states
+	}
+
+	kept := make(map[string]models.ClientState, len(states))
+	for sessionID, state := range states {
+		if _, ended := s.endedSessions[openVPNEndKey(state.CommonName, state.ConnectedSince)]; !ended {
+			kept[sessionID] = state
+		}
+	}
+	return kept
+}
+
+// RecordOpenVPNConnect notes a session the client-connect hook reported, so
+// that its disconnect is accepted, also after a restart
+func (s *BandwidthService) RecordOpenVPNConnect(event models.OpenVPNSessionEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hooked := s.accumulator.HookedOpenVPNSessions
+	now := time.Now().UTC()
+	for key, reportedAt := range hooked {
+		if now.Sub(reportedAt) > connectedSessionRetention {
+			delete(hooked, key)
+		}
+	}
+	hooked[openVPNEndKey(event.CommonName, event.ConnectedAt)] = now
+
+	if err := s.saveAccumulator(); err != nil {
+		return fmt.Errorf("failed to save accumulator: %w", err)
+	}
+	return nil
+}
+
+// RecordOpenVPNSession credits the exact totals a client-disconnect hook
+// reports. Traffic a collector already counted for the session is
+// subtracted, so sessions shorter than a collection interval are counted in
+// full and longer ones gain only their last, unobserved part.
+func (s *BandwidthService) RecordOpenVPNSession(event models.OpenVPNSessionEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	if err := s.closeDuePeriods(now); err != nil {
+		return fmt.Errorf("failed to close billing period: %w", err)
+	}
+
+	final := models.ClientState{
+		CommonName:     event.CommonName,
+		RealAddress:    event.RealAddress,
+		VirtualAddress: event.VirtualAddress,
+		Username:       event.Username,
+		ClientID:       -1,
+		PeerID:         -1,
+		BytesSent:      event.BytesSent,
+		BytesReceived:  event.BytesReceived,
+		ConnectedSince: event.ConnectedAt,
+		LastSeenAt:     now,
+	}
+	key := openVPNEndKey(event.CommonName, event.ConnectedAt)
+
+	_, hooked := s.accumulator.HookedOpenVPNSessions[key]
+	delete(s.accumulator.HookedOpenVPNSessions, key)
+
+	var baseline models.ClientState
+	counted := false
+	if ended, ok := s.endedSessions[key]; ok {
+		// A collector saw the session end first
+		baseline = ended.state
+		counted = true
+	} else if sessionID, state, ok := s.findClientState(key); ok {
+		baseline = state
+		delete(s.accumulator.ClientStates, sessionID)
+		delete(s.clientDeltas, sessionID)
+	} else if !hooked {
+		return ErrUnknownSession
+	} else if event.ConnectedAt.Before(s.accumulator.LastResetAt) {
+		// Never observed and older than the period: as with collectors, the
+		// totals may include traffic from before it
+		baseline = final
+	}
+
+	sent := sessionCounterDelta(final.BytesSent, baseline.BytesSent)
+	received := sessionCounterDelta(final.BytesReceived, baseline.BytesReceived)
+
+	s.accumulator.OpenVPN.TotalBytesSent += sent
+	s.accumulator.OpenVPN.TotalBytesReceived += received
+	s.accumulator.OpenVPN.TotalBandwidthMB = float64(s.accumulator.OpenVPN.TotalBytesSent+s.accumulator.OpenVPN.TotalBytesReceived) / (1024 * 1024)
+	s.addClientTotals(event.CommonName, sent, received, !counted)
+	if !counted {
+		s.accumulator.OpenVPN.SessionCount++
+	}
+	s.recordEndedSession(final)
+
+	if err := s.saveAccumulator(); err != nil {
+		return fmt.Errorf("failed to save accumulator: %w", err)
+	}
+	return nil
+}
+
+// findClientState looks up a tracked session by its end key. Caller must hold s.mu.
+func (s *BandwidthService) findClientState(key string) (string, models.ClientState, bool) {
+	for sessionID, state := range s.accumulator.ClientStates {
+		if openVPNEndKey(state.CommonName, state.ConnectedSince) == key {
+			return sessionID, state, true
+		}
+	}
+	return "", models.ClientState{}, false
+}
+
+// UserUsage returns what a user moved in the current period, over OpenVPN by
+// common name, over L2TP by ppp peer name and in RADIUS accounting by
+// User-Name
+func (s *BandwidthService) UserUsage(name string) models.AccumulatedData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var usage models.AccumulatedData
+	for _, totals := range []models.AccumulatedData{
+		s.accumulator.ClientTotals[name],
+		s.accumulator.PPPUserTotals[name],
+		s.accumulator.RadiusUserTotals[name],
+	} {
+		usage.TotalBytesSent += totals.TotalBytesSent
+		usage.TotalBytesReceived += totals.TotalBytesReceived
+		usage.SessionCount += totals.SessionCount
+	}
+	usage.TotalBandwidthMB = float64(usage.TotalBytesSent+usage.TotalBytesReceived) / (1024 * 1024)
+	return usage
+}
+
+// CurrentPeriod returns when the period UserUsage counts started and, with a
+// billing cycle, when it ends
+func (s *BandwidthService) CurrentPeriod() (time.Time, *time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := s.accumulator.LastResetAt
+	if end := s.billing.NextBoundary(start); !end.IsZero() {
+		return start, &end
+	}
+	return start, nil
+}
+
+// endedPPPSession holds the counters already credited for a ppp session
+type endedPPPSession struct {
+	user     string
+	counters models.InterfaceCounters // TxBytes sent, RxBytes received
+	endedAt  time.Time
+	byHook   bool // reported by ip-down rather than noticed by the collector
+}
+
+// recordEndedPPP remembers the credited counters of an ended ppp session. Caller must hold s.mu.
+func (s *BandwidthService) recordEndedPPP(iface, user string, counters models.InterfaceCounters, byHook bool) {
+	if s.endedPPP == nil {
+		s.endedPPP = make(map[string]endedPPPSession)
+	}
+	s.endedPPP[iface] = endedPPPSession{user: user, counters: counters, endedAt: time.Now().UTC(), byHook: byHook}
+}
+
+// pruneEndedPPP forgets ppp sessions that ended before the retention window. Caller must hold s.mu.
+func (s *BandwidthService) pruneEndedPPP(now time.Time) {
+	for iface, ended := range s.endedPPP {
+		if now.Sub(ended.endedAt) > endedSessionRetention {
+			delete(s.endedPPP, iface)
+		}
+	}
+}
+
+// pppEndedByHook reports whether an interface still carries a session the
+// ip-down hook already finalised. An interface that is new since the last
+// observation or whose counters went backwards belongs to a new session.
+// Caller must hold s.mu.
+func (s *BandwidthService) pppEndedByHook(iface, user string, counters models.InterfaceCounters) bool {
+	ended, ok := s.endedPPP[iface]
+	if !ok || !ended.byHook || ended.user != user {
+		return false
+	}
+
+	if s.accumulator.HostState != nil {
+		before, seen := s.accumulator.HostState.Interfaces[iface]
+		if seen && counters.TxBytes >= before.TxBytes && counters.RxBytes >= before.RxBytes {
+			return true
+		}
+	}
+
+	delete(s.endedPPP, iface)
+	return false
+}
+
+// RecordPPPConnect notes a session the ip-up hook reported, so that its
+// ip-down is accepted, also after a restart
+func (s *BandwidthService) RecordPPPConnect(event models.PPPSessionEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accumulator.HookedPPPSessions[event.Interface] = event.Peer
+
+	if err := s.saveAccumulator(); err != nil {
+		return fmt.Errorf("failed to save accumulator: %w", err)
+	}
+	return nil
+}
+
+// RecordPPPSession credits the exact totals of an L2TP session, as reported
+// by the pppd ip-down hook, to the peer's per-period totals. Traffic the host
+// interface collector already attributed to the session is subtracted.
+// IPsec totals are left alone: the container counters include L2TP already.
+func (s *BandwidthService) RecordPPPSession(event models.PPPSessionEvent, connectedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	if err := s.closeDuePeriods(now); err != nil {
+		return fmt.Errorf("failed to close billing period: %w", err)
+	}
+	s.pruneEndedPPP(now)
+
+	hooked := s.accumulator.HookedPPPSessions[event.Interface] == event.Peer
+	if hooked {
+		delete(s.accumulator.HookedPPPSessions, event.Interface)
+	}
+
+	var baseline models.InterfaceCounters
+	counted := false
+	if ended, ok := s.endedPPP[event.Interface]; ok && ended.user == event.Peer && connectedAt.Before(ended.endedAt) {
+		// The collector saw the interface go away first, or this is a retried callback
+		baseline = ended.counters
+		counted = true
+	} else if current, ok := s.hostIfaces[event.Interface]; ok && current.User == event.Peer {
+		if s.accumulator.HostState != nil {
+			baseline = s.accumulator.HostState.Interfaces[event.Interface]
+		}
+	} else if !hooked {
+		return ErrUnknownSession
+	}
+
+	sent := sessionCounterDelta(event.BytesSent, baseline.TxBytes)
+	received := sessionCounterDelta(event.BytesReceived, baseline.RxBytes)
+	addTotals(s.accumulator.PPPUserTotals, event.Peer, sent, received, !counted)
+
+	s.recordEndedPPP(event.Interface, event.Peer, models.InterfaceCounters{
+		TxBytes: max(event.BytesSent, baseline.TxBytes),
+		RxBytes: max(event.BytesReceived, baseline.RxBytes),
+	}, true)
+
+	if err := s.saveAccumulator(); err != nil {
+		return fmt.Errorf("failed to save accumulator: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// Billing cycle kinds
+const (
+	BillingCycleNone          = "none"
+	BillingCycleCalendarMonth = "calendar_month"
+	BillingCycleDayOfMonth    = "day_of_month"
+	BillingCycleRolling       = "rolling"
+)
+
+// BillingCycle decides when the accumulator closes a period and starts a new one
+type BillingCycle struct {
+	Kind        string
+	DayOfMonth  int
+	RollingDays int
+	Location    *time.Location
+}
+
+// NewBillingCycle validates the configured cycle. An empty kind disables automatic resets.
+func NewBillingCycle(kind string, dayOfMonth, rollingDays int, timezone string) (*BillingCycle, error) {
+	if kind == "" {
+		kind = BillingCycleNone
+	}
+
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid billing timezone %q: %w", timezone, err)
+	}
+
+	cycle := &BillingCycle{
+		Kind:        kind,
+		DayOfMonth:  dayOfMonth,
+		RollingDays: rollingDays,
+		Location:    location,
+	}
+
+	switch kind {
+	case BillingCycleNone, BillingCycleCalendarMonth:
+	case BillingCycleDayOfMonth:
+		if dayOfMonth < 1 || dayOfMonth > 31 {
+			return nil, fmt.Errorf("billing day_of_month must be between 1 and 31, got %d", dayOfMonth)
+		}
+	case BillingCycleRolling:
+		if rollingDays < 1 {
+			return nil, fmt.Errorf("billing rolling_days must be positive, got %d", rollingDays)
+		}
+	default:
+		return nil, fmt.Errorf("unknown billing cycle %q", kind)
+	}
+
+	return cycle, nil
+}
+
+// Enabled reports whether periods close automatically
+func (c *BillingCycle) Enabled() bool {
+	return c != nil && c.Kind != BillingCycleNone
+}
+
+// NextBoundary returns the end of the period that started at start.
+// The zero time is returned when the cycle is disabled.
+func (c *BillingCycle) NextBoundary(start time.Time) time.Time {
+	if !c.Enabled() {
+		return time.Time{}
+	}
+
+	local := start.In(c.Location)
+
+	switch c.Kind {
+	case BillingCycleCalendarMonth:
+		return time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, c.Location).UTC()
+
+	case BillingCycleDayOfMonth:
+		// The anchor day in the start's month, or the next one if already passed
+		boundary := c.anchorIn(local.Year(), local.Month())
+		if !boundary.After(local) {
+			boundary = c.anchorIn(local.Year(), local.Month()+1)
+		}
+		return boundary.UTC()
+
+	case BillingCycleRolling:
+		return local.AddDate(0, 0, c.RollingDays).UTC()
+	}
+
+	return time.Time{}
+}
+
+// anchorIn returns midnight of the billing day in the given month, clamped
+// to the last day for shorter months
+func (c *BillingCycle) anchorIn(year int, month time.Month) time.Time {
+	firstOfMonth := time.Date(year, month, 1, 0, 0, 0, 0, c.Location)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+
+	day := c.DayOfMonth
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, 0, 0, 0, 0, c.Location)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBillingCycleNextBoundary(t *testing.T) {
+	tbilisi, err := time.LoadLocation("Asia/Tbilisi")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		cycle BillingCycle
+		start time.Time
+		want  time.Time
+	}{
+		{
+			name:  "calendar month",
+			cycle: BillingCycle{Kind: BillingCycleCalendarMonth, Location: time.UTC},
+			start: time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "calendar month in local timezone",
+			cycle: BillingCycle{Kind: BillingCycleCalendarMonth, Location: tbilisi},
+			start: time.Date(2026, 1, 31, 21, 0, 0, 0, time.UTC), // already Feb 1 in Tbilisi
+			want:  time.Date(2026, 2, 28, 20, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "day of month later this month",
+			cycle: BillingCycle{Kind: BillingCycleDayOfMonth, DayOfMonth: 15, Location: time.UTC},
+			start: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "day of month clamped to short month",
+			cycle: BillingCycle{Kind: BillingCycleDayOfMonth, DayOfMonth: 31, Location: time.UTC},
+			start: time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "rolling days",
+			cycle: BillingCycle{Kind: BillingCycleRolling, RollingDays: 30, Location: time.UTC},
+			start: time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 2, 9, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "disabled",
+			cycle: BillingCycle{Kind: BillingCycleNone, Location: time.UTC},
+			start: time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cycle.NextBoundary(tt.start); !got.Equal(tt.want) {
+				t.Fatalf("NextBoundary(%s) = %s, want %s", tt.start, got, tt.want)
+			}
+		})
+	}
+}
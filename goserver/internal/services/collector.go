@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// Collector is a pluggable source of bandwidth counters. Each collector runs
+// on its own interval; the bandwidth service turns consecutive samples into
+// deltas and rates.
+type Collector interface {
+	// Name identifies the collector in configuration and in the API
+	Name() string
+	// Collect reads the current cumulative counters
+	Collect(ctx context.Context) (*CollectorSample, error)
+}
+
+// StreamingCollector is a collector that also pushes samples between its
+// scheduled runs
+type StreamingCollector interface {
+	Collector
+	// Updates delivers pushed samples; it is never closed
+	Updates() <-chan *CollectorSample
+}
+
+// CollectorSample carries what a collector observed. Only the parts a source
+// knows about are set; nil parts are left untouched by the service.
+type CollectorSample struct {
+	OpenVPN *OpenVPNSample
+	IPSec   *IPSecSample
+	Host    *HostSample
+}
+
+// OpenVPNSample is the set of currently connected OpenVPN client sessions
+type OpenVPNSample struct {
+	UpdatedAt time.Time                     // when the source produced the list, zero if unknown
+	Clients   map[string]models.ClientState // key: session ID, see openVPNSessionID
+	Ended     map[string]models.ClientState // final counters of sessions that disconnected, key: session ID
+}
+
+// IPSecSample is the counter state of the IPsec server. Counters is nil when
+// the server is not running.
+type IPSecSample struct {
+	Counters    *models.IPSecCounterState
+	Connections []models.IPSecConnection
+	// ConnectionsErr is set when the counters were read but the connections
+	// were not; Connections is then empty rather than known to be empty
+	ConnectionsErr error
+}
+
+// HostSample is the counter state of selected host interfaces
+type HostSample struct {
+	Counters   *models.HostCounterState
+	PPPUsers   map[string]string // key: ppp interface, value: peer name
+	PPPAsIPSec bool
+}
+
+// collectorEntry is a registered collector together with its schedule and health
+type collectorEntry struct {
+	collector Collector
+	enabled   bool
+	interval  time.Duration
+
+	// Guarded by CollectorRegistry.mu
+	lastAt time.Time
+	status models.CollectorStatus
+}
+
+// CollectorRegistry holds the configured collectors in registration order
+type CollectorRegistry struct {
+	mu      sync.RWMutex
+	entries []*collectorEntry
+	byName  map[string]*collectorEntry
+}
+
+func NewCollectorRegistry() *CollectorRegistry {
+	return &CollectorRegistry{
+		byName: make(map[string]*collectorEntry),
+	}
+}
+
+// Register adds a collector. Disabled collectors are kept so their state is visible in the API.
+func (r *CollectorRegistry) Register(collector Collector, enabled bool, interval time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := collector.Name()
+	if _, exists := r.byName[name]; exists {
+		return fmt.Errorf("collector %q is already registered", name)
+	}
+
+	if enabled && interval <= 0 {
+		return fmt.Errorf("collector %q needs a positive interval", name)
+	}
+
+	entry := &collectorEntry{
+		collector: collector,
+		enabled:   enabled,
+		interval:  interval,
+		status: models.CollectorStatus{
+			Name:     name,
+			Enabled:  enabled,
+			Interval: interval.String(),
+		},
+	}
+
+	r.entries = append(r.entries, entry)
+	r.byName[name] = entry
+	return nil
+}
+
+// enabledEntries returns the collectors that should run
+func (r *CollectorRegistry) enabledEntries() []*collectorEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]*collectorEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		if entry.enabled {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// collectors returns every registered collector, enabled or not
+func (r *CollectorRegistry) collectors() []Collector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	collectors := make([]Collector, 0, len(r.entries))
+	for _, entry := range r.entries {
+		collectors = append(collectors, entry.collector)
+	}
+	return collectors
+}
+
+// record updates an entry's health after a run and returns the time since its previous successful run
+func (r *CollectorRegistry) record(entry *collectorEntry, at time.Time, err error) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := &entry.status
+	status.LastRunAt = &at
+
+	if err != nil {
+		status.Healthy = false
+		status.LastError = err.Error()
+		status.LastErrorAt = &at
+		status.ConsecutiveFailures++
+		return 0
+	}
+
+	status.Healthy = true
+	status.LastSuccessAt = &at
+	status.ConsecutiveFailures = 0
+
+	var elapsed time.Duration
+	if !entry.lastAt.IsZero() {
+		elapsed = at.Sub(entry.lastAt)
+	}
+	entry.lastAt = at
+
+	return elapsed
+}
+
+// Statuses returns the health of every registered collector
+func (r *CollectorRegistry) Statuses() []models.CollectorStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]models.CollectorStatus, 0, len(r.entries))
+	for _, entry := range r.entries {
+		status := entry.status
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
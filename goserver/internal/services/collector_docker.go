@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// DockerStatsCollector reads IPsec traffic from the IPSec container through
+// the Docker API: interface counters from container stats and per-connection
+// counters from `ipsec trafficstatus`
+type DockerStatsCollector struct {
+	dockerClient  *client.Client
+	containerName string
+}
+
+func NewDockerStatsCollector(containerName string) (*DockerStatsCollector, error) {
+	if containerName == "" {
+		containerName = ipsecContainerName
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	return &DockerStatsCollector{
+		dockerClient:  cli,
+		containerName: containerName,
+	}, nil
+}
+
+func (c *DockerStatsCollector) Name() string {
+	return "docker_stats"
+}
+
+func (c *DockerStatsCollector) Collect(ctx context.Context) (*CollectorSample, error) {
+	counters, err := c.collectCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := &IPSecSample{Counters: counters}
+
+	if counters != nil {
+		// The counters are still good without the per-connection view
+		sample.Connections, sample.ConnectionsErr = c.collectConnections(ctx)
+	}
+
+	return &CollectorSample{IPSec: sample}, nil
+}
+
+// collectCounters returns the per-interface counters of the IPSec container
+// together with the identity of the current counter epoch. A nil state without
+// error means the container is not running.
+func (c *DockerStatsCollector) collectCounters(ctx context.Context) (*models.IPSecCounterState, error) {
+	info, err := inspectRunning(ctx, c.dockerClient, c.containerName)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	startedAt, err := time.Parse(time.RFC3339Nano, info.State.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse container start time %q: %w", info.State.StartedAt, err)
+	}
+
+	stats, err := c.dockerClient.ContainerStats(ctx, c.containerName, false)
+	if errdefs.IsNotFound(err) {
+		return nil, nil // Container went away between calls
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read container stats: %w", err)
+	}
+	defer stats.Body.Close()
+
+	var containerStats container.StatsResponse
+	if err := json.NewDecoder(stats.Body).Decode(&containerStats); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	state := &models.IPSecCounterState{
+		ContainerID: info.ID,
+		StartedAt:   startedAt.UTC(),
+		ObservedAt:  time.Now().UTC(),
+		Interfaces:  make(map[string]models.InterfaceCounters, len(containerStats.Networks)),
+	}
+	for name, network := range containerStats.Networks {
+		state.Interfaces[name] = models.InterfaceCounters{
+			RxBytes: network.RxBytes,
+			TxBytes: network.TxBytes,
+		}
+	}
+
+	return state, nil
+}
+
+// collectConnections lists established IPsec SAs inside the IPSec container
+func (c *DockerStatsCollector) collectConnections(ctx context.Context) ([]models.IPSecConnection, error) {
+	output, err := execInContainer(ctx, c.dockerClient, c.containerName, []string{"ipsec", "trafficstatus"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ipsec trafficstatus: %w", err)
+	}
+
+	return parseIPSecTrafficStatus(output), nil
+}
+
+// Close releases the Docker client
+func (c *DockerStatsCollector) Close() error {
+	return c.dockerClient.Close()
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// HostInterfacesOptions configures the host interface collector
+type HostInterfacesOptions struct {
+	Include         []string // glob patterns, e.g. tun*, ppp*
+	Exclude         []string
+	EgressInterface string // empty means the interface of the default route
+	ProcPath        string
+	SysPath         string
+	PPPUsersDir     string // one file per ppp interface containing the peer name
+	PPPAsIPSec      bool   // credit ppp traffic to the IPSec protocol totals
+}
+
+// HostInterfacesCollector reads per-interface counters of the host for
+// deployments where the VPN daemons run outside Docker
+type HostInterfacesCollector struct {
+	opts HostInterfacesOptions
+}
+
+func NewHostInterfacesCollector(opts HostInterfacesOptions) (*HostInterfacesCollector, error) {
+	if opts.ProcPath == "" {
+		opts.ProcPath = "/proc"
+	}
+	if opts.SysPath == "" {
+		opts.SysPath = "/sys"
+	}
+
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid interface pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &HostInterfacesCollector{opts: opts}, nil
+}
+
+func (c *HostInterfacesCollector) Name() string {
+	return "host_interfaces"
+}
+
+func (c *HostInterfacesCollector) Collect(ctx context.Context) (*CollectorSample, error) {
+	counters, err := c.readProcNetDev()
+	if err != nil {
+		counters, err = c.readSysClassNet()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	egress := c.opts.EgressInterface
+	if egress == "" {
+		egress, err = c.defaultRouteInterface()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selected := make(map[string]models.InterfaceCounters)
+	for name, value := range counters {
+		if name == egress || c.matches(name) {
+			selected[name] = value
+		}
+	}
+
+	if _, ok := selected[egress]; !ok {
+		return nil, fmt.Errorf("egress interface %q not found", egress)
+	}
+
+	return &CollectorSample{
+		Host: &HostSample{
+			Counters: &models.HostCounterState{
+				ObservedAt:      time.Now().UTC(),
+				EgressInterface: egress,
+				Interfaces:      selected,
+			},
+			PPPUsers:   c.pppUsers(selected),
+			PPPAsIPSec: c.opts.PPPAsIPSec,
+		},
+	}, nil
+}
+
+// matches applies the include and exclude globs to an interface name
+func (c *HostInterfacesCollector) matches(name string) bool {
+	for _, pattern := range c.opts.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	for _, pattern := range c.opts.Include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readProcNetDev parses /proc/net/dev:
+//
+//	Inter-|   Receive                            |  Transmit
+//	 face |bytes    packets errs drop ...        |bytes    packets ...
+//	  eth0: 1234     10     0    0    ...          5678     20      ...
+func (c *HostInterfacesCollector) readProcNetDev() (map[string]models.InterfaceCounters, error) {
+	file, err := os.Open(filepath.Join(c.opts.ProcPath, "net", "dev"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /proc/net/dev: %w", err)
+	}
+	defer file.Close()
+
+	counters := make(map[string]models.InterfaceCounters)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) < 9 {
+			continue
+		}
+
+		rx, err1 := strconv.ParseUint(fields[0], 10, 64)
+		tx, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		counters[strings.TrimSpace(name)] = models.InterfaceCounters{RxBytes: rx, TxBytes: tx}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading /proc/net/dev: %w", err)
+	}
+
+	return counters, nil
+}
+
+// readSysClassNet reads /sys/class/net/*/statistics as a fallback
+func (c *HostInterfacesCollector) readSysClassNet() (map[string]models.InterfaceCounters, error) {
+	root := filepath.Join(c.opts.SysPath, "class", "net")
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", root, err)
+	}
+
+	counters := make(map[string]models.InterfaceCounters, len(entries))
+	for _, entry := range entries {
+		rx, err1 := readCounterFile(filepath.Join(root, entry.Name(), "statistics", "rx_bytes"))
+		tx, err2 := readCounterFile(filepath.Join(root, entry.Name(), "statistics", "tx_bytes"))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		counters[entry.Name()] = models.InterfaceCounters{RxBytes: rx, TxBytes: tx}
+	}
+
+	return counters, nil
+}
+
+func readCounterFile(file string) (uint64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// defaultRouteInterface finds the interface of the IPv4 default route in /proc/net/route
+func (c *HostInterfacesCollector) defaultRouteInterface() (string, error) {
+	file, err := os.Open(filepath.Join(c.opts.ProcPath, "net", "route"))
+	if err != nil {
+		return "", fmt.Errorf("failed to open /proc/net/route: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if len(fields) >= 8 && fields[1] == "00000000" && fields[7] == "00000000" {
+			return fields[0], nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading /proc/net/route: %w", err)
+	}
+
+	return "", errors.New("no default route found, set egress_interface explicitly")
+}
+
+// pppUsers maps ppp interfaces to peer names written by the ip-up hook
+func (c *HostInterfacesCollector) pppUsers(interfaces map[string]models.InterfaceCounters) map[string]string {
+	users := make(map[string]string)
+	if c.opts.PPPUsersDir == "" {
+		return users
+	}
+
+	for name := range interfaces {
+		if !strings.HasPrefix(name, "ppp") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(c.opts.PPPUsersDir, name))
+		if err != nil {
+			continue
+		}
+
+		if user := strings.TrimSpace(string(data)); user != "" {
+			users[name] = user
+		}
+	}
+
+	return users
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+const procNetDevSample = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0: 5000000    4000    0    0    0     0          0         0  9000000    6000    0    0    0     0       0          0
+  tun0:   20000     100    0    0    0     0          0         0    30000     120    0    0    0     0       0          0
+  ppp0:    1000      10    0    0    0     0          0         0     2000      20    0    0    0     0       0          0
+  ppp1:     500       5    0    0    0     0          0         0      700       7    0    0    0     0       0          0
+`
+
+const procNetRouteSample = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`
+
+func TestHostInterfacesCollector(t *testing.T) {
+	root := t.TempDir()
+	procPath := filepath.Join(root, "proc")
+	usersDir := filepath.Join(root, "ppp")
+
+	for file, content := range map[string]string{
+		filepath.Join(procPath, "net", "dev"):   procNetDevSample,
+		filepath.Join(procPath, "net", "route"): procNetRouteSample,
+		filepath.Join(usersDir, "ppp0"):         "alice\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collector, err := NewHostInterfacesCollector(HostInterfacesOptions{
+		Include:     []string{"tun*", "ppp*"},
+		Exclude:     []string{"ppp1"},
+		ProcPath:    procPath,
+		PPPUsersDir: usersDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sample, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := sample.Host
+	if host.Counters.EgressInterface != "eth0" {
+		t.Fatalf("expected egress eth0, got %q", host.Counters.EgressInterface)
+	}
+	if len(host.Counters.Interfaces) != 3 {
+		t.Fatalf("expected eth0, tun0 and ppp0, got %v", host.Counters.Interfaces)
+	}
+	if got := host.Counters.Interfaces["eth0"]; got.RxBytes != 5000000 || got.TxBytes != 9000000 {
+		t.Fatalf("unexpected eth0 counters: %+v", got)
+	}
+	if host.PPPUsers["ppp0"] != "alice" {
+		t.Fatalf("expected ppp0 to belong to alice, got %v", host.PPPUsers)
+	}
+}
+
+func TestInterfaceDeltas(t *testing.T) {
+	prev := &models.HostCounterState{Interfaces: map[string]models.InterfaceCounters{
+		"eth0": {RxBytes: 100, TxBytes: 200},
+		"ppp0": {RxBytes: 50, TxBytes: 50},
+	}}
+	cur := &models.HostCounterState{Interfaces: map[string]models.InterfaceCounters{
+		"eth0": {RxBytes: 150, TxBytes: 260},
+		"ppp0": {RxBytes: 10, TxBytes: 20}, // recreated
+		"ppp1": {RxBytes: 5, TxBytes: 6},   // new
+	}}
+
+	deltas := interfaceDeltas(prev, cur)
+	if deltas["eth0"] != (clientDelta{sent: 60, received: 50}) {
+		t.Fatalf("unexpected eth0 delta: %+v", deltas["eth0"])
+	}
+	if deltas["ppp0"] != (clientDelta{sent: 20, received: 10}) {
+		t.Fatalf("unexpected ppp0 delta: %+v", deltas["ppp0"])
+	}
+	if deltas["ppp1"] != (clientDelta{sent: 6, received: 5}) {
+		t.Fatalf("unexpected ppp1 delta: %+v", deltas["ppp1"])
+	}
+
+	if baseline := interfaceDeltas(nil, cur); baseline["eth0"] != (clientDelta{}) {
+		t.Fatalf("first observation must only set a baseline")
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+)
+
+// OpenVPNStatusCollector reads connected clients from the OpenVPN status file
+type OpenVPNStatusCollector struct {
+	statusFile string
+	logger     *slog.Logger
+}
+
+func NewOpenVPNStatusCollector(statusFile string, logger *slog.Logger) *OpenVPNStatusCollector {
+	if statusFile == "" {
+		statusFile = openVPNStatusFile
+	}
+	return &OpenVPNStatusCollector{statusFile: statusFile, logger: logger}
+}
+
+func (c *OpenVPNStatusCollector) Name() string {
+	return "openvpn_status"
+}
+
+func (c *OpenVPNStatusCollector) Collect(ctx context.Context) (*CollectorSample, error) {
+	status, err := c.readStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	clients := make(map[string]models.ClientState, len(status.Clients))
+	for _, client := range status.Clients {
+		state := clientStateFromStatus(client, now)
+		clients[openVPNSessionID(state)] = state
+	}
+
+	return &CollectorSample{
+		OpenVPN: &OpenVPNSample{UpdatedAt: status.UpdatedAt, Clients: clients},
+	}, nil
+}
+
+// readStatus parses the status file; a missing file means OpenVPN is not running
+func (c *OpenVPNStatusCollector) readStatus() (*openvpn.Status, error) {
+	file, err := os.Open(c.statusFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &openvpn.Status{}, nil
+		}
+		return nil, fmt.Errorf("failed to open OpenVPN status file: %w", err)
+	}
+	defer file.Close()
+
+	status, err := openvpn.ParseStatus(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenVPN status file: %w", err)
+	}
+	logSkippedClients(c.logger, status)
+
+	return status, nil
+}
+
+// logSkippedClients reports CLIENT_LIST entries the parser left out
+func logSkippedClients(logger *slog.Logger, status *openvpn.Status) {
+	for _, reason := range status.Skipped {
+		logger.Warn("Skipping malformed OpenVPN client entry", "error", reason)
+	}
+}
+
+// openVPNSessionID identifies a single client session. OpenVPN reuses common
+// names (duplicate-cn, reconnects before the old session timed out) and
+// recycles client IDs after a restart, so the connection time is part of the
+// key. Older status versions carry no IDs; the real address stands in for them.
+func openVPNSessionID(state models.ClientState) string {
+	if state.ClientID >= 0 {
+		return fmt.Sprintf("%d/%d/%d", state.ClientID, state.PeerID, state.ConnectedSince.Unix())
+	}
+	return fmt.Sprintf("%s@%s/%d", state.CommonName, state.RealAddress, state.ConnectedSince.Unix())
+}
+
+// clientStateFromStatus converts a status file entry into tracked client state
+func clientStateFromStatus(client openvpn.Client, seenAt time.Time) models.ClientState {
+	return models.ClientState{
+		CommonName:         client.CommonName,
+		RealAddress:        client.RealAddress,
+		VirtualAddress:     client.VirtualAddress,
+		VirtualIPv6Address: client.VirtualIPv6Address,
+		Username:           client.Username,
+		ClientID:           client.ClientID,
+		PeerID:             client.PeerID,
+		BytesSent:          client.BytesSent,
+		BytesReceived:      client.BytesReceived,
+		ConnectedSince:     client.ConnectedSince,
+		LastSeenAt:         seenAt,
+	}
+}
+
+// Status returns the parsed status file
+func (c *OpenVPNStatusCollector) Status(ctx context.Context) (*openvpn.Status, error) {
+	return c.readStatus()
+}
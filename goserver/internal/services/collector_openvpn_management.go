@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+)
+
+// ErrManagementUnavailable is returned when the OpenVPN management interface cannot be reached
+var ErrManagementUnavailable = errors.New("OpenVPN management interface is unavailable")
+
+// OpenVPNManagementOptions configures the management interface collector
+type OpenVPNManagementOptions struct {
+	Network           string // tcp or unix
+	Address           string // host:port or socket path
+	Password          string
+	BytecountInterval time.Duration
+	// Fallback is read while the management interface is unreachable
+	Fallback *OpenVPNStatusCollector
+}
+
+// OpenVPNManagementCollector reads sessions from the OpenVPN management
+// interface. Besides the periodic "status 3" poll it subscribes to bytecount
+// and client notifications and pushes samples between polls, so traffic of
+// short sessions and the final counters of disconnecting clients are not lost.
+type OpenVPNManagementCollector struct {
+	opts   OpenVPNManagementOptions
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	conn     *openvpn.Management
+	sessions map[int64]models.ClientState  // key: client ID
+	ended    map[string]models.ClientState // final counters not yet delivered, key: session ID
+	dirty    bool
+	polledAt time.Time // source time of the last full client list
+
+	updates chan *CollectorSample
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewOpenVPNManagementCollector(opts OpenVPNManagementOptions, logger *slog.Logger) (*OpenVPNManagementCollector, error) {
+	switch opts.Network {
+	case "tcp", "unix":
+	default:
+		return nil, fmt.Errorf("invalid management network %q, want tcp or unix", opts.Network)
+	}
+	if opts.Address == "" {
+		return nil, errors.New("management address is required")
+	}
+	if opts.BytecountInterval <= 0 {
+		opts.BytecountInterval = 5 * time.Second
+	}
+
+	c := &OpenVPNManagementCollector{
+		opts:     opts,
+		logger:   logger,
+		sessions: make(map[int64]models.ClientState),
+		ended:    make(map[string]models.ClientState),
+		updates:  make(chan *CollectorSample, 1),
+		done:     make(chan struct{}),
+	}
+
+	c.wg.Add(1)
+	go c.pushLoop()
+
+	return c, nil
+}
+
+func (c *OpenVPNManagementCollector) Name() string {
+	return "openvpn_management"
+}
+
+// Collect polls the full client list. While the management interface is
+// unreachable the status file is read instead.
+func (c *OpenVPNManagementCollector) Collect(ctx context.Context) (*CollectorSample, error) {
+	status, err := c.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessions := make(map[int64]models.ClientState, len(status.Clients))
+	clients := make(map[string]models.ClientState, len(status.Clients))
+	for _, client := range status.Clients {
+		state := clientStateFromStatus(client, now)
+
+		// Bytecount notifications may be newer than the polled list
+		if live, ok := c.sessions[state.ClientID]; ok && openVPNSessionID(live) == openVPNSessionID(state) {
+			state.BytesSent = max(state.BytesSent, live.BytesSent)
+			state.BytesReceived = max(state.BytesReceived, live.BytesReceived)
+		}
+
+		if state.ClientID >= 0 {
+			sessions[state.ClientID] = state
+		}
+		clients[openVPNSessionID(state)] = state
+	}
+
+	// A push still waiting in the channel predates this list; take back what it carried
+	select {
+	case stale := <-c.updates:
+		maps.Copy(c.ended, stale.OpenVPN.Ended)
+	default:
+	}
+
+	if c.conn != nil {
+		c.sessions = sessions
+	}
+	c.dirty = false
+	c.polledAt = status.UpdatedAt
+	if c.polledAt.IsZero() {
+		c.polledAt = now
+	}
+
+	return &CollectorSample{
+		OpenVPN: &OpenVPNSample{
+			UpdatedAt: c.polledAt,
+			Clients:   clients,
+			Ended:     c.takeEnded(),
+		},
+	}, nil
+}
+
+// Updates delivers samples pushed between polls
+func (c *OpenVPNManagementCollector) Updates() <-chan *CollectorSample {
+	return c.updates
+}
+
+// Status returns the server status from the management interface, or from
+// the status file when the interface is unreachable
+func (c *OpenVPNManagementCollector) Status(ctx context.Context) (*openvpn.Status, error) {
+	conn, err := c.connection(ctx)
+	if err == nil {
+		status, statusErr := conn.Status(ctx)
+		if statusErr == nil {
+			logSkippedClients(c.logger, status)
+			return status, nil
+		}
+		err = statusErr
+	}
+
+	if c.opts.Fallback == nil {
+		return nil, err
+	}
+
+	c.logger.Warn("OpenVPN management interface unavailable, reading status file", "error", err.Error())
+	return c.opts.Fallback.readStatus()
+}
+
+// Kill disconnects all sessions of a common name, or the session of a real address
+func (c *OpenVPNManagementCollector) Kill(ctx context.Context, target string) error {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Kill(ctx, target)
+}
+
+// ClientKill disconnects a single session by client ID
+func (c *OpenVPNManagementCollector) ClientKill(ctx context.Context, clientID int64, message string) error {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.ClientKill(ctx, clientID, message)
+}
+
+// Signal sends a signal to the server, e.g. SIGHUP to reload server.conf
+func (c *OpenVPNManagementCollector) Signal(ctx context.Context, signal string) error {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Signal(ctx, signal)
+}
+
+// connection returns the live management connection, dialing a new one when needed
+func (c *OpenVPNManagementCollector) connection(ctx context.Context) (*openvpn.Management, error) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn != nil {
+		select {
+		case <-conn.Done():
+		default:
+			return conn, nil
+		}
+	}
+
+	conn, err := openvpn.DialManagement(ctx, c.opts.Network, c.opts.Address, c.opts.Password, openvpn.ManagementHandlers{
+		OnByteCount: c.onByteCount,
+		OnClient:    c.onClient,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManagementUnavailable, err)
+	}
+
+	if err := conn.SetByteCount(ctx, c.opts.BytecountInterval); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %v", ErrManagementUnavailable, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another caller may have connected meanwhile; the interface serves one client at a time
+	if c.conn != nil && c.conn != conn {
+		select {
+		case <-c.conn.Done():
+		default:
+			conn.Close()
+			return c.conn, nil
+		}
+	}
+
+	c.conn = conn
+	c.logger.Info("Connected to OpenVPN management interface", "address", c.opts.Address)
+	return conn, nil
+}
+
+// onByteCount updates the counters of a known session
+func (c *OpenVPNManagementCollector) onByteCount(count openvpn.ByteCount) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.sessions[count.ClientID]
+	if !ok {
+		// Connected since the last poll; the next poll counts it in full
+		return
+	}
+
+	state.BytesReceived = count.BytesIn
+	state.BytesSent = count.BytesOut
+	state.LastSeenAt = time.Now().UTC()
+	c.sessions[count.ClientID] = state
+	c.dirty = true
+}
+
+// onClient records the final counters of disconnecting sessions
+func (c *OpenVPNManagementCollector) onClient(event openvpn.ClientEvent) {
+	if event.Event != "DISCONNECT" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.sessions[event.ClientID]
+	if !ok {
+		// Connected and disconnected between two polls; it was never part of a
+		// client list, so its ID only has to be unique
+		state = clientStateFromEnv(event)
+		if state.CommonName == "" {
+			return
+		}
+	}
+	delete(c.sessions, event.ClientID)
+
+	if received, err := strconv.ParseUint(event.Env["bytes_received"], 10, 64); err == nil {
+		state.BytesReceived = max(state.BytesReceived, received)
+	}
+	if sent, err := strconv.ParseUint(event.Env["bytes_sent"], 10, 64); err == nil {
+		state.BytesSent = max(state.BytesSent, sent)
+	}
+	state.LastSeenAt = time.Now().UTC()
+
+	c.ended[openVPNSessionID(state)] = state
+	c.dirty = true
+}
+
+// clientStateFromEnv builds a session from the environment of a client notification
+func clientStateFromEnv(event openvpn.ClientEvent) models.ClientState {
+	env := event.Env
+
+	state := models.ClientState{
+		CommonName:     env["common_name"],
+		VirtualAddress: env["ifconfig_pool_remote_ip"],
+		Username:       env["username"],
+		ClientID:       event.ClientID,
+		PeerID:         -1,
+	}
+	if ip := env["trusted_ip"]; ip != "" {
+		state.RealAddress = ip + ":" + env["trusted_port"]
+	}
+	if secs, err := strconv.ParseInt(env["time_unix"], 10, 64); err == nil {
+		state.ConnectedSince = time.Unix(secs, 0).UTC()
+	}
+
+	return state
+}
+
+// pushLoop publishes accumulated notifications once per bytecount interval
+func (c *OpenVPNManagementCollector) pushLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.opts.BytecountInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.push()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *OpenVPNManagementCollector) push() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return
+	}
+
+	clients := make(map[string]models.ClientState, len(c.sessions))
+	for _, state := range c.sessions {
+		clients[openVPNSessionID(state)] = state
+	}
+
+	// Sessions that connected after the last poll are not in the list yet, so
+	// the push does not move the observation time past them
+	sample := &CollectorSample{
+		OpenVPN: &OpenVPNSample{
+			UpdatedAt: c.polledAt,
+			Clients:   clients,
+			Ended:     maps.Clone(c.ended),
+		},
+	}
+
+	// Keep the pending state if the service has not consumed the previous push
+	select {
+	case c.updates <- sample:
+		c.ended = make(map[string]models.ClientState)
+		c.dirty = false
+	default:
+	}
+}
+
+// takeEnded hands over the final counters of ended sessions. Caller must hold c.mu.
+func (c *OpenVPNManagementCollector) takeEnded() map[string]models.ClientState {
+	ended := c.ended
+	c.ended = make(map[string]models.ClientState)
+	return ended
+}
+
+func (c *OpenVPNManagementCollector) Close() error {
+	close(c.done)
+	c.wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
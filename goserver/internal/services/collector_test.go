@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubCollector struct{ name string }
+
+func (c stubCollector) Name() string { return c.name }
+
+func (c stubCollector) Collect(ctx context.Context) (*CollectorSample, error) {
+	return &CollectorSample{}, nil
+}
+
+func TestCollectorRegistryHealth(t *testing.T) {
+	registry := NewCollectorRegistry()
+
+	if err := registry.Register(stubCollector{"a"}, true, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(stubCollector{"b"}, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(stubCollector{"a"}, true, time.Minute); err == nil {
+		t.Fatalf("expected duplicate registration to fail")
+	}
+
+	entries := registry.enabledEntries()
+	if len(entries) != 1 || entries[0].collector.Name() != "a" {
+		t.Fatalf("unexpected enabled entries: %d", len(entries))
+	}
+
+	start := time.Now()
+	if elapsed := registry.record(entries[0], start, nil); elapsed != 0 {
+		t.Fatalf("first run must not report an interval, got %s", elapsed)
+	}
+	registry.record(entries[0], start.Add(time.Minute), errors.New("boom"))
+	if elapsed := registry.record(entries[0], start.Add(2*time.Minute), nil); elapsed != 2*time.Minute {
+		t.Fatalf("elapsed should span the failed run, got %s", elapsed)
+	}
+
+	registry.record(entries[0], start.Add(3*time.Minute), errors.New("boom"))
+	status := registry.Statuses()[0]
+	if status.Healthy || status.LastError != "boom" || status.ConsecutiveFailures != 1 || status.LastSuccessAt == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if registry.Statuses()[1].Enabled {
+		t.Fatalf("disabled collector reported as enabled")
+	}
+}
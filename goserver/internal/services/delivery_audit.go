@@ -0,0 +1,24 @@
+package services
+
+import (
+	"github.com/LevanPro/server/internal/models"
+)
+
+// DeliveryAuditFilter selects audit records; zero fields match everything
+type DeliveryAuditFilter struct {
+	User  string
+	Event string
+	Limit int
+}
+
+func (f DeliveryAuditFilter) match(record models.DeliveryRecord) bool {
+	return (f.User == "" || record.User == f.User) && (f.Event == "" || record.Event == f.Event)
+}
+
+// deliveryAudit is the append-only JSON Lines log of issued and delivered
+// download links
+type deliveryAudit = jsonLinesLog[models.DeliveryRecord]
+
+func newDeliveryAudit(path string) (*deliveryAudit, error) {
+	return newJSONLinesLog[models.DeliveryRecord](path, "delivery audit", 0600)
+}
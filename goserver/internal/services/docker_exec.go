@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// execInContainer runs cmd inside a container and returns its stdout. A non-zero
+// exit status is reported as an error carrying stderr.
+func execInContainer(ctx context.Context, cli *client.Client, containerName string, cmd []string) (string, error) {
+	execID, err := cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, execID.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+
+	outputBuf := new(bytes.Buffer)
+	errorBuf := new(bytes.Buffer)
+
+	if _, err := stdcopy.StdCopy(outputBuf, errorBuf, resp.Reader); err != nil {
+		return "", fmt.Errorf("failed to decode docker stream: %w", err)
+	}
+
+	inspect, err := cli.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if inspect.ExitCode != 0 {
+		return outputBuf.String(), fmt.Errorf("%s exited with status %d: %s", cmd[0], inspect.ExitCode, strings.TrimSpace(errorBuf.String()))
+	}
+
+	return outputBuf.String(), nil
+}
+
+// inspectRunning inspects a container. It returns nil without error when the
+// container does not exist or is not running; any other failure, such as an
+// unreachable Docker socket, is an error.
+func inspectRunning(ctx context.Context, cli *client.Client, containerName string) (*container.InspectResponse, error) {
+	info, err := cli.ContainerInspect(ctx, containerName)
+	if errdefs.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", containerName, err)
+	}
+	if info.ContainerJSONBase == nil || info.State == nil || !info.State.Running {
+		return nil, nil
+	}
+	return &info, nil
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LevanPro/server/internal/fsutil"
+	"github.com/LevanPro/server/internal/models"
+)
+
+var (
+	// ErrLinkNotFound is returned for unknown, expired or already used download links
+	ErrLinkNotFound = errors.New("download link not found or expired")
+	// ErrInvalidLink is returned for link requests that cannot be issued
+	ErrInvalidLink = errors.New("invalid download link request")
+)
+
+// Rejected unknown tokens are audited at most unknownTokenAuditLimit times per
+// remote address within unknownTokenAuditWindow, so guessing does not grow
+// the audit log without bound; the rest are only counted in the service log
+const (
+	unknownTokenAuditLimit   = 20
+	unknownTokenAuditWindow  = 10 * time.Minute
+	unknownTokenAuditTracked = 10000
+)
+
+// DownloadLinkOptions configures where links and their audit log are kept
+type DownloadLinkOptions struct {
+	Dir       string // one encrypted file per unused link
+	AuditPath string // JSON Lines delivery audit log
+	TTL       time.Duration
+	MaxTTL    time.Duration
+}
+
+// LinkPayload is what a link delivers, captured when the link is issued
+type LinkPayload struct {
+	Kind        string `json:"kind"`
+	User        string `json:"user"`
+	Platform    string `json:"platform,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// storedLink is the file of an unused link. Only the sealed payload holds
+// secrets; it is encrypted with a key derived from the token, which is never
+// stored, so the files alone cannot be opened.
+type storedLink struct {
+	Kind      string    `json:"kind"`
+	User      string    `json:"user"`
+	Platform  string    `json:"platform,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Sealed    []byte    `json:"sealed"` // nonce followed by the AES-GCM ciphertext
+}
+
+// DownloadLinkService issues single-use, short-lived links to credentials and
+// profiles, and audits their delivery
+type DownloadLinkService struct {
+	opts   DownloadLinkOptions
+	audit  *deliveryAudit
+	logger *slog.Logger
+	Now    func() time.Time
+
+	unknownTokens *FailureLimiter // key: remote address without port
+	suppressed    map[string]int  // unaudited unknown tokens per remote address
+
+	mu sync.Mutex
+}
+
+func NewDownloadLinkService(opts DownloadLinkOptions, logger *slog.Logger) (*DownloadLinkService, error) {
+	if opts.TTL <= 0 || opts.MaxTTL < opts.TTL {
+		return nil, fmt.Errorf("invalid link lifetimes %s and %s", opts.TTL, opts.MaxTTL)
+	}
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating download link directory: %w", err)
+	}
+	audit, err := newDeliveryAudit(opts.AuditPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DownloadLinkService{
+		opts:          opts,
+		audit:         audit,
+		logger:        logger,
+		Now:           time.Now,
+		unknownTokens: NewFailureLimiter(unknownTokenAuditLimit, unknownTokenAuditWindow, unknownTokenAuditTracked),
+		suppressed:    make(map[string]int),
+	}
+	s.unknownTokens.Now = func() time.Time { return s.Now() }
+	return s, nil
+}
+
+// Create issues a link to payload valid for ttl, the default lifetime when
+// zero. It returns the token of the link and its expiry.
+func (s *DownloadLinkService) Create(payload LinkPayload, ttl time.Duration, remoteAddress string) (string, time.Time, error) {
+	if ttl == 0 {
+		ttl = s.opts.TTL
+	}
+	if ttl < 0 || ttl > s.opts.MaxTTL {
+		return "", time.Time{}, fmt.Errorf("%w: ttl must be at most %s", ErrInvalidLink, s.opts.MaxTTL)
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate link token: %w", err)
+	}
+	id := linkID(token)
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	sealed, err := sealLink(token, id, plaintext)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+
+	now := s.Now().UTC()
+	link := storedLink{
+		Kind:      payload.Kind,
+		User:      payload.User,
+		Platform:  payload.Platform,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Sealed:    sealed,
+	}
+	data, err := json.Marshal(link)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := fsutil.WriteFileAtomic(s.path(id), data, 0600); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store download link: %w", err)
+	}
+
+	s.record(models.DeliveryRecord{
+		Event:         models.DeliveryIssued,
+		LinkID:        id,
+		Kind:          link.Kind,
+		User:          link.User,
+		Platform:      link.Platform,
+		ExpiresAt:     &link.ExpiresAt,
+		RemoteAddress: remoteAddress,
+	})
+	return base64.RawURLEncoding.EncodeToString(token), link.ExpiresAt, nil
+}
+
+// LinkInfo is what can be told about an unused link without consuming it
+type LinkInfo struct {
+	Kind      string    `json:"kind"`
+	Platform  string    `json:"platform,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Peek describes a link without consuming it, for the confirmation step
+// before the download. Unknown tokens are audited as in Consume.
+func (s *DownloadLinkService) Peek(token, remoteAddress, userAgent string) (*LinkInfo, error) {
+	rejected := models.DeliveryRecord{Event: models.DeliveryRejected, RemoteAddress: remoteAddress, UserAgent: userAgent}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 32 {
+		s.recordUnknown(rejected)
+		return nil, ErrLinkNotFound
+	}
+	id := linkID(raw)
+	rejected.LinkID = id
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		s.recordUnknown(rejected)
+		return nil, ErrLinkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download link: %w", err)
+	}
+
+	var link storedLink
+	if err := json.Unmarshal(data, &link); err != nil {
+		return nil, fmt.Errorf("failed to parse download link: %w", err)
+	}
+	if !s.Now().Before(link.ExpiresAt) {
+		return nil, ErrLinkNotFound
+	}
+	return &LinkInfo{Kind: link.Kind, Platform: link.Platform, ExpiresAt: link.ExpiresAt}, nil
+}
+
+// Consume returns the payload of a link and invalidates it. Every attempt on
+// an issued link is audited; unknown or used tokens are audited up to a limit
+// per remote address.
+func (s *DownloadLinkService) Consume(token, remoteAddress, userAgent string) (*LinkPayload, error) {
+	rejected := models.DeliveryRecord{Event: models.DeliveryRejected, RemoteAddress: remoteAddress, UserAgent: userAgent}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 32 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.recordUnknown(rejected)
+		return nil, ErrLinkNotFound
+	}
+	id := linkID(raw)
+	rejected.LinkID = id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		s.recordUnknown(rejected)
+		return nil, ErrLinkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download link: %w", err)
+	}
+
+	// The link is used up whatever happens next
+	if err := os.Remove(s.path(id)); err != nil {
+		return nil, fmt.Errorf("failed to invalidate download link: %w", err)
+	}
+
+	var link storedLink
+	if err := json.Unmarshal(data, &link); err != nil {
+		return nil, fmt.Errorf("failed to parse download link: %w", err)
+	}
+	rejected.Kind, rejected.User, rejected.Platform = link.Kind, link.User, link.Platform
+	if !s.Now().Before(link.ExpiresAt) {
+		s.record(rejected)
+		return nil, ErrLinkNotFound
+	}
+
+	plaintext, err := openLink(raw, id, link.Sealed)
+	if err != nil {
+		return nil, err
+	}
+	var payload LinkPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse download link payload: %w", err)
+	}
+
+	s.record(models.DeliveryRecord{
+		Event:         models.DeliveryDelivered,
+		LinkID:        id,
+		Kind:          link.Kind,
+		User:          link.User,
+		Platform:      link.Platform,
+		RemoteAddress: remoteAddress,
+		UserAgent:     userAgent,
+	})
+	return &payload, nil
+}
+
+// Audit returns the delivery audit log, most recent first
+func (s *DownloadLinkService) Audit(filter DeliveryAuditFilter) ([]models.DeliveryRecord, error) {
+	records, err := s.audit.List(filter.match)
+	if err != nil {
+		return nil, err
+	}
+	return limitRecords(records, filter.Limit), nil
+}
+
+// Sweep removes the files of expired links
+func (s *DownloadLinkService) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+}
+
+func (s *DownloadLinkService) sweep() {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		s.logger.Warn("Failed to list download links", "error", err.Error())
+		return
+	}
+
+	now := s.Now()
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(s.path(id))
+		if err != nil {
+			continue
+		}
+		var link storedLink
+		if json.Unmarshal(data, &link) == nil && now.Before(link.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(s.path(id)); err != nil {
+			s.logger.Warn("Failed to remove expired download link", "link_id", id, "error", err.Error())
+			continue
+		}
+		s.record(models.DeliveryRecord{Event: models.DeliveryExpired, LinkID: id, Kind: link.Kind, User: link.User, Platform: link.Platform})
+	}
+}
+
+// recordUnknown audits the rejection of an unknown token unless its remote
+// address is over the limit. Caller must hold s.mu.
+func (s *DownloadLinkService) recordUnknown(record models.DeliveryRecord) {
+	host := record.RemoteAddress
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if s.unknownTokens.Blocked(host) {
+		if _, ok := s.suppressed[host]; !ok && len(s.suppressed) >= unknownTokenAuditTracked {
+			s.logger.Warn("Rejected download links were not audited", "remote_addresses", len(s.suppressed))
+			clear(s.suppressed)
+		}
+		s.suppressed[host]++
+		return
+	}
+	if count := s.suppressed[host]; count > 0 {
+		delete(s.suppressed, host)
+		s.logger.Warn("Rejected download links were not audited", "remote_address", host, "count", count)
+	}
+
+	s.record(record)
+	if s.unknownTokens.Fail(host) {
+		s.logger.Warn("Too many unknown download links, pausing their audit", "remote_address", host, "for", unknownTokenAuditWindow.String())
+	}
+}
+
+func (s *DownloadLinkService) path(id string) string {
+	return filepath.Join(s.opts.Dir, id+".json")
+}
+
+// record audits an event; a failing audit log is logged but does not stop deliveries
+func (s *DownloadLinkService) record(record models.DeliveryRecord) {
+	record.Time = s.Now().UTC()
+	if len(record.LinkID) > 12 {
+		record.LinkID = record.LinkID[:12]
+	}
+	if err := s.audit.Append(record); err != nil {
+		s.logger.Error("Failed to audit download link", "event", record.Event, "user", record.User, "error", err.Error())
+	}
+}
+
+// linkID names the file of a link, the hex SHA-256 of its token
+func linkID(token []byte) string {
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:])
+}
+
+func linkCipher(token []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, token, nil, "goserver download link", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealLink encrypts a payload, bound to the link id so that files cannot be swapped
+func sealLink(token []byte, id string, plaintext []byte) ([]byte, error) {
+	aead, err := linkCipher(token)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(id)), nil
+}
+
+func openLink(token []byte, id string, sealed []byte) ([]byte, error) {
+	aead, err := linkCipher(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("download link payload is truncated")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt download link: %w", err)
+	}
+	return plaintext, nil
+}
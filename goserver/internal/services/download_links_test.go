@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+func newTestDownloadLinks(t *testing.T) (*DownloadLinkService, *time.Time) {
+	t.Helper()
+
+	dir := t.TempDir()
+	s, err := NewDownloadLinkService(DownloadLinkOptions{
+		Dir:       filepath.Join(dir, "links"),
+		AuditPath: filepath.Join(dir, "deliveries.jsonl"),
+		TTL:       15 * time.Minute,
+		MaxTTL:    time.Hour,
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestDownloadLinkService(t *testing.T) {
+	s, now := newTestDownloadLinks(t)
+	secret := []byte(`{"password":"s3cret-password"}`)
+
+	token, expiresAt, err := s.Create(LinkPayload{Kind: models.LinkKindCredentials, User: "alice", Data: secret}, 0, "10.0.0.1:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 43 || !expiresAt.Equal(now.Add(15*time.Minute)) {
+		t.Fatalf("unexpected link %q expiring %v", token, expiresAt)
+	}
+
+	// Neither the token nor the plaintext is on disk
+	files, _ := filepath.Glob(filepath.Join(s.opts.Dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("expected one link file, got %v", files)
+	}
+	stored, _ := os.ReadFile(files[0])
+	if bytes.Contains(stored, []byte("s3cret")) || bytes.Contains(stored, []byte(token)) {
+		t.Fatalf("link stored in the clear: %s", stored)
+	}
+
+	// Looking at the link, as a chat preview does, leaves it usable
+	info, err := s.Peek(token, "198.51.100.3:4000", "Slackbot")
+	if err != nil || info.Kind != models.LinkKindCredentials || !info.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("unexpected link info %+v, %v", info, err)
+	}
+
+	payload, err := s.Consume(token, "192.0.2.7:4000", "curl/8")
+	if err != nil || payload.User != "alice" || !bytes.Equal(payload.Data, secret) {
+		t.Fatalf("unexpected payload %+v, %v", payload, err)
+	}
+	if _, err := s.Consume(token, "192.0.2.7:4000", "curl/8"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("expected a used link to be gone, got %v", err)
+	}
+	if _, err := s.Peek(token, "192.0.2.7:4000", "curl/8"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("expected a used link to be gone, got %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(s.opts.Dir, "*")); len(files) != 0 {
+		t.Errorf("used link still on disk: %v", files)
+	}
+
+	if _, _, err := s.Create(LinkPayload{User: "alice"}, 2*time.Hour, ""); !errors.Is(err, ErrInvalidLink) {
+		t.Errorf("expected a ttl above the maximum to be rejected, got %v", err)
+	}
+
+	expired, _, _ := s.Create(LinkPayload{Kind: models.LinkKindProfile, User: "bob", Platform: "ios"}, 0, "")
+	*now = now.Add(15 * time.Minute)
+	if _, err := s.Consume(expired, "", ""); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("expected an expired link to be rejected, got %v", err)
+	}
+
+	records, err := s.Audit(DeliveryAuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, record := range records {
+		events = append(events, record.Event+":"+record.User)
+	}
+	want := []string{"rejected:bob", "issued:bob", "rejected:", "rejected:", "delivered:alice", "issued:alice"}
+	if len(events) != len(want) {
+		t.Fatalf("unexpected audit %v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("unexpected audit %v", events)
+		}
+	}
+	if delivered := records[4]; delivered.RemoteAddress != "192.0.2.7:4000" || delivered.UserAgent != "curl/8" || len(delivered.LinkID) != 12 {
+		t.Errorf("unexpected delivery record %+v", delivered)
+	}
+}
+
+func TestDownloadLinkServiceSweep(t *testing.T) {
+	s, now := newTestDownloadLinks(t)
+
+	if _, _, err := s.Create(LinkPayload{Kind: models.LinkKindCredentials, User: "alice"}, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Hour)
+	s.Sweep()
+
+	if files, _ := filepath.Glob(filepath.Join(s.opts.Dir, "*")); len(files) != 0 {
+		t.Errorf("expired link still on disk: %v", files)
+	}
+	records, _ := s.Audit(DeliveryAuditFilter{Event: models.DeliveryExpired})
+	if len(records) != 1 || records[0].User != "alice" {
+		t.Errorf("expected the expiry to be audited, got %+v", records)
+	}
+}
+
+func TestDownloadLinkServiceLimitsUnknownTokenAudit(t *testing.T) {
+	s, now := newTestDownloadLinks(t)
+
+	for i := 0; i < unknownTokenAuditLimit+10; i++ {
+		if _, err := s.Consume("guess", "198.51.100.9:4000", ""); !errors.Is(err, ErrLinkNotFound) {
+			t.Fatalf("expected an unknown token to be rejected, got %v", err)
+		}
+	}
+	if _, err := s.Consume("guess", "203.0.113.1:4000", ""); !errors.Is(err, ErrLinkNotFound) {
+		t.Fatal(err)
+	}
+	records, _ := s.Audit(DeliveryAuditFilter{Event: models.DeliveryRejected})
+	if len(records) != unknownTokenAuditLimit+1 {
+		t.Fatalf("expected %d audited rejections, got %d", unknownTokenAuditLimit+1, len(records))
+	}
+	if s.suppressed["198.51.100.9"] != 10 {
+		t.Errorf("expected 10 suppressed rejections, got %v", s.suppressed)
+	}
+
+	// Auditing resumes after the window
+	*now = now.Add(unknownTokenAuditWindow)
+	s.Consume("guess", "198.51.100.9:4000", "")
+	if records, _ := s.Audit(DeliveryAuditFilter{Event: models.DeliveryRejected}); len(records) != unknownTokenAuditLimit+2 {
+		t.Errorf("expected auditing to resume, got %d records", len(records))
+	}
+	if len(s.suppressed) != 0 {
+		t.Errorf("suppressed counts not reset: %v", s.suppressed)
+	}
+}
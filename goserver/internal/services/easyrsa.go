@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ClientPKI issues and revokes OpenVPN client certificates. Implementations
+// keep the easy-rsa pki/ layout (ca.crt, issued/, private/, index.txt, crl.pem)
+// that the rest of the OpenVPN tooling reads.
+type ClientPKI interface {
+	IssueClient(ctx context.Context, name string, days int) error
+	RevokeClient(ctx context.Context, name string) error
+	GenerateCRL(ctx context.Context, days int) error
+}
+
+// EasyRSA drives the easy-rsa script installed by openvpn.sh
+type EasyRSA struct {
+	dir string // directory containing the easyrsa script and pki/
+}
+
+// NewEasyRSA fails unless the easyrsa script in dir can be run, so that a
+// missing script or interpreter shows at startup rather than on the first
+// certificate request
+func NewEasyRSA(dir string) (*EasyRSA, error) {
+	if err := checkRunnable(filepath.Join(dir, "easyrsa")); err != nil {
+		return nil, err
+	}
+	return &EasyRSA{dir: dir}, nil
+}
+
+func (e *EasyRSA) IssueClient(ctx context.Context, name string, days int) error {
+	return e.run(ctx, "--batch", "--days="+strconv.Itoa(days), "build-client-full", name, "nopass")
+}
+
+func (e *EasyRSA) RevokeClient(ctx context.Context, name string) error {
+	return e.run(ctx, "--batch", "revoke", name)
+}
+
+func (e *EasyRSA) GenerateCRL(ctx context.Context, days int) error {
+	return e.run(ctx, "--batch", "--days="+strconv.Itoa(days), "gen-crl")
+}
+
+func (e *EasyRSA) run(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "./easyrsa", args...)
+	cmd.Dir = e.dir
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("easyrsa %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// checkRunnable verifies that a script exists, is executable and that the
+// interpreter named on its #! line is installed
+func checkRunnable(script string) error {
+	info, err := os.Stat(script)
+	if err != nil {
+		return fmt.Errorf("easyrsa script not found: %w", err)
+	}
+	if info.Mode()&0111 == 0 {
+		return fmt.Errorf("easyrsa script %s is not executable", script)
+	}
+
+	file, err := os.Open(script)
+	if err != nil {
+		return fmt.Errorf("failed to open easyrsa script: %w", err)
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	interpreter, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		return fmt.Errorf("easyrsa script %s has an empty #! line", script)
+	}
+	// "#!/usr/bin/env bash" names the interpreter in its argument
+	name := fields[0]
+	if filepath.Base(name) == "env" && len(fields) > 1 {
+		name = fields[1]
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("interpreter %s of easyrsa script is not installed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewEasyRSAChecksScript(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "easyrsa")
+
+	if _, err := NewEasyRSA(dir); err == nil {
+		t.Error("expected an error for a missing script")
+	}
+
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexit 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEasyRSA(dir); err == nil {
+		t.Error("expected an error for a script that is not executable")
+	}
+
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("#!/usr/bin/env no-such-interpreter\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEasyRSA(dir); err == nil {
+		t.Error("expected an error for a missing interpreter")
+	}
+
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEasyRSA(dir); err != nil {
+		t.Errorf("runnable script rejected: %v", err)
+	}
+}
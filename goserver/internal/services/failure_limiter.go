@@ -0,0 +1,101 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+type failureEntry struct {
+	count int
+	last  time.Time
+	until time.Time // blocked until, once count reached the limit
+}
+
+// FailureLimiter counts failures per key, such as a username or a remote
+// address, and blocks a key for a lockout once it fails limit times without
+// a lockout's pause in between. At most capacity keys are tracked; when full,
+// idle keys are forgotten first, then the least recently failed.
+type FailureLimiter struct {
+	limit    int
+	lockout  time.Duration
+	capacity int
+	Now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]failureEntry
+}
+
+func NewFailureLimiter(limit int, lockout time.Duration, capacity int) *FailureLimiter {
+	return &FailureLimiter{
+		limit:    limit,
+		lockout:  lockout,
+		capacity: capacity,
+		Now:      time.Now,
+		entries:  make(map[string]failureEntry),
+	}
+}
+
+// Blocked reports whether key is locked out
+func (l *FailureLimiter) Blocked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.Now().Before(l.entries[key].until)
+}
+
+// Fail counts a failure of key and reports whether key is now locked out
+func (l *FailureLimiter) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	entry, ok := l.entries[key]
+	if !ok {
+		l.makeRoom(now)
+	}
+	if now.Before(entry.until) {
+		return true
+	}
+	if now.Sub(entry.last) >= l.lockout {
+		entry = failureEntry{}
+	}
+
+	entry.count++
+	entry.last = now
+	if entry.count >= l.limit {
+		entry = failureEntry{last: now, until: now.Add(l.lockout)}
+	}
+	l.entries[key] = entry
+	return !entry.until.IsZero()
+}
+
+// Reset forgets the failures of key, e.g. after a success
+func (l *FailureLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// makeRoom frees an entry for a new key. Caller must hold l.mu.
+func (l *FailureLimiter) makeRoom(now time.Time) {
+	if len(l.entries) < l.capacity {
+		return
+	}
+
+	for key, entry := range l.entries {
+		if !now.Before(entry.until) && now.Sub(entry.last) >= l.lockout {
+			delete(l.entries, key)
+		}
+	}
+
+	for len(l.entries) >= l.capacity {
+		var oldest string
+		for key, entry := range l.entries {
+			if oldest == "" || entry.last.Before(l.entries[oldest].last) {
+				oldest = key
+			}
+		}
+		delete(l.entries, oldest)
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailureLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewFailureLimiter(3, time.Minute, 2)
+	l.Now = func() time.Time { return now }
+
+	l.Fail("alice")
+	l.Fail("alice")
+	if l.Blocked("alice") {
+		t.Fatal("blocked before the limit")
+	}
+	if !l.Fail("alice") || !l.Blocked("alice") {
+		t.Fatal("not blocked at the limit")
+	}
+
+	now = now.Add(time.Minute)
+	if l.Blocked("alice") {
+		t.Error("still blocked after the lockout")
+	}
+
+	// Failures spread wider than the lockout do not add up
+	l.Fail("bob")
+	l.Fail("bob")
+	now = now.Add(2 * time.Minute)
+	if l.Fail("bob") {
+		t.Error("old failures were counted")
+	}
+
+	l.Reset("bob")
+	if _, ok := l.entries["bob"]; ok {
+		t.Error("reset key is still tracked")
+	}
+
+	// The least recently failed key makes room once the limiter is full
+	l.Fail("carol")
+	now = now.Add(time.Second)
+	l.Fail("dave")
+	now = now.Add(time.Second)
+	l.Fail("erin")
+	if len(l.entries) != 2 {
+		t.Errorf("tracking %d keys, want at most 2", len(l.entries))
+	}
+	if _, ok := l.entries["carol"]; ok {
+		t.Error("oldest key was not evicted")
+	}
+}
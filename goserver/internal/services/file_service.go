@@ -2,32 +2,259 @@ package services
 
 import (
 	"bufio"
+	"crypto/subtle"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/LevanPro/server/internal/fsutil"
 	"github.com/LevanPro/server/internal/models"
 )
 
+var (
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidCredentials covers unknown users as well, so callers cannot probe usernames
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserDisabled       = errors.New("user is disabled")
+	ErrUserExpired        = errors.New("user account has expired")
+	ErrInvalidAccount     = errors.New("invalid account")
+	// ErrInvalidPassword is returned for passwords the credential files cannot hold
+	ErrInvalidPassword = errors.New("password must be 8 to 64 printable ASCII characters without spaces, quotes, colons, backslashes or #")
+)
+
 type FileService struct {
 	storagePath string
+	// accountsPath holds disabled and expiry state per user, see models.UserAccount
+	accountsPath string
+
+	mu  sync.Mutex // serialises account and password updates
+	Now func() time.Time
 }
 
-func NewFileService(folderPath string) *FileService {
+func NewFileService(folderPath, accountsPath string) *FileService {
 	return &FileService{
-		storagePath: folderPath,
+		storagePath:  folderPath,
+		accountsPath: accountsPath,
+		Now:          time.Now,
 	}
 }
 
+// chapSecret is one client line of chap-secrets
+type chapSecret struct {
+	username string
+	password string
+}
+
 func (fileService *FileService) ReadFile() ([]models.User, error) {
+	result := make([]models.User, 0)
+
+	secrets, err := fileService.readChapSecrets()
+	if err != nil {
+		return result, err
+	}
+
+	psk, err := fileService.ReadPSKSecret()
+
+	if err != nil {
+		return result, err
+	}
+
+	accounts, err := fileService.ReadAccounts()
+	if err != nil {
+		return result, err
+	}
+
+	for _, secret := range secrets {
+		account := accounts[secret.username]
+		result = append(result, models.User{
+			Username:   secret.username,
+			PSKSecret:  psk,
+			Disabled:   account.Disabled,
+			ExpiresAt:  account.ExpiresAt,
+			QuotaBytes: account.QuotaBytes,
+		})
+	}
+
+	return result, nil
+}
+
+// Authenticate checks a password against chap-secrets, the store shared by
+// L2TP, IPsec XAuth and OpenVPN, and rejects disabled and expired accounts
+func (fileService *FileService) Authenticate(username, password string) (*models.User, error) {
+	return fileService.Verify(username, func(stored string) bool {
+		return password != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+	})
+}
+
+// Verify is Authenticate for challenge-response schemes such as CHAP, where
+// check proves knowledge of the stored password without seeing it in clear
+func (fileService *FileService) Verify(username string, check func(password string) bool) (*models.User, error) {
+	secrets, err := fileService.readChapSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	var match *chapSecret
+	for i := range secrets {
+		if secrets[i].username == username {
+			match = &secrets[i]
+			break
+		}
+	}
+	if match == nil || !check(match.password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	accounts, err := fileService.ReadAccounts()
+	if err != nil {
+		return nil, err
+	}
+	account := accounts[username]
+
+	if account.Disabled {
+		return nil, ErrUserDisabled
+	}
+	if account.Expired(fileService.Now()) {
+		return nil, ErrUserExpired
+	}
+
+	return accountUser(username, account), nil
+}
+
+// Credentials returns a user's password and the shared PSK, for rendering
+// client profiles
+func (fileService *FileService) Credentials(username string) (*models.User, error) {
+	secrets, err := fileService.readChapSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range secrets {
+		if secret.username != username {
+			continue
+		}
+
+		psk, err := fileService.ReadPSKSecret()
+		if err != nil {
+			return nil, err
+		}
+		accounts, err := fileService.ReadAccounts()
+		if err != nil {
+			return nil, err
+		}
+
+		user := accountUser(username, accounts[username])
+		user.Password = secret.password
+		user.PSKSecret = psk
+		return user, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+// ReadAccounts returns the account state by username; a missing file means
+// every user is enabled
+func (fileService *FileService) ReadAccounts() (map[string]models.UserAccount, error) {
+	accounts := make(map[string]models.UserAccount)
+
+	data, err := os.ReadFile(fileService.accountsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return accounts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading accounts: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("error parsing accounts %s: %w", fileService.accountsPath, err)
+	}
+	return accounts, nil
+}
+
+// SetAccount replaces the account state of an existing user
+func (fileService *FileService) SetAccount(username string, account models.UserAccount) (*models.User, error) {
+	fileService.mu.Lock()
+	defer fileService.mu.Unlock()
+
+	secrets, err := fileService.readChapSecrets()
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, secret := range secrets {
+		if secret.username == username {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrUserNotFound
+	}
+
+	accounts, err := fileService.ReadAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	if account.FramedIP != "" {
+		addr, err := netip.ParseAddr(account.FramedIP)
+		if err != nil || !addr.Is4() {
+			return nil, fmt.Errorf("%w: framed_ip must be an IPv4 address", ErrInvalidAccount)
+		}
+		account.FramedIP = addr.String()
+	}
+	if account.SessionTimeout < 0 {
+		return nil, fmt.Errorf("%w: session_timeout must not be negative", ErrInvalidAccount)
+	}
+
+	if account.ExpiresAt != nil {
+		expiresAt := account.ExpiresAt.UTC()
+		account.ExpiresAt = &expiresAt
+	}
+	if account == (models.UserAccount{}) {
+		delete(accounts, username)
+	} else {
+		accounts[username] = account
+	}
+
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileService.accountsPath), 0755); err != nil {
+		return nil, fmt.Errorf("error creating accounts directory: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(fileService.accountsPath, data, 0600); err != nil {
+		return nil, fmt.Errorf("error writing accounts: %w", err)
+	}
+
+	return accountUser(username, account), nil
+}
+
+// accountUser is the user view of an account entry, without credentials
+func accountUser(username string, account models.UserAccount) *models.User {
+	return &models.User{
+		Username:       username,
+		Disabled:       account.Disabled,
+		ExpiresAt:      account.ExpiresAt,
+		QuotaBytes:     account.QuotaBytes,
+		FramedIP:       account.FramedIP,
+		SessionTimeout: account.SessionTimeout,
+	}
+}
+
+// readChapSecrets reads the client lines of chap-secrets under a shared lock
+func (fileService *FileService) readChapSecrets() ([]chapSecret, error) {
 	sourceFile := filepath.Join(fileService.storagePath, "/ppp/chap-secrets")
 
-	result := make([]models.User, 0)
+	result := make([]chapSecret, 0)
 
 	maxRetries := 3
 
@@ -64,12 +291,6 @@ func (fileService *FileService) ReadFile() ([]models.User, error) {
 		file.Close()
 	}()
 
-	psk, err := fileService.ReadPSKSecret()
-
-	if err != nil {
-		return result, err
-	}
-
 	scanner := bufio.NewScanner(file)
 
 	for scanner.Scan() {
@@ -81,13 +302,12 @@ func (fileService *FileService) ReadFile() ([]models.User, error) {
 
 		fields := strings.Fields(line)
 		if len(fields) > 0 {
-			username := fields[0]
-			username = strings.Trim(username, "\"")
+			secret := chapSecret{username: strings.Trim(fields[0], "\"")}
+			if len(fields) > 2 {
+				secret.password = strings.Trim(fields[2], "\"")
+			}
 
-			result = append(result, models.User{
-				Username:  username,
-				PSKSecret: psk,
-			})
+			result = append(result, secret)
 		}
 	}
 
@@ -95,10 +315,6 @@ func (fileService *FileService) ReadFile() ([]models.User, error) {
 		return result, err
 	}
 
-	if err := scanner.Err(); err != nil {
-		return result, err
-	}
-
 	return result, nil
 }
 
@@ -114,6 +330,87 @@ func (fileService *FileService) AddUsers(users []models.User) error {
 	return nil
 }
 
+// SetPassword replaces a user's password in chap-secrets and its hash in
+// ipsec.d/passwd. Both files are rewritten in place under their locks, as
+// they may be bind-mounted into the IPsec container.
+func (fileService *FileService) SetPassword(username, password, passwordHashed string) error {
+	if len(password) < 8 || len(password) > 64 || strings.ContainsAny(password, "\"\\:#") ||
+		strings.ContainsFunc(password, func(r rune) bool { return r <= ' ' || r > '~' }) {
+		return ErrInvalidPassword
+	}
+
+	fileService.mu.Lock()
+	defer fileService.mu.Unlock()
+
+	found := false
+	err := rewriteFile(filepath.Join(fileService.storagePath, "/ppp/chap-secrets"), func(line string) string {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.Trim(fields[0], "\"") != username {
+			return line
+		}
+		found = true
+		fields[2] = fmt.Sprintf("%q", password)
+		return strings.Join(fields, " ")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update chap-secrets: %w", err)
+	}
+	if !found {
+		return ErrUserNotFound
+	}
+
+	found = false
+	passwdPath := filepath.Join(fileService.storagePath, "/ipsec.d/passwd")
+	err = rewriteFile(passwdPath, func(line string) string {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) < 2 || fields[0] != username {
+			return line
+		}
+		found = true
+		fields[1] = passwordHashed
+		return strings.Join(fields, ":")
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to update ipsec passwd: %w", err)
+	}
+	if !found {
+		return fileService.appendToIpsecPasswd([]models.User{{Username: username, PasswordHashed: passwordHashed}})
+	}
+	return nil
+}
+
+// rewriteFile replaces each line of a file with edit(line) under an exclusive lock
+func rewriteFile(path string, edit func(line string) string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock file %s: %w", path, err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	var b strings.Builder
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		b.WriteString(edit(scanner.Text()))
+		b.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(b.String()), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
 func (fileService *FileService) ReadPSKSecret() (string, error) {
 	path := filepath.Join(fileService.storagePath, "/ipsec.secrets")
 
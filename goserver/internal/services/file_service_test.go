@@ -1 +1,170 @@
 package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+func newTestFileService(t *testing.T) *FileService {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"ppp/chap-secrets": "# Secrets for authentication using CHAP\n" +
+			"\"alice\" l2tpd \"alicepass\" *\n" +
+			"\"bob\" l2tpd \"bobpass\" *\n",
+		"ipsec.secrets": "%any  %any  : PSK \"sharedkey\"\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewFileService(dir, filepath.Join(dir, "goserver", "accounts.json"))
+}
+
+func TestFileServiceAuthenticate(t *testing.T) {
+	fs := newTestFileService(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fs.Now = func() time.Time { return now }
+
+	if _, err := fs.Authenticate("alice", "alicepass"); err != nil {
+		t.Fatalf("valid credentials: %v", err)
+	}
+	for _, creds := range [][2]string{{"alice", "bobpass"}, {"carol", "alicepass"}, {"alice", ""}} {
+		if _, err := fs.Authenticate(creds[0], creds[1]); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%v: expected ErrInvalidCredentials, got %v", creds, err)
+		}
+	}
+
+	if _, err := fs.SetAccount("alice", models.UserAccount{Disabled: true}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if _, err := fs.Authenticate("alice", "alicepass"); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("expected ErrUserDisabled, got %v", err)
+	}
+
+	expiry := now.Add(-time.Minute)
+	if _, err := fs.SetAccount("alice", models.UserAccount{ExpiresAt: &expiry}); err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if _, err := fs.Authenticate("alice", "alicepass"); !errors.Is(err, ErrUserExpired) {
+		t.Errorf("expected ErrUserExpired, got %v", err)
+	}
+
+	expiry = now.Add(time.Hour)
+	fs.SetAccount("alice", models.UserAccount{ExpiresAt: &expiry})
+	if _, err := fs.Authenticate("alice", "alicepass"); err != nil {
+		t.Errorf("account before expiry: %v", err)
+	}
+
+	if _, err := fs.SetAccount("carol", models.UserAccount{Disabled: true}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestFileServiceReadFileMergesAccounts(t *testing.T) {
+	fs := newTestFileService(t)
+
+	if _, err := fs.SetAccount("bob", models.UserAccount{Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := fs.ReadFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Username != "alice" || users[0].Disabled || !users[1].Disabled {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+	if users[0].Password != "" || users[0].PSKSecret != "sharedkey" {
+		t.Errorf("ReadFile must not expose passwords: %+v", users[0])
+	}
+
+	// Clearing every field drops the entry
+	if _, err := fs.SetAccount("bob", models.UserAccount{}); err != nil {
+		t.Fatal(err)
+	}
+	accounts, _ := fs.ReadAccounts()
+	if len(accounts) != 0 {
+		t.Errorf("expected no accounts, got %v", accounts)
+	}
+}
+
+func TestAccessServiceCheck(t *testing.T) {
+	fs := newTestFileService(t)
+	bandwidth := &BandwidthService{accumulator: newAccumulator(time.Now().UTC())}
+	access := NewAccessService(fs, bandwidth)
+
+	if err := access.Check("cert-only-client"); err != nil {
+		t.Errorf("names without an account must be allowed: %v", err)
+	}
+
+	fs.SetAccount("alice", models.UserAccount{QuotaBytes: 1000})
+	bandwidth.accumulator.ClientTotals["alice"] = models.AccumulatedData{TotalBytesSent: 600}
+	if err := access.Check("alice"); err != nil {
+		t.Errorf("alice is under quota: %v", err)
+	}
+
+	// L2TP traffic counts towards the same quota
+	bandwidth.accumulator.PPPUserTotals["alice"] = models.AccumulatedData{TotalBytesReceived: 400}
+	if err := access.Check("alice"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	fs.SetAccount("bob", models.UserAccount{Disabled: true})
+	if err := access.Check("bob"); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("expected ErrUserDisabled, got %v", err)
+	}
+}
+
+func TestFileServiceSetPassword(t *testing.T) {
+	fs := newTestFileService(t)
+	passwd := filepath.Join(fs.storagePath, "ipsec.d", "passwd")
+	os.MkdirAll(filepath.Dir(passwd), 0755)
+	if err := os.WriteFile(passwd, []byte("alice:$1$old$hash:xauth-psk\nbob:$1$bob$hash:xauth-psk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"short", "has space1", `quote"d-pass`, "colon:pass"} {
+		if err := fs.SetPassword("alice", password, "x"); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("%q: expected ErrInvalidPassword, got %v", password, err)
+		}
+	}
+	if err := fs.SetPassword("carol", "newpassword", "x"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := fs.SetPassword("alice", "n3w-Passw0rd", "$1$new$hash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Authenticate("alice", "n3w-Passw0rd"); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+	if _, err := fs.Authenticate("alice", "alicepass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password still accepted: %v", err)
+	}
+	if _, err := fs.Authenticate("bob", "bobpass"); err != nil {
+		t.Errorf("other user changed: %v", err)
+	}
+
+	data, _ := os.ReadFile(passwd)
+	if string(data) != "alice:$1$new$hash:xauth-psk\nbob:$1$bob$hash:xauth-psk\n" {
+		t.Errorf("unexpected passwd:\n%s", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(fs.storagePath, "ppp", "chap-secrets"))
+	if !strings.HasPrefix(string(data), "# Secrets for authentication using CHAP\n") {
+		t.Errorf("comments not kept:\n%s", data)
+	}
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/docker/docker/client"
+)
+
+// ErrIKEv2Unavailable is returned when IKEv2 was never set up in the IPsec container
+var ErrIKEv2Unavailable = errors.New("IKEv2 is not set up in the IPsec container")
+
+// IKEv2 setup of the hwdsl2 image, see ipsec/run.sh
+const (
+	ikev2Script  = "/opt/src/ikev2.sh"
+	ikev2Conf    = "/etc/ipsec.d/ikev2.conf"
+	ikev2CertDB  = "sql:/etc/ipsec.d"
+	ikev2Exports = "/etc/ipsec.d/" // where ikev2.sh writes client files inside the container
+)
+
+// IKEv2 client files ikev2.sh exports, by format
+var ikev2ExportFormats = map[string]string{
+	"p12":          ".p12",
+	"mobileconfig": ".mobileconfig",
+	"sswan":        ".sswan",
+}
+
+// IKEv2Service manages IKEv2 clients through ikev2.sh in the IPsec container
+type IKEv2Service struct {
+	dockerClient  *client.Client
+	containerName string
+	logger        *slog.Logger
+
+	// exec runs a command in the container, replaced in tests
+	exec func(ctx context.Context, cmd []string) (string, error)
+
+	// Serializes certificate database changes
+	mu sync.Mutex
+}
+
+func NewIKEv2Service(containerName string, logger *slog.Logger) (*IKEv2Service, error) {
+	if containerName == "" {
+		containerName = ipsecContainerName
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	s := &IKEv2Service{
+		dockerClient:  cli,
+		containerName: containerName,
+		logger:        logger,
+	}
+	s.exec = func(ctx context.Context, cmd []string) (string, error) {
+		return execInContainer(ctx, s.dockerClient, s.containerName, cmd)
+	}
+	return s, nil
+}
+
+func (s *IKEv2Service) Close() error {
+	return s.dockerClient.Close()
+}
+
+// List returns every client certificate with its status, without the CA and
+// server certificates
+func (s *IKEv2Service) List(ctx context.Context) ([]models.IKEv2Client, error) {
+	conf, err := s.exec(ctx, []string{"cat", ikev2Conf})
+	if err != nil {
+		return nil, ErrIKEv2Unavailable
+	}
+	serverCert := ikev2ServerCert(conf)
+
+	output, err := s.exec(ctx, []string{"certutil", "-L", "-d", ikev2CertDB})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+
+	clients := make([]models.IKEv2Client, 0)
+	for _, name := range parseCertutilNicknames(output) {
+		if name == serverCert || !clientNamePattern.MatchString(name) {
+			continue
+		}
+		client, err := s.inspect(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *client)
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Name < clients[j].Name
+	})
+	return clients, nil
+}
+
+// Get returns a single client
+func (s *IKEv2Service) Get(ctx context.Context, name string) (*models.IKEv2Client, error) {
+	if !clientNamePattern.MatchString(name) {
+		return nil, ErrClientNotFound
+	}
+
+	clients, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range clients {
+		if client.Name == name {
+			return &client, nil
+		}
+	}
+	return nil, ErrClientNotFound
+}
+
+// inspect reads a certificate and asks NSS whether it is still valid for a
+// client, the check ikev2.sh --listclients uses
+func (s *IKEv2Service) inspect(ctx context.Context, name string) (*models.IKEv2Client, error) {
+	output, err := s.exec(ctx, []string{"sh", "-c",
+		`certutil -L -d "$1" -n "$2" -a && { certutil -V -u C -d "$1" -n "$2" 2>&1 || true; }`,
+		"sh", ikev2CertDB, name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate of %s: %w", name, err)
+	}
+
+	block, rest := pem.Decode([]byte(output))
+	if block == nil {
+		return nil, fmt.Errorf("no certificate found for %s", name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate of %s: %w", name, err)
+	}
+
+	return &models.IKEv2Client{
+		Name:      name,
+		Status:    certutilStatus(string(rest)),
+		Serial:    strings.ToUpper(cert.SerialNumber.Text(16)),
+		ExpiresAt: cert.NotAfter.UTC(),
+	}, nil
+}
+
+// Add creates a client with the default options of ikev2.sh and exports its files
+func (s *IKEv2Service) Add(ctx context.Context, name string) (*models.IKEv2Client, error) {
+	if !clientNamePattern.MatchString(name) {
+		return nil, ErrInvalidClientName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Get(ctx, name); err == nil {
+		return nil, ErrClientExists
+	} else if !errors.Is(err, ErrClientNotFound) {
+		return nil, err
+	}
+
+	if _, err := s.exec(ctx, []string{ikev2Script, "--addclient", name}); err != nil {
+		return nil, fmt.Errorf("failed to add IKEv2 client: %w", err)
+	}
+	s.logger.Info("IKEv2 client added", "name", name)
+
+	return s.Get(ctx, name)
+}
+
+// Revoke revokes a valid client certificate and removes its exported files
+func (s *IKEv2Service) Revoke(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, err := s.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if client.Status == models.CertificateRevoked {
+		return ErrClientNotFound
+	}
+
+	if _, err := s.exec(ctx, []string{ikev2Script, "--revokeclient", name, "-y"}); err != nil {
+		return fmt.Errorf("failed to revoke IKEv2 client: %w", err)
+	}
+
+	files := []string{"rm", "-f"}
+	for _, ext := range ikev2ExportFormats {
+		files = append(files, ikev2Exports+name+ext)
+	}
+	if _, err := s.exec(ctx, files); err != nil {
+		s.logger.Warn("Failed to remove exported IKEv2 client files", "name", name, "error", err.Error())
+	}
+
+	s.logger.Info("IKEv2 client revoked", "name", name)
+	return nil
+}
+
+// Export returns a client file in one of the ikev2ExportFormats, exporting it
+// again when it is missing from the container
+func (s *IKEv2Service) Export(ctx context.Context, name, format string) ([]byte, error) {
+	ext, ok := ikev2ExportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown IKEv2 export format %q", format)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, err := s.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if client.Status != models.CertificateValid {
+		return nil, ErrClientNotFound
+	}
+
+	path := ikev2Exports + name + ext
+	data, err := s.exec(ctx, []string{"cat", path})
+	if err == nil {
+		return []byte(data), nil
+	}
+
+	if _, err := s.exec(ctx, []string{ikev2Script, "--exportclient", name}); err != nil {
+		return nil, fmt.Errorf("failed to export IKEv2 client: %w", err)
+	}
+	data, err = s.exec(ctx, []string{"cat", path})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return []byte(data), nil
+}
+
+// ikev2ServerCert is the nickname of the server certificate, the leftcert of ikev2.conf
+func ikev2ServerCert(conf string) string {
+	for line := range strings.Lines(conf) {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "leftcert="); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// parseCertutilNicknames returns the nicknames of `certutil -L` that are not
+// certificate authorities. Each entry is a nickname, which may contain
+// spaces, followed by the trust attributes.
+func parseCertutilNicknames(output string) []string {
+	var names []string
+	for line := range strings.Lines(output) {
+		line = strings.TrimRight(line, " \r\n")
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+		nickname, trust := strings.TrimSpace(line[:i]), line[i+1:]
+		if nickname == "" || strings.Count(trust, ",") != 2 || strings.ContainsAny(trust, "Cc") {
+			continue
+		}
+		names = append(names, nickname)
+	}
+	return names
+}
+
+// certutilStatus maps the result of `certutil -V -u C` to a certificate status
+func certutilStatus(output string) string {
+	switch {
+	case strings.Contains(output, "revoked"):
+		return models.CertificateRevoked
+	case strings.Contains(output, "expired"):
+		return models.CertificateExpired
+	case strings.Contains(output, "certificate is valid"):
+		return models.CertificateValid
+	}
+	return "unknown"
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// fakeIKEv2Container answers the commands IKEv2Service runs like the hwdsl2 image would
+type fakeIKEv2Container struct {
+	t       *testing.T
+	certs   map[string]string // nickname -> PEM
+	revoked map[string]bool
+	files   map[string]string
+}
+
+func newFakeIKEv2Container(t *testing.T) *fakeIKEv2Container {
+	c := &fakeIKEv2Container{
+		t:       t,
+		certs:   make(map[string]string),
+		revoked: make(map[string]bool),
+		files:   map[string]string{ikev2Conf: "conn ikev2-cp\n  leftcert=vpn.example.com\n"},
+	}
+	c.certs["vpn.example.com"] = c.issue(1, "vpn.example.com")
+	c.certs["vpnclient"] = c.issue(2, "vpnclient")
+	c.files["/etc/ipsec.d/vpnclient.p12"] = "\x30\x82p12"
+	return c
+}
+
+func (c *fakeIKEv2Container) issue(serial int64, name string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2036, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func (c *fakeIKEv2Container) exec(_ context.Context, cmd []string) (string, error) {
+	switch {
+	case cmd[0] == "cat":
+		if data, ok := c.files[cmd[1]]; ok {
+			return data, nil
+		}
+		return "", errors.New("cat exited with status 1: No such file or directory")
+
+	case cmd[0] == "certutil":
+		var b strings.Builder
+		b.WriteString("\nCertificate Nickname                                         Trust Attributes\n")
+		b.WriteString("                                                             SSL,S/MIME,JAR/XPI\n\n")
+		b.WriteString("IKEv2 VPN CA                                                 CTu,u,u\n")
+		for _, name := range slices.Sorted(maps.Keys(c.certs)) {
+			fmt.Fprintf(&b, "%-60s u,u,u\n", name)
+		}
+		return b.String(), nil
+
+	case cmd[0] == "sh":
+		name := cmd[len(cmd)-1]
+		status := "certutil: certificate is valid\n"
+		if c.revoked[name] {
+			status = "certutil: certificate is invalid: Peer's Certificate has been revoked.\n"
+		}
+		return c.certs[name] + status, nil
+
+	case cmd[0] == ikev2Script && cmd[1] == "--addclient":
+		c.certs[cmd[2]] = c.issue(int64(10+len(c.certs)), cmd[2])
+		for _, ext := range ikev2ExportFormats {
+			c.files[ikev2Exports+cmd[2]+ext] = cmd[2] + ext
+		}
+		return "", nil
+
+	case cmd[0] == ikev2Script && cmd[1] == "--exportclient":
+		for _, ext := range ikev2ExportFormats {
+			c.files[ikev2Exports+cmd[2]+ext] = cmd[2] + ext
+		}
+		return "", nil
+
+	case cmd[0] == ikev2Script && cmd[1] == "--revokeclient":
+		c.revoked[cmd[2]] = true
+		return "", nil
+
+	case cmd[0] == "rm":
+		for _, path := range cmd[2:] {
+			delete(c.files, path)
+		}
+		return "", nil
+	}
+
+	c.t.Fatalf("unexpected command %q", cmd)
+	return "", nil
+}
+
+func newTestIKEv2Service(t *testing.T) (*IKEv2Service, *fakeIKEv2Container) {
+	container := newFakeIKEv2Container(t)
+	return &IKEv2Service{exec: container.exec, logger: slog.New(slog.DiscardHandler)}, container
+}
+
+func TestIKEv2ServiceList(t *testing.T) {
+	s, _ := newTestIKEv2Service(t)
+
+	clients, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 1 {
+		t.Fatalf("expected only vpnclient, got %+v", clients)
+	}
+	client := clients[0]
+	if client.Name != "vpnclient" || client.Status != models.CertificateValid || client.Serial != "2" ||
+		!client.ExpiresAt.Equal(time.Date(2036, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected client %+v", client)
+	}
+}
+
+func TestIKEv2ServiceLifecycle(t *testing.T) {
+	s, container := newTestIKEv2Service(t)
+	ctx := context.Background()
+
+	if _, err := s.Add(ctx, "bad name"); !errors.Is(err, ErrInvalidClientName) {
+		t.Fatalf("expected ErrInvalidClientName, got %v", err)
+	}
+	if _, err := s.Add(ctx, "vpnclient"); !errors.Is(err, ErrClientExists) {
+		t.Fatalf("expected ErrClientExists, got %v", err)
+	}
+
+	client, err := s.Add(ctx, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Name != "phone" || client.Status != models.CertificateValid {
+		t.Fatalf("unexpected client %+v", client)
+	}
+
+	// Files missing from the container are exported again
+	delete(container.files, ikev2Exports+"phone.sswan")
+	data, err := s.Export(ctx, "phone", "sswan")
+	if err != nil || string(data) != "phone.sswan" {
+		t.Fatalf("unexpected export %q, %v", data, err)
+	}
+
+	if err := s.Revoke(ctx, "phone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := container.files[ikev2Exports+"phone.p12"]; ok {
+		t.Error("exported files were not removed")
+	}
+	if _, err := s.Export(ctx, "phone", "p12"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("expected ErrClientNotFound exporting a revoked client, got %v", err)
+	}
+	if err := s.Revoke(ctx, "phone"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("expected ErrClientNotFound revoking twice, got %v", err)
+	}
+
+	client, err = s.Get(ctx, "phone")
+	if err != nil || client.Status != models.CertificateRevoked {
+		t.Errorf("expected a revoked client, got %+v, %v", client, err)
+	}
+}
+
+func TestIKEv2ServiceNotSetUp(t *testing.T) {
+	s, container := newTestIKEv2Service(t)
+	delete(container.files, ikev2Conf)
+
+	if _, err := s.List(context.Background()); !errors.Is(err, ErrIKEv2Unavailable) {
+		t.Fatalf("expected ErrIKEv2Unavailable, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// trafficStatusLine matches Libreswan `ipsec trafficstatus` lines, e.g.
+//
+//	006 #3: "xauth-psk"[2] 203.0.113.7, username=vpnuser, type=ESP, add_time=1700000000, inBytes=123, outBytes=456, lease=192.168.43.10/32
+var trafficStatusLine = regexp.MustCompile(`^(?:\d+ )?(#\d+): "([^"]+)"(\[\d+\])?\s*([^,\s]*),\s*(.*)$`)
+
+// parseIPSecTrafficStatus parses the output of `ipsec trafficstatus`
+func parseIPSecTrafficStatus(output string) []models.IPSecConnection {
+	connections := make([]models.IPSecConnection, 0)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		match := trafficStatusLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+
+		conn := models.IPSecConnection{
+			Serial:        match[1],
+			Connection:    match[2] + match[3],
+			RemoteAddress: match[4],
+		}
+
+		for _, field := range strings.Split(match[5], ", ") {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(value, "'")
+
+			switch key {
+			case "username":
+				conn.Username = value
+			case "id":
+				conn.PeerID = value
+			case "lease":
+				conn.VirtualIP = strings.TrimSuffix(value, "/32")
+			case "add_time":
+				if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+					conn.AddedAt = time.Unix(secs, 0).UTC()
+				}
+			case "inBytes":
+				conn.BytesIn, _ = strconv.ParseUint(value, 10, 64)
+			case "outBytes":
+				conn.BytesOut, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+
+		connections = append(connections, conn)
+	}
+
+	return connections
+}
+
+// updateIPSecConnectionRates computes per-connection rates against the previous tick.
+// Connections are matched by name and instance; a counter that went backwards
+// (SA replaced on rekey) is counted from zero.
+func updateIPSecConnectionRates(current []models.IPSecConnection, previous map[string]models.IPSecConnection, elapsed time.Duration) map[string]models.IPSecConnection {
+	updated := make(map[string]models.IPSecConnection, len(current))
+
+	for _, conn := range current {
+		if prev, ok := previous[conn.Connection]; ok {
+			sent := conn.BytesOut
+			if sent >= prev.BytesOut {
+				sent -= prev.BytesOut
+			}
+			received := conn.BytesIn
+			if received >= prev.BytesIn {
+				received -= prev.BytesIn
+			}
+			conn.Rate = updateRate(prev.Rate, sent, received, elapsed)
+		}
+
+		updated[conn.Connection] = conn
+	}
+
+	return updated
+}
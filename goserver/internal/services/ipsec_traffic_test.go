@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+const trafficStatusSample = `006 #3: "l2tp-psk"[1] 198.51.100.4, type=ESP, add_time=1700000000, inBytes=1000, outBytes=2000, id='198.51.100.4'
+006 #7: "xauth-psk"[2] 203.0.113.7, username=vpnuser, type=ESP, add_time=1700000100, inBytes=10, outBytes=20, lease=192.168.43.10/32
+006 #9: "ikev2-cp"[3] 192.0.2.1, type=ESP, add_time=1700000200, inBytes=5, outBytes=6, maxBytes=2^63B, id='CN=phone', lease=192.168.43.11/32
+000 Total IPsec connections: loaded 6, active 3
+`
+
+func TestParseIPSecTrafficStatus(t *testing.T) {
+	conns := parseIPSecTrafficStatus(trafficStatusSample)
+	if len(conns) != 3 {
+		t.Fatalf("expected 3 connections, got %d", len(conns))
+	}
+
+	xauth := conns[1]
+	if xauth.Serial != "#7" || xauth.Connection != "xauth-psk[2]" || xauth.RemoteAddress != "203.0.113.7" {
+		t.Fatalf("unexpected identity: %+v", xauth)
+	}
+	if xauth.Username != "vpnuser" || xauth.VirtualIP != "192.168.43.10" || xauth.BytesIn != 10 || xauth.BytesOut != 20 {
+		t.Fatalf("unexpected fields: %+v", xauth)
+	}
+	if !xauth.AddedAt.Equal(time.Unix(1700000100, 0)) {
+		t.Fatalf("unexpected add time: %s", xauth.AddedAt)
+	}
+
+	if conns[2].PeerID != "CN=phone" {
+		t.Fatalf("unexpected peer id: %q", conns[2].PeerID)
+	}
+}
+
+func TestUpdateRate(t *testing.T) {
+	rate := updateRate(models.ThroughputRate{}, 6000, 600, time.Minute)
+	if rate.SentPerSecond != 100 || rate.ReceivedPerSecond != 10 {
+		t.Fatalf("unexpected instantaneous rate: %+v", rate)
+	}
+	if rate.SmoothedSentPerSecond <= 0 || rate.SmoothedSentPerSecond >= rate.SentPerSecond {
+		t.Fatalf("smoothed rate should move towards the sample: %+v", rate)
+	}
+
+	idle := updateRate(rate, 0, 0, 0)
+	if idle.SentPerSecond != 0 || idle.SmoothedSentPerSecond != rate.SmoothedSentPerSecond {
+		t.Fatalf("zero interval must not change the smoothed rate: %+v", idle)
+	}
+}
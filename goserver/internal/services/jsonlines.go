@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+// jsonLinesLog is an append-only JSON Lines file of records, one per line
+type jsonLinesLog[T any] struct {
+	path string
+	name string // for error messages, e.g. "session journal"
+	perm os.FileMode
+	mu   sync.Mutex
+}
+
+func newJSONLinesLog[T any](path, name string, perm os.FileMode) (*jsonLinesLog[T], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating %s directory: %w", name, err)
+	}
+
+	return &jsonLinesLog[T]{path: path, name: name, perm: perm}, nil
+}
+
+// Append adds a record to the end of the log
+func (l *jsonLinesLog[T]) Append(record T) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, l.perm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", l.name, err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", l.name, err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.name, err)
+	}
+	return nil
+}
+
+// List returns the records match accepts, most recently appended first.
+// Lines that do not parse, such as one cut short by a crash, are skipped.
+func (l *jsonLinesLog[T]) List(match func(T) bool) ([]T, error) {
+	records := make([]T, 0)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", l.name, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record T
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if match(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", l.name, err)
+	}
+
+	slices.Reverse(records)
+	return records, nil
+}
+
+// limitRecords keeps the first limit records; zero keeps all
+func limitRecords[T any](records []T, limit int) []T {
+	if limit > 0 && len(records) > limit {
+		return records[:limit]
+	}
+	return records
+}
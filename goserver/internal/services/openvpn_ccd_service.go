@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/LevanPro/server/internal/fsutil"
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+)
+
+var (
+	// ErrInvalidClientConfig is returned for entries OpenVPN would reject or misroute
+	ErrInvalidClientConfig = errors.New("invalid client config")
+	// ErrClientConfigExists is returned when creating an entry that already exists
+	ErrClientConfigExists = errors.New("client config already exists")
+	// ErrClientConfigNotFound is returned when no entry exists for the common name
+	ErrClientConfigNotFound = errors.New("client config not found")
+	// ErrAddressInUse is returned when a static address is assigned or leased to another client
+	ErrAddressInUse = errors.New("address is already in use")
+)
+
+// OpenVPNCCDOptions configures the client-config-dir service
+type OpenVPNCCDOptions struct {
+	ServerDir string       // ccd/ and ipp.txt live here
+	Subnet    netip.Prefix // used when server.conf has no server directive, 10.8.0.0/24 in openvpn.sh
+	// Management, when set, disconnects clients when they are disabled
+	Management *OpenVPNManagementCollector
+}
+
+// OpenVPNCCDService manages per-client files in the client-config-dir
+type OpenVPNCCDService struct {
+	opts   OpenVPNCCDOptions
+	logger *slog.Logger
+
+	// Serializes changes so address conflict checks stay valid
+	mu sync.Mutex
+}
+
+func NewOpenVPNCCDService(opts OpenVPNCCDOptions, logger *slog.Logger) (*OpenVPNCCDService, error) {
+	if !opts.Subnet.IsValid() || !opts.Subnet.Addr().Is4() || opts.Subnet.Bits() > 30 {
+		return nil, fmt.Errorf("invalid OpenVPN subnet %s", opts.Subnet)
+	}
+	opts.Subnet = opts.Subnet.Masked()
+
+	return &OpenVPNCCDService{opts: opts, logger: logger}, nil
+}
+
+func (s *OpenVPNCCDService) ccdPath(name string) string {
+	return filepath.Join(s.opts.ServerDir, "ccd", name)
+}
+
+// List returns every entry of the client-config-dir. Entries that cannot be
+// read are listed with their error only.
+func (s *OpenVPNCCDService) List() ([]models.OpenVPNClientConfig, error) {
+	entries, unreadable, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]models.OpenVPNClientConfig, 0, len(entries)+len(unreadable))
+	for name, ccd := range entries {
+		configs = append(configs, clientConfigFromCCD(name, ccd))
+	}
+	for name, err := range unreadable {
+		configs = append(configs, models.OpenVPNClientConfig{CommonName: name, Error: err.Error()})
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].CommonName < configs[j].CommonName
+	})
+
+	return configs, nil
+}
+
+// Get returns the entry of a common name
+func (s *OpenVPNCCDService) Get(name string) (*models.OpenVPNClientConfig, error) {
+	if !clientNamePattern.MatchString(name) {
+		return nil, ErrClientConfigNotFound
+	}
+
+	ccd, err := s.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	config := clientConfigFromCCD(name, ccd)
+	return &config, nil
+}
+
+// Create writes the entry of a common name that has none yet
+func (s *OpenVPNCCDService) Create(ctx context.Context, config models.OpenVPNClientConfig) (*models.OpenVPNClientConfig, error) {
+	name := config.CommonName
+	if !clientNamePattern.MatchString(name) || name == serverCertName {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidClientConfig, ErrInvalidClientName)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.read(name); err == nil {
+		return nil, ErrClientConfigExists
+	} else if !errors.Is(err, ErrClientConfigNotFound) {
+		return nil, err
+	}
+
+	return s.write(ctx, name, config, nil)
+}
+
+// Update replaces the managed directives of an existing entry, keeping the others
+func (s *OpenVPNCCDService) Update(ctx context.Context, name string, config models.OpenVPNClientConfig) (*models.OpenVPNClientConfig, error) {
+	if !clientNamePattern.MatchString(name) {
+		return nil, ErrClientConfigNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.write(ctx, name, config, current.Other)
+}
+
+// Delete removes the entry of a common name
+func (s *OpenVPNCCDService) Delete(name string) error {
+	if !clientNamePattern.MatchString(name) {
+		return ErrClientConfigNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.ccdPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrClientConfigNotFound
+		}
+		return fmt.Errorf("failed to remove client config: %w", err)
+	}
+
+	s.logger.Info("OpenVPN client config deleted", "client", name)
+	return nil
+}
+
+// write validates and installs an entry. Caller must hold s.mu.
+func (s *OpenVPNCCDService) write(ctx context.Context, name string, config models.OpenVPNClientConfig, other []string) (*models.OpenVPNClientConfig, error) {
+	ccd, err := s.validate(name, config)
+	if err != nil {
+		return nil, err
+	}
+	ccd.Other = other
+
+	dir := filepath.Dir(s.ccdPath(name))
+	// OpenVPN reads the directory after dropping privileges
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create client config directory: %w", err)
+	}
+
+	tmp, err := fsutil.WriteTempFile(dir, name, ccd.Render(), 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write client config: %w", err)
+	}
+	if err := os.Rename(tmp, s.ccdPath(name)); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to install client config: %w", err)
+	}
+
+	// A disabled client is only refused on its next connection
+	if ccd.Disable && s.opts.Management != nil {
+		var cmdErr *openvpn.CommandError
+		if err := s.opts.Management.Kill(ctx, name); err != nil && !errors.As(err, &cmdErr) {
+			s.logger.Warn("Failed to disconnect disabled client", "client", name, "error", err.Error())
+		}
+	}
+
+	s.logger.Info("OpenVPN client config written", "client", name)
+
+	written := clientConfigFromCCD(name, ccd)
+	return &written, nil
+}
+
+// validate converts an API entry, checking addresses against the server
+// subnet, other entries and the leases in ipp.txt. Caller must hold s.mu.
+func (s *OpenVPNCCDService) validate(name string, config models.OpenVPNClientConfig) (*openvpn.CCD, error) {
+	subnet := s.subnet()
+	ccd := &openvpn.CCD{Disable: config.Disabled}
+
+	if config.IfconfigPush != "" {
+		addr, err := netip.ParseAddr(config.IfconfigPush)
+		if err != nil || !addr.Is4() {
+			return nil, fmt.Errorf("%w: ifconfig_push %q is not an IPv4 address", ErrInvalidClientConfig, config.IfconfigPush)
+		}
+		if err := s.checkClientAddress(name, addr); err != nil {
+			return nil, err
+		}
+		ccd.IfconfigPush = netip.PrefixFrom(addr, subnet.Bits())
+	}
+
+	for _, value := range config.IRoutes {
+		prefix, err := parseRoute(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: iroute: %v", ErrInvalidClientConfig, err)
+		}
+		if prefix.Overlaps(subnet) {
+			return nil, fmt.Errorf("%w: iroute %s overlaps the VPN subnet %s", ErrInvalidClientConfig, prefix, subnet)
+		}
+		ccd.IRoutes = append(ccd.IRoutes, prefix)
+	}
+
+	for _, value := range config.PushRoutes {
+		prefix, err := parseRoute(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: push route: %v", ErrInvalidClientConfig, err)
+		}
+		ccd.PushRoutes = append(ccd.PushRoutes, prefix)
+	}
+
+	for _, value := range config.DNS {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: dns %q is not an IP address", ErrInvalidClientConfig, value)
+		}
+		ccd.DNS = append(ccd.DNS, addr)
+	}
+
+	return ccd, nil
+}
+
+// checkClientAddress accepts host addresses of the subnet other than the
+// server's own that no other client holds
+func (s *OpenVPNCCDService) checkClientAddress(name string, addr netip.Addr) error {
+	subnet := s.subnet()
+
+	if !subnet.Contains(addr) {
+		return fmt.Errorf("%w: ifconfig_push %s is outside the VPN subnet %s", ErrInvalidClientConfig, addr, subnet)
+	}
+	network := subnet.Addr()
+	if addr == network || addr == network.Next() || addr == lastAddr(subnet) {
+		return fmt.Errorf("%w: ifconfig_push %s is the network, server or broadcast address", ErrInvalidClientConfig, addr)
+	}
+
+	entries, _, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	for other, ccd := range entries {
+		if other != name && ccd.IfconfigPush.IsValid() && ccd.IfconfigPush.Addr() == addr {
+			return fmt.Errorf("%w: %s is assigned to %s", ErrAddressInUse, addr, other)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.opts.ServerDir, "ipp.txt"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read ipp.txt: %w", err)
+	}
+	for _, lease := range openvpn.ParseIPPool(data) {
+		if lease.CommonName != name && lease.Address == addr {
+			return fmt.Errorf("%w: %s is leased to %s in ipp.txt", ErrAddressInUse, addr, lease.CommonName)
+		}
+	}
+
+	return nil
+}
+
+// subnet prefers the server directive of server.conf over the configured subnet
+func (s *OpenVPNCCDService) subnet() netip.Prefix {
+	data, err := os.ReadFile(filepath.Join(s.opts.ServerDir, "server.conf"))
+	if err != nil {
+		return s.opts.Subnet
+	}
+
+	config, err := openvpn.ParseServerConfig(data, nil)
+	if err != nil {
+		return s.opts.Subnet
+	}
+	subnet, err := config.SubnetPrefix()
+	if err != nil {
+		return s.opts.Subnet
+	}
+	return subnet
+}
+
+func (s *OpenVPNCCDService) read(name string) (*openvpn.CCD, error) {
+	data, err := os.ReadFile(s.ccdPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrClientConfigNotFound
+		}
+		return nil, fmt.Errorf("failed to read client config: %w", err)
+	}
+
+	ccd, err := openvpn.ParseCCD(data)
+	if err != nil {
+		return nil, fmt.Errorf("client config %s: %w", name, err)
+	}
+	return ccd, nil
+}
+
+// readAll parses every entry; files with names OpenVPN would never look up are
+// skipped. Entries that cannot be read or parsed are logged and returned
+// separately, so that one broken file does not block changes to the others.
+func (s *OpenVPNCCDService) readAll() (map[string]*openvpn.CCD, map[string]error, error) {
+	entries := make(map[string]*openvpn.CCD)
+	unreadable := make(map[string]error)
+
+	files, err := os.ReadDir(filepath.Join(s.opts.ServerDir, "ccd"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, unreadable, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read client config directory: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if !file.Type().IsRegular() || !clientNamePattern.MatchString(name) {
+			continue
+		}
+
+		ccd, err := s.read(name)
+		if err != nil {
+			s.logger.Warn("Skipping unreadable client config", "client", name, "error", err.Error())
+			unreadable[name] = err
+			continue
+		}
+		entries[name] = ccd
+	}
+
+	return entries, unreadable, nil
+}
+
+func clientConfigFromCCD(name string, ccd *openvpn.CCD) models.OpenVPNClientConfig {
+	config := models.OpenVPNClientConfig{
+		CommonName: name,
+		IRoutes:    make([]string, 0, len(ccd.IRoutes)),
+		PushRoutes: make([]string, 0, len(ccd.PushRoutes)),
+		DNS:        make([]string, 0, len(ccd.DNS)),
+		Disabled:   ccd.Disable,
+		Other:      ccd.Other,
+	}
+
+	if ccd.IfconfigPush.IsValid() {
+		config.IfconfigPush = ccd.IfconfigPush.Addr().String()
+	}
+	for _, route := range ccd.IRoutes {
+		config.IRoutes = append(config.IRoutes, route.String())
+	}
+	for _, route := range ccd.PushRoutes {
+		config.PushRoutes = append(config.PushRoutes, route.String())
+	}
+	for _, dns := range ccd.DNS {
+		config.DNS = append(config.DNS, dns.String())
+	}
+
+	return config
+}
+
+// parseRoute accepts an IPv4 network in CIDR notation, or a single address
+func parseRoute(value string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		addr, addrErr := netip.ParseAddr(value)
+		if addrErr != nil {
+			return netip.Prefix{}, fmt.Errorf("%q is not a network", value)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	if !prefix.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("%s is not an IPv4 network", prefix)
+	}
+	if prefix.Masked() != prefix {
+		return netip.Prefix{}, fmt.Errorf("%s has host bits set, use %s", prefix, prefix.Masked())
+	}
+	return prefix, nil
+}
+
+// lastAddr returns the broadcast address of an IPv4 prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	raw := prefix.Masked().Addr().As4()
+	for i := prefix.Bits(); i < 32; i++ {
+		raw[i/8] |= 1 << (7 - i%8)
+	}
+	return netip.AddrFrom4(raw)
+}
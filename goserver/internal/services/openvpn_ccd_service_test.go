@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+func TestOpenVPNCCDService(t *testing.T) {
+	serverDir := t.TempDir()
+	os.WriteFile(filepath.Join(serverDir, "ipp.txt"), []byte("bob,10.8.0.2\nalice,10.8.0.3\n"), 0600)
+
+	service, err := NewOpenVPNCCDService(OpenVPNCCDOptions{
+		ServerDir: serverDir,
+		Subnet:    netip.MustParsePrefix("10.8.0.0/24"),
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+
+	invalid := []models.OpenVPNClientConfig{
+		{CommonName: "alice", IfconfigPush: "10.9.0.5"},
+		{CommonName: "alice", IfconfigPush: "10.8.0.1"},
+		{CommonName: "alice", IfconfigPush: "10.8.0.255"},
+		{CommonName: "alice", IRoutes: []string{"10.8.0.0/16"}},
+		{CommonName: "alice", PushRoutes: []string{"192.168.1.1/24"}},
+		{CommonName: "alice", DNS: []string{"dns.example"}},
+		{CommonName: "bad name"},
+	}
+	for _, config := range invalid {
+		if _, err := service.Create(ctx, config); !errors.Is(err, ErrInvalidClientConfig) {
+			t.Errorf("expected %+v to be rejected, got %v", config, err)
+		}
+	}
+
+	if _, err := service.Create(ctx, models.OpenVPNClientConfig{CommonName: "alice", IfconfigPush: "10.8.0.2"}); !errors.Is(err, ErrAddressInUse) {
+		t.Errorf("expected the ipp.txt lease of bob to conflict, got %v", err)
+	}
+
+	// Alice may keep the address she already leases
+	config, err := service.Create(ctx, models.OpenVPNClientConfig{
+		CommonName:   "alice",
+		IfconfigPush: "10.8.0.3",
+		IRoutes:      []string{"192.168.10.0/24"},
+		PushRoutes:   []string{"172.16.0.0/12", "203.0.113.7"},
+		DNS:          []string{"1.1.1.1"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if config.IfconfigPush != "10.8.0.3" || len(config.PushRoutes) != 2 || config.PushRoutes[1] != "203.0.113.7/32" {
+		t.Errorf("unexpected config: %+v", config)
+	}
+	if _, err := service.Create(ctx, models.OpenVPNClientConfig{CommonName: "alice"}); !errors.Is(err, ErrClientConfigExists) {
+		t.Errorf("expected duplicate to be rejected, got %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(serverDir, "ccd", "alice"))
+	want := "ifconfig-push 10.8.0.3 255.255.255.0\n" +
+		"iroute 192.168.10.0 255.255.255.0\n" +
+		"push \"route 172.16.0.0 255.240.0.0\"\n" +
+		"push \"route 203.0.113.7 255.255.255.255\"\n" +
+		"push \"dhcp-option DNS 1.1.1.1\"\n"
+	if string(data) != want {
+		t.Errorf("unexpected ccd file:\n%s", data)
+	}
+
+	// Hand-written directives survive updates
+	os.WriteFile(filepath.Join(serverDir, "ccd", "alice"), append(data, "push-reset\n"...), 0644)
+	if _, err := service.Create(ctx, models.OpenVPNClientConfig{CommonName: "carol", IfconfigPush: "10.8.0.3"}); !errors.Is(err, ErrAddressInUse) {
+		t.Errorf("expected the static address of alice to conflict, got %v", err)
+	}
+
+	updated, err := service.Update(ctx, "alice", models.OpenVPNClientConfig{Disabled: true})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !updated.Disabled || updated.IfconfigPush != "" || len(updated.Other) != 1 {
+		t.Errorf("unexpected updated config: %+v", updated)
+	}
+	if _, err := service.Update(ctx, "carol", models.OpenVPNClientConfig{}); !errors.Is(err, ErrClientConfigNotFound) {
+		t.Errorf("expected update of a missing entry to fail, got %v", err)
+	}
+
+	configs, err := service.List()
+	if err != nil || len(configs) != 1 || configs[0].CommonName != "alice" {
+		t.Fatalf("unexpected list: %+v %v", configs, err)
+	}
+
+	// A broken entry is listed with its error and does not block the others
+	os.WriteFile(filepath.Join(serverDir, "ccd", "dave"), []byte("ifconfig-push 10.8.0.x 255.255.255.0\n"), 0644)
+	if _, err := service.Create(ctx, models.OpenVPNClientConfig{CommonName: "erin", IfconfigPush: "10.8.0.61"}); err != nil {
+		t.Fatalf("create next to a broken entry: %v", err)
+	}
+	configs, err = service.List()
+	if err != nil || len(configs) != 3 || configs[1].CommonName != "dave" || configs[1].Error == "" || configs[2].Error != "" {
+		t.Fatalf("unexpected list with a broken entry: %+v %v", configs, err)
+	}
+	os.Remove(filepath.Join(serverDir, "ccd", "dave"))
+	service.Delete("erin")
+
+	if err := service.Delete("alice"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := service.Get("alice"); !errors.Is(err, ErrClientConfigNotFound) {
+		t.Errorf("expected deleted entry to be gone, got %v", err)
+	}
+}
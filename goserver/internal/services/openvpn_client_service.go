@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/LevanPro/server/internal/fsutil"
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+	"github.com/LevanPro/server/internal/pki"
+)
+
+var (
+	// ErrInvalidClientName is returned for names openvpn.sh would not accept
+	ErrInvalidClientName = errors.New("invalid client name, use up to 64 letters, digits, '-' and '_'")
+	// ErrClientExists is returned when a valid certificate already exists for the name
+	ErrClientExists = errors.New("client already exists")
+	// ErrClientNotFound is returned when no valid certificate exists for the name
+	ErrClientNotFound = errors.New("client not found")
+)
+
+// clientNamePattern matches the names set_client_name in openvpn.sh produces
+var clientNamePattern = regexp.MustCompile(`^[0-9A-Za-z_-]{1,64}$`)
+
+// serverCertName is the certificate of the OpenVPN server itself
+const serverCertName = "server"
+
+// OpenVPNClientOptions configures the OpenVPN client service
+type OpenVPNClientOptions struct {
+	ServerDir  string // /etc/openvpn/server
+	ClientDays int
+	CRLDays    int
+	PKI        ClientPKI
+	// Management, when set, disconnects sessions of revoked clients
+	Management *OpenVPNManagementCollector
+}
+
+// OpenVPNClientService manages OpenVPN client certificates and profiles
+type OpenVPNClientService struct {
+	opts   OpenVPNClientOptions
+	logger *slog.Logger
+
+	// Serializes PKI changes
+	mu sync.Mutex
+}
+
+func NewOpenVPNClientService(opts OpenVPNClientOptions, logger *slog.Logger) *OpenVPNClientService {
+	if opts.ClientDays <= 0 {
+		opts.ClientDays = 3650
+	}
+	if opts.CRLDays <= 0 {
+		opts.CRLDays = 3650
+	}
+	if opts.PKI == nil {
+		opts.PKI = &EasyRSA{dir: filepath.Join(opts.ServerDir, "easy-rsa")}
+	}
+
+	return &OpenVPNClientService{opts: opts, logger: logger}
+}
+
+func (s *OpenVPNClientService) pkiPath(elem ...string) string {
+	return filepath.Join(append([]string{s.opts.ServerDir, "easy-rsa", "pki"}, elem...)...)
+}
+
+// List returns the latest certificate of every client, without the server certificate
+func (s *OpenVPNClientService) List() ([]models.OpenVPNClient, error) {
+	entries, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]models.OpenVPNClient)
+	for _, entry := range entries {
+		if entry.Name == serverCertName {
+			continue
+		}
+		latest[entry.Name] = entry
+	}
+
+	clients := make([]models.OpenVPNClient, 0, len(latest))
+	for _, client := range latest {
+		clients = append(clients, client)
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Name < clients[j].Name
+	})
+
+	return clients, nil
+}
+
+// Get returns the latest certificate of a client
+func (s *OpenVPNClientService) Get(name string) (*models.OpenVPNClient, error) {
+	if !clientNamePattern.MatchString(name) || name == serverCertName {
+		return nil, ErrClientNotFound
+	}
+
+	clients, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, client := range clients {
+		if client.Name == name {
+			return &client, nil
+		}
+	}
+	return nil, ErrClientNotFound
+}
+
+// Add issues a client certificate
+func (s *OpenVPNClientService) Add(ctx context.Context, name string) (*models.OpenVPNClient, error) {
+	if !clientNamePattern.MatchString(name) || name == serverCertName {
+		return nil, ErrInvalidClientName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.pkiPath("issued", name+".crt")); err == nil {
+		return nil, ErrClientExists
+	}
+
+	if err := s.opts.PKI.IssueClient(ctx, name, s.opts.ClientDays); err != nil {
+		return nil, fmt.Errorf("failed to issue client certificate: %w", err)
+	}
+
+	s.logger.Info("OpenVPN client added", "client", name)
+	return s.Get(name)
+}
+
+// Revoke revokes a client certificate, publishes the new CRL and removes the
+// client's key material, like revoke_client_ovpn in openvpn.sh. Connected
+// sessions of the client are disconnected when the management interface is
+// available, since OpenVPN only checks the CRL on (re)negotiation.
+func (s *OpenVPNClientService) Revoke(ctx context.Context, name string) error {
+	if !clientNamePattern.MatchString(name) || name == serverCertName {
+		return ErrClientNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.pkiPath("issued", name+".crt")); err != nil {
+		return ErrClientNotFound
+	}
+
+	if err := s.opts.PKI.RevokeClient(ctx, name); err != nil {
+		return fmt.Errorf("failed to revoke client certificate: %w", err)
+	}
+
+	if err := s.regenerateCRL(ctx); err != nil {
+		return err
+	}
+
+	for _, file := range []string{s.pkiPath("reqs", name+".req"), s.pkiPath("private", name+".key")} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("Failed to remove revoked client material", "file", file, "error", err.Error())
+		}
+	}
+
+	if s.opts.Management != nil {
+		var cmdErr *openvpn.CommandError
+		if err := s.opts.Management.Kill(ctx, name); err != nil && !errors.As(err, &cmdErr) {
+			s.logger.Warn("Failed to disconnect revoked client", "client", name, "error", err.Error())
+		}
+	}
+
+	s.logger.Info("OpenVPN client revoked", "client", name)
+	return nil
+}
+
+// RegenerateCRL issues a fresh CRL and installs it for the server
+func (s *OpenVPNClientService) RegenerateCRL(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.regenerateCRL(ctx)
+}
+
+// regenerateCRL must be called with s.mu held
+func (s *OpenVPNClientService) regenerateCRL(ctx context.Context) error {
+	if err := s.opts.PKI.GenerateCRL(ctx, s.opts.CRLDays); err != nil {
+		return fmt.Errorf("failed to generate CRL: %w", err)
+	}
+
+	crl, err := os.ReadFile(s.pkiPath("crl.pem"))
+	if err != nil {
+		return fmt.Errorf("failed to read generated CRL: %w", err)
+	}
+
+	return installCRL(filepath.Join(s.opts.ServerDir, "crl.pem"), crl)
+}
+
+// installCRL atomically replaces the server's crl.pem. The CRL is read on
+// every connection after OpenVPN dropped privileges, so it stays world
+// readable and keeps the owner openvpn.sh gave it.
+func installCRL(path string, crl []byte) error {
+	uid, gid := -1, -1
+	if info, err := os.Stat(path); err == nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+		}
+	}
+
+	tmp, err := fsutil.WriteTempFile(filepath.Dir(path), filepath.Base(path), crl, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write CRL: %w", err)
+	}
+
+	if uid >= 0 {
+		if err := os.Chown(tmp, uid, gid); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to set CRL owner: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to install CRL: %w", err)
+	}
+
+	return fsutil.SyncDir(filepath.Dir(path))
+}
+
+// Profile renders the inline .ovpn profile of a client with a valid certificate
+func (s *OpenVPNClientService) Profile(name string) ([]byte, error) {
+	client, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if client.Status != models.CertificateValid {
+		return nil, ErrClientNotFound
+	}
+
+	var profile openvpn.ClientProfile
+	files := map[string]*[]byte{
+		filepath.Join(s.opts.ServerDir, "client-common.txt"): &profile.Common,
+		s.pkiPath("ca.crt"):                       &profile.CA,
+		s.pkiPath("issued", name+".crt"):          &profile.Cert,
+		s.pkiPath("private", name+".key"):         &profile.Key,
+		filepath.Join(s.opts.ServerDir, "tc.key"): &profile.TLSCrypt,
+	}
+
+	for path, dst := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+		*dst = data
+	}
+
+	return openvpn.RenderInlineProfile(profile)
+}
+
+// readIndex parses pki/index.txt
+func (s *OpenVPNClientService) readIndex() ([]models.OpenVPNClient, error) {
+	data, err := os.ReadFile(s.pkiPath("index.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKI index: %w", err)
+	}
+	return parsePKIIndex(data, time.Now())
+}
+
+// parsePKIIndex maps the entries of an OpenSSL CA database to clients
+func parsePKIIndex(data []byte, now time.Time) ([]models.OpenVPNClient, error) {
+	entries, err := pki.ParseIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PKI index: %w", err)
+	}
+
+	clients := make([]models.OpenVPNClient, 0, len(entries))
+	for _, entry := range entries {
+		client := models.OpenVPNClient{
+			Name:      entry.CommonName(),
+			Serial:    entry.Serial,
+			ExpiresAt: entry.ExpiresAt,
+		}
+
+		switch entry.Status {
+		case pki.StatusValid:
+			client.Status = models.CertificateValid
+			if !entry.ExpiresAt.After(now) {
+				client.Status = models.CertificateExpired
+			}
+		case pki.StatusExpired:
+			client.Status = models.CertificateExpired
+		case pki.StatusRevoked:
+			client.Status = models.CertificateRevoked
+			revokedAt := entry.RevokedAt
+			client.RevokedAt = &revokedAt
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/LevanPro/server/internal/fsutil"
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+)
+
+var (
+	// ErrInvalidServerConfig is returned for server settings OpenVPN would reject
+	ErrInvalidServerConfig = errors.New("invalid server config")
+	// ErrRestartFailed is returned when the files were written but OpenVPN was not restarted
+	ErrRestartFailed = errors.New("server config written, but restarting OpenVPN failed")
+	// ErrFirewallChange is returned for changes the host firewall must follow
+	// before they are applied
+	ErrFirewallChange = errors.New("server config change needs a firewall change")
+)
+
+// openvpnIPv6Subnet is the IPv6 client subnet openvpn.sh and RenderServerConfig use
+const openvpnIPv6Subnet = "fddd:1194:1194:1194::/64"
+
+// OpenVPNRestarter makes the OpenVPN server read server.conf again
+type OpenVPNRestarter interface {
+	Restart(ctx context.Context) error
+}
+
+// ManagementRestarter sends SIGHUP through the management interface. OpenVPN
+// has dropped privileges by then, so ports below 1024 cannot be bound and,
+// with persist-tun, subnet changes do not reach the tun device; those need a
+// full restart through CommandRestarter.
+type ManagementRestarter struct {
+	Management *OpenVPNManagementCollector
+}
+
+func (r *ManagementRestarter) Restart(ctx context.Context) error {
+	if r.Management == nil {
+		return fmt.Errorf("%w: not enabled", ErrManagementUnavailable)
+	}
+	return r.Management.Signal(ctx, "SIGHUP")
+}
+
+// CommandRestarter runs a command, e.g. systemctl restart openvpn-server@server.service
+type CommandRestarter struct {
+	Command []string
+}
+
+func (r *CommandRestarter) Restart(ctx context.Context) error {
+	if len(r.Command) == 0 {
+		return errors.New("no restart command configured")
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.Command[0], r.Command[1:]...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", strings.Join(r.Command, " "), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// OpenVPNServerConfigOptions configures the server configuration service
+type OpenVPNServerConfigOptions struct {
+	ServerDir string
+	// Restarter applies written changes; nil leaves restarting to the operator
+	Restarter OpenVPNRestarter
+	// CCD, when set, keeps subnet changes from orphaning static client addresses
+	CCD *OpenVPNCCDService
+}
+
+// OpenVPNServerConfigService renders server.conf and client-common.txt from
+// a ServerConfig instead of the install-time choices of openvpn.sh
+type OpenVPNServerConfigService struct {
+	opts   OpenVPNServerConfigOptions
+	logger *slog.Logger
+
+	mu sync.Mutex
+}
+
+func NewOpenVPNServerConfigService(opts OpenVPNServerConfigOptions, logger *slog.Logger) *OpenVPNServerConfigService {
+	return &OpenVPNServerConfigService{opts: opts, logger: logger}
+}
+
+func (s *OpenVPNServerConfigService) serverConfPath() string {
+	return filepath.Join(s.opts.ServerDir, "server.conf")
+}
+
+func (s *OpenVPNServerConfigService) clientCommonPath() string {
+	return filepath.Join(s.opts.ServerDir, "client-common.txt")
+}
+
+// Get reads the current settings from the installed files
+func (s *OpenVPNServerConfigService) Get() (*openvpn.ServerConfig, error) {
+	serverConf, clientCommon, err := s.readFiles()
+	if err != nil {
+		return nil, err
+	}
+	return openvpn.ParseServerConfig(serverConf, clientCommon)
+}
+
+// Diff shows what applying config would change, without writing anything
+func (s *OpenVPNServerConfigService) Diff(config *openvpn.ServerConfig) (*models.OpenVPNServerConfigChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, _, _, err := s.plan(config)
+	return change, err
+}
+
+// Apply writes both files and, when restart is set and something changed,
+// restarts OpenVPN. goserver does not manage the host firewall that
+// openvpn.sh set up, so changes listed in FirewallChanges are refused unless
+// firewallUpdated confirms the operator took care of them.
+func (s *OpenVPNServerConfigService) Apply(ctx context.Context, config *openvpn.ServerConfig, restart, firewallUpdated bool) (*models.OpenVPNServerConfigChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, serverConf, clientCommon, err := s.plan(config)
+	if err != nil || !change.Changed {
+		return change, err
+	}
+	if len(change.FirewallChanges) > 0 && !firewallUpdated {
+		return change, fmt.Errorf("%w: %s", ErrFirewallChange, strings.Join(change.FirewallChanges, "; "))
+	}
+
+	for _, file := range []struct {
+		path string
+		data []byte
+	}{
+		{s.clientCommonPath(), clientCommon},
+		{s.serverConfPath(), serverConf},
+	} {
+		tmp, err := fsutil.WriteTempFile(s.opts.ServerDir, filepath.Base(file.path), file.data, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", filepath.Base(file.path), err)
+		}
+		if err := os.Rename(tmp, file.path); err != nil {
+			os.Remove(tmp)
+			return nil, fmt.Errorf("failed to install %s: %w", filepath.Base(file.path), err)
+		}
+	}
+	change.Applied = true
+	s.logger.Info("OpenVPN server config written")
+
+	if !restart || s.opts.Restarter == nil {
+		return change, nil
+	}
+
+	if err := s.opts.Restarter.Restart(ctx); err != nil {
+		return change, fmt.Errorf("%w: %w", ErrRestartFailed, err)
+	}
+	change.Restarted = true
+	s.logger.Info("OpenVPN restarted to apply the server config")
+
+	return change, nil
+}
+
+// plan validates config and renders the files. Caller must hold s.mu.
+func (s *OpenVPNServerConfigService) plan(config *openvpn.ServerConfig) (*models.OpenVPNServerConfigChange, []byte, []byte, error) {
+	currentServerConf, currentClientCommon, err := s.readFiles()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	current, err := openvpn.ParseServerConfig(currentServerConf, currentClientCommon)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse the installed server config: %w", err)
+	}
+
+	serverConf, err := openvpn.RenderServerConfig(config, currentServerConf)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %w", ErrInvalidServerConfig, err)
+	}
+	clientCommon, err := openvpn.RenderClientCommon(config)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %w", ErrInvalidServerConfig, err)
+	}
+
+	if err := s.checkStaticAddresses(config); err != nil {
+		return nil, nil, nil, err
+	}
+
+	change := &models.OpenVPNServerConfigChange{
+		ServerConfDiff:   unifiedDiff("server.conf", currentServerConf, serverConf),
+		ClientCommonDiff: unifiedDiff("client-common.txt", currentClientCommon, clientCommon),
+	}
+	change.Changed = change.ServerConfDiff != "" || change.ClientCommonDiff != ""
+	if change.Changed {
+		change.FirewallChanges = firewallChanges(current, config)
+	}
+
+	return change, serverConf, clientCommon, nil
+}
+
+// firewallChanges lists what the rules of openvpn.sh, in
+// openvpn-iptables.service or firewalld, need for config to work
+func firewallChanges(current, config *openvpn.ServerConfig) []string {
+	var changes []string
+
+	if current.Port != config.Port || current.Protocol != config.Protocol {
+		changes = append(changes, fmt.Sprintf("accept %d/%s instead of %d/%s", config.Port, config.Protocol, current.Port, current.Protocol))
+	}
+	if current.Subnet != config.Subnet {
+		changes = append(changes, fmt.Sprintf("forward and masquerade %s instead of %s", config.Subnet, current.Subnet))
+	}
+	if config.IPv6 && !current.IPv6 {
+		changes = append(changes, fmt.Sprintf("forward and masquerade %s", openvpnIPv6Subnet))
+	}
+	if current.IPv6 && !config.IPv6 {
+		changes = append(changes, fmt.Sprintf("stop forwarding %s", openvpnIPv6Subnet))
+	}
+
+	return changes
+}
+
+// checkStaticAddresses rejects subnets that would leave ifconfig-push entries behind
+func (s *OpenVPNServerConfigService) checkStaticAddresses(config *openvpn.ServerConfig) error {
+	if s.opts.CCD == nil {
+		return nil
+	}
+
+	subnet, err := config.SubnetPrefix()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidServerConfig, err)
+	}
+
+	entries, _, err := s.opts.CCD.readAll()
+	if err != nil {
+		return err
+	}
+	for name, ccd := range entries {
+		static := ccd.IfconfigPush
+		if static.IsValid() && (!subnet.Contains(static.Addr()) || static.Bits() != subnet.Bits()) {
+			return fmt.Errorf("%w: client config %s pushes %s, update it before moving to subnet %s", ErrInvalidServerConfig, name, static, subnet)
+		}
+	}
+	return nil
+}
+
+func (s *OpenVPNServerConfigService) readFiles() ([]byte, []byte, error) {
+	serverConf, err := os.ReadFile(s.serverConfPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read server.conf: %w", err)
+	}
+	clientCommon, err := os.ReadFile(s.clientCommonPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read client-common.txt: %w", err)
+	}
+	return serverConf, clientCommon, nil
+}
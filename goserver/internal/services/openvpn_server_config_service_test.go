@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+)
+
+type fakeRestarter struct {
+	restarts int
+	err      error
+}
+
+func (f *fakeRestarter) Restart(ctx context.Context) error {
+	f.restarts++
+	return f.err
+}
+
+func TestOpenVPNServerConfigApply(t *testing.T) {
+	serverDir := t.TempDir()
+	installed := &openvpn.ServerConfig{
+		PublicAddress: "203.0.113.10",
+		Port:          1194,
+		Protocol:      "udp",
+		Subnet:        "10.8.0.0/24",
+		DNS:           []string{"8.8.8.8", "8.8.4.4"},
+		Cipher:        "AES-128-GCM",
+		Group:         "nogroup",
+	}
+	serverConf, _ := openvpn.RenderServerConfig(installed, nil)
+	clientCommon, _ := openvpn.RenderClientCommon(installed)
+	os.WriteFile(filepath.Join(serverDir, "server.conf"), serverConf, 0644)
+	os.WriteFile(filepath.Join(serverDir, "client-common.txt"), clientCommon, 0644)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ccd, err := NewOpenVPNCCDService(OpenVPNCCDOptions{ServerDir: serverDir, Subnet: netip.MustParsePrefix("10.8.0.0/24")}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarter := &fakeRestarter{}
+	service := NewOpenVPNServerConfigService(OpenVPNServerConfigOptions{
+		ServerDir: serverDir,
+		Restarter: restarter,
+		CCD:       ccd,
+	}, logger)
+	ctx := context.Background()
+
+	config, err := service.Get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	config.Port = 443
+	config.Protocol = "tcp"
+
+	change, err := service.Diff(config)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if !change.Changed || change.Applied || !strings.Contains(change.ServerConfDiff, "-port 1194\n-proto udp\n+port 443\n+proto tcp\n") ||
+		!strings.Contains(change.ClientCommonDiff, "+remote 203.0.113.10 443\n") {
+		t.Errorf("unexpected diff: %+v", change)
+	}
+	if len(change.FirewallChanges) != 1 || change.FirewallChanges[0] != "accept 443/tcp instead of 1194/udp" {
+		t.Errorf("unexpected firewall changes: %v", change.FirewallChanges)
+	}
+
+	// The firewall must be updated first
+	if change, err := service.Apply(ctx, config, true, false); !errors.Is(err, ErrFirewallChange) || change.Applied || restarter.restarts != 0 {
+		t.Fatalf("expected the port change to be refused, got %+v %v", change, err)
+	}
+
+	change, err = service.Apply(ctx, config, true, true)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if !change.Applied || !change.Restarted || restarter.restarts != 1 {
+		t.Errorf("unexpected change: %+v, %d restarts", change, restarter.restarts)
+	}
+
+	applied, err := service.Get()
+	if err != nil || applied.Port != 443 || applied.Protocol != "tcp" || applied.PublicAddress != "203.0.113.10" {
+		t.Fatalf("unexpected config after apply: %+v %v", applied, err)
+	}
+
+	// Applying the same config again is a no-op
+	change, err = service.Apply(ctx, config, true, false)
+	if err != nil || change.Changed || restarter.restarts != 1 {
+		t.Errorf("expected no change, got %+v %v", change, err)
+	}
+
+	config.Cipher = "BF-CBC"
+	if _, err := service.Diff(config); !errors.Is(err, ErrInvalidServerConfig) {
+		t.Errorf("expected invalid cipher to be rejected, got %v", err)
+	}
+	config.Cipher = "AES-128-GCM"
+
+	// Static client addresses pin the subnet
+	if _, err := ccd.Create(ctx, models.OpenVPNClientConfig{CommonName: "alice", IfconfigPush: "10.8.0.50"}); err != nil {
+		t.Fatalf("create ccd: %v", err)
+	}
+	config.Subnet = "10.9.0.0/24"
+	if _, err := service.Apply(ctx, config, true, true); !errors.Is(err, ErrInvalidServerConfig) {
+		t.Errorf("expected subnet change to be rejected, got %v", err)
+	}
+
+	ccd.Delete("alice")
+	restarter.err = errors.New("boom")
+	change, err = service.Apply(ctx, config, true, true)
+	if !errors.Is(err, ErrRestartFailed) || change == nil || !change.Applied {
+		t.Errorf("expected written config with restart failure, got %+v %v", change, err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n")
+	new := []byte("a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n")
+
+	want := "--- a/f\n+++ b/f\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -9,3 +9,4 @@\n i\n j\n k\n+l\n"
+	if got := unifiedDiff("f", old, new); got != want {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+
+	if got := unifiedDiff("f", old, old); got != "" {
+		t.Errorf("expected no diff for equal input, got:\n%s", got)
+	}
+	if got := unifiedDiff("f", nil, []byte("x\n")); got != "--- a/f\n+++ b/f\n@@ -0,0 +1,1 @@\n+x\n" {
+		t.Errorf("unexpected diff against empty input:\n%s", got)
+	}
+}
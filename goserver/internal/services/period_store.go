@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/LevanPro/server/internal/fsutil"
+	"github.com/LevanPro/server/internal/models"
+)
+
+const periodsDir = "periods"
+
+// ErrPeriodNotFound is returned when an archived period does not exist
+var ErrPeriodNotFound = errors.New("billing period not found")
+
+var periodIDPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z_[0-9]{8}T[0-9]{6}Z(-[0-9]+)?$`)
+
+// periodStore keeps one JSON file per closed billing period
+type periodStore struct {
+	dir string
+}
+
+func newPeriodStore(storagePath string) (*periodStore, error) {
+	dir := filepath.Join(storagePath, periodsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create periods directory: %w", err)
+	}
+	return &periodStore{dir: dir}, nil
+}
+
+// periodID derives a stable, path-safe identifier from the period bounds
+func periodID(period *models.BandwidthPeriod) string {
+	const layout = "20060102T150405Z"
+	return period.Start.UTC().Format(layout) + "_" + period.End.UTC().Format(layout)
+}
+
+// save archives a period under a new file. Periods with the same bounds, such
+// as two resets within a second, get a numeric suffix instead of replacing
+// the earlier one; period.ID is updated to the name used.
+func (ps *periodStore) save(period *models.BandwidthPeriod) error {
+	base := periodID(period)
+	period.ID = base
+	for n := 2; ; n++ {
+		_, err := os.Stat(filepath.Join(ps.dir, period.ID+".json"))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check period %s: %w", period.ID, err)
+		}
+		period.ID = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	data, err := json.MarshalIndent(period, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode period: %w", err)
+	}
+	data = append(data, '\n')
+
+	return fsutil.WriteFileAtomic(filepath.Join(ps.dir, period.ID+".json"), data, 0644)
+}
+
+func (ps *periodStore) get(id string) (*models.BandwidthPeriod, error) {
+	if !periodIDPattern.MatchString(id) {
+		return nil, ErrPeriodNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(ps.dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrPeriodNotFound
+		}
+		return nil, fmt.Errorf("failed to read period %s: %w", id, err)
+	}
+
+	var period models.BandwidthPeriod
+	if err := json.Unmarshal(data, &period); err != nil {
+		return nil, fmt.Errorf("failed to decode period %s: %w", id, err)
+	}
+
+	return &period, nil
+}
+
+// list returns summaries of all archived periods, newest first
+func (ps *periodStore) list() ([]models.BandwidthPeriodSummary, error) {
+	entries, err := os.ReadDir(ps.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list periods: %w", err)
+	}
+
+	summaries := make([]models.BandwidthPeriodSummary, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || !periodIDPattern.MatchString(id) {
+			continue
+		}
+
+		period, err := ps.get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		summaries = append(summaries, models.BandwidthPeriodSummary{
+			ID:              period.ID,
+			Start:           period.Start,
+			End:             period.End,
+			Reason:          period.Reason,
+			CombinedTotalMB: period.CombinedTotalMB,
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Start.After(summaries[j].Start)
+	})
+
+	return summaries, nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+func TestPeriodStoreSameBounds(t *testing.T) {
+	ps, err := newPeriodStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	first := &models.BandwidthPeriod{Start: start, End: end, Reason: "manual", CombinedTotalMB: 1}
+	second := &models.BandwidthPeriod{Start: start, End: end, Reason: "manual", CombinedTotalMB: 2}
+	for _, period := range []*models.BandwidthPeriod{first, second} {
+		if err := ps.save(period); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if first.ID == second.ID {
+		t.Fatalf("both periods saved as %s", first.ID)
+	}
+	for _, period := range []*models.BandwidthPeriod{first, second} {
+		stored, err := ps.get(period.ID)
+		if err != nil || stored.CombinedTotalMB != period.CombinedTotalMB {
+			t.Errorf("period %s: got %+v, %v", period.ID, stored, err)
+		}
+	}
+	if summaries, _ := ps.list(); len(summaries) != 2 {
+		t.Errorf("expected two archived periods, got %+v", summaries)
+	}
+}
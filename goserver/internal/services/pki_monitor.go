@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/pki"
+)
+
+// PKIMonitorOptions configures the certificate and CRL expiry checks
+type PKIMonitorOptions struct {
+	ServerDir    string // ca.crt, server.crt and crl.pem as used by the server
+	Interval     time.Duration
+	WarningDays  int
+	CriticalDays int
+	// RegenerateCRL reissues a CRL that reached the warning threshold
+	RegenerateCRL bool
+	Clients       *OpenVPNClientService
+}
+
+// PKIMonitor periodically checks how long the CA, server and client
+// certificates and the CRL remain valid. OpenVPN rejects every client once
+// the CA, the server certificate or crl.pem expires.
+type PKIMonitor struct {
+	opts   PKIMonitorOptions
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	report *models.PKIExpiryReport
+	levels map[string]string // last level per artefact, to warn on changes only
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewPKIMonitor(opts PKIMonitorOptions, logger *slog.Logger) (*PKIMonitor, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = 12 * time.Hour
+	}
+	if opts.CriticalDays < 0 || opts.WarningDays < opts.CriticalDays {
+		return nil, fmt.Errorf("invalid expiry thresholds: warning %d days, critical %d days", opts.WarningDays, opts.CriticalDays)
+	}
+	if opts.Clients == nil {
+		return nil, fmt.Errorf("client service is required")
+	}
+
+	return &PKIMonitor{
+		opts:   opts,
+		logger: logger,
+		levels: make(map[string]string),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start runs a check now and then once per interval
+func (m *PKIMonitor) Start() {
+	m.wg.Add(1)
+	go m.loop()
+	m.logger.Info("PKI expiry monitor started", "interval", m.opts.Interval)
+}
+
+func (m *PKIMonitor) loop() {
+	defer m.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-m.done
+		cancel()
+	}()
+
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Report returns the result of the last check, or nil before the first one
+func (m *PKIMonitor) Report() *models.PKIExpiryReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.report
+}
+
+// Check reads the PKI, regenerates the CRL when enabled and due, and logs
+// artefacts whose level changed
+func (m *PKIMonitor) Check(ctx context.Context) *models.PKIExpiryReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	report := &models.PKIExpiryReport{
+		CheckedAt:    now,
+		WarningDays:  m.opts.WarningDays,
+		CriticalDays: m.opts.CriticalDays,
+		Items:        make([]models.PKIExpiry, 0),
+	}
+
+	for _, file := range []struct{ kind, name string }{
+		{models.PKIKindCA, "ca.crt"},
+		{models.PKIKindServer, "server.crt"},
+	} {
+		cert, err := pki.ReadCertificate(filepath.Join(m.opts.ServerDir, file.name))
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", file.name, err))
+			continue
+		}
+		report.Items = append(report.Items, m.certificateExpiry(file.kind, cert, now))
+	}
+
+	crlPath := filepath.Join(m.opts.ServerDir, "crl.pem")
+	crl, crlErr := pki.ReadCRL(crlPath)
+	if m.opts.RegenerateCRL && (crlErr != nil || m.level(crl.NextUpdate, now) != models.ExpiryOK) {
+		if err := m.opts.Clients.RegenerateCRL(ctx); err != nil {
+			m.logger.Error("Failed to regenerate CRL", "error", err.Error())
+		} else {
+			report.CRLRegenerated = true
+			m.logger.Info("CRL regenerated before expiry")
+			crl, crlErr = pki.ReadCRL(crlPath)
+		}
+	}
+	if crlErr != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("crl.pem: %v", crlErr))
+	} else {
+		report.Items = append(report.Items, models.PKIExpiry{
+			Kind:      models.PKIKindCRL,
+			Name:      "crl.pem",
+			ExpiresAt: crl.NextUpdate.UTC(),
+			DaysLeft:  daysLeft(crl.NextUpdate, now),
+			Level:     m.level(crl.NextUpdate, now),
+		})
+	}
+
+	// Revoked clients no longer matter; expired ones are reported until revoked or reissued
+	clients, err := m.opts.Clients.List()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	for _, client := range clients {
+		if client.Status == models.CertificateRevoked {
+			continue
+		}
+		report.Items = append(report.Items, models.PKIExpiry{
+			Kind:      models.PKIKindClient,
+			Name:      client.Name,
+			Serial:    client.Serial,
+			ExpiresAt: client.ExpiresAt,
+			DaysLeft:  daysLeft(client.ExpiresAt, now),
+			Level:     m.level(client.ExpiresAt, now),
+		})
+	}
+
+	report.Level = models.ExpiryOK
+	for _, item := range report.Items {
+		if expiryRank(item.Level) > expiryRank(report.Level) {
+			report.Level = item.Level
+		}
+	}
+	for _, msg := range report.Errors {
+		m.logger.Warn("PKI expiry check incomplete", "error", msg)
+	}
+
+	m.logChanges(report.Items)
+	m.report = report
+	return report
+}
+
+func (m *PKIMonitor) certificateExpiry(kind string, cert *x509.Certificate, now time.Time) models.PKIExpiry {
+	return models.PKIExpiry{
+		Kind:      kind,
+		Name:      cert.Subject.CommonName,
+		Serial:    pki.FormatSerial(cert.SerialNumber),
+		ExpiresAt: cert.NotAfter.UTC(),
+		DaysLeft:  daysLeft(cert.NotAfter, now),
+		Level:     m.level(cert.NotAfter, now),
+	}
+}
+
+// level maps the time left to the configured thresholds
+func (m *PKIMonitor) level(expiresAt, now time.Time) string {
+	left := expiresAt.Sub(now)
+	day := 24 * time.Hour
+
+	switch {
+	case left <= 0:
+		return models.ExpiryExpired
+	case left < time.Duration(m.opts.CriticalDays)*day:
+		return models.ExpiryCritical
+	case left < time.Duration(m.opts.WarningDays)*day:
+		return models.ExpiryWarning
+	}
+	return models.ExpiryOK
+}
+
+// logChanges warns about artefacts that reached a new level. Caller must hold m.mu.
+func (m *PKIMonitor) logChanges(items []models.PKIExpiry) {
+	levels := make(map[string]string, len(items))
+
+	for _, item := range items {
+		key := item.Kind + "/" + item.Name
+		levels[key] = item.Level
+
+		previous, seen := m.levels[key]
+		if item.Level == previous || (!seen && item.Level == models.ExpiryOK) {
+			continue
+		}
+
+		attrs := []any{"kind", item.Kind, "name", item.Name, "expires_at", item.ExpiresAt, "days_left", item.DaysLeft}
+		switch item.Level {
+		case models.ExpiryOK:
+			m.logger.Info("PKI artefact renewed", attrs...)
+		case models.ExpiryExpired:
+			m.logger.Error("PKI artefact expired", attrs...)
+		default:
+			m.logger.Warn("PKI artefact expires soon", append(attrs, "level", item.Level)...)
+		}
+	}
+
+	m.levels = levels
+}
+
+func (m *PKIMonitor) Close() error {
+	close(m.done)
+	m.wg.Wait()
+	return nil
+}
+
+// daysLeft counts whole days until expiresAt, negative once it passed
+func daysLeft(expiresAt, now time.Time) int {
+	return int(math.Floor(expiresAt.Sub(now).Hours() / 24))
+}
+
+func expiryRank(level string) int {
+	switch level {
+	case models.ExpiryWarning:
+		return 1
+	case models.ExpiryCritical:
+		return 2
+	case models.ExpiryExpired:
+		return 3
+	}
+	return 0
+}
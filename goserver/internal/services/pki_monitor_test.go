@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/pki"
+)
+
+func TestPKIMonitorCheck(t *testing.T) {
+	serverDir := t.TempDir()
+	pkiDir := filepath.Join(serverDir, "easy-rsa", "pki")
+
+	authority, err := pki.Create(pkiDir, "Test CA", 3650)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	if _, err := authority.IssueClient("server", 20); err != nil {
+		t.Fatalf("issue server: %v", err)
+	}
+	if _, err := authority.IssueClient("alice", 3); err != nil {
+		t.Fatalf("issue client: %v", err)
+	}
+	if _, err := authority.GenerateCRL(5); err != nil {
+		t.Fatalf("crl: %v", err)
+	}
+
+	// openvpn.sh copies these into the server directory
+	for src, dst := range map[string]string{"ca.crt": "ca.crt", "issued/server.crt": "server.crt", "crl.pem": "crl.pem"} {
+		data, err := os.ReadFile(filepath.Join(pkiDir, src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(serverDir, dst), data, 0644)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	clients := NewOpenVPNClientService(OpenVPNClientOptions{
+		ServerDir: serverDir,
+		PKI:       NewNativePKI(pkiDir),
+	}, logger)
+
+	newMonitor := func(regenerate bool) *PKIMonitor {
+		monitor, err := NewPKIMonitor(PKIMonitorOptions{
+			ServerDir:     serverDir,
+			WarningDays:   30,
+			CriticalDays:  7,
+			RegenerateCRL: regenerate,
+			Clients:       clients,
+		}, logger)
+		if err != nil {
+			t.Fatalf("monitor: %v", err)
+		}
+		return monitor
+	}
+
+	report := newMonitor(false).Check(context.Background())
+	if len(report.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", report.Errors)
+	}
+
+	levels := make(map[string]string)
+	for _, item := range report.Items {
+		levels[item.Kind+"/"+item.Name] = item.Level
+	}
+	want := map[string]string{
+		"ca/Test CA":    models.ExpiryOK,
+		"server/server": models.ExpiryWarning,
+		"crl/crl.pem":   models.ExpiryCritical,
+		"client/alice":  models.ExpiryCritical,
+	}
+	for key, level := range want {
+		if levels[key] != level {
+			t.Errorf("%s: expected %s, got %q", key, level, levels[key])
+		}
+	}
+	if len(levels) != len(want) || report.Level != models.ExpiryCritical || report.CRLRegenerated {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	report = newMonitor(true).Check(context.Background())
+	if !report.CRLRegenerated {
+		t.Fatal("expected the CRL to be regenerated")
+	}
+	for _, item := range report.Items {
+		if item.Kind == models.PKIKindCRL && (item.Level != models.ExpiryOK || item.DaysLeft < 3000) {
+			t.Errorf("unexpected CRL after regeneration: %+v", item)
+		}
+	}
+
+	if _, err := NewPKIMonitor(PKIMonitorOptions{WarningDays: 7, CriticalDays: 30, Clients: clients}, logger); err == nil {
+		t.Error("expected inverted thresholds to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/LevanPro/server/internal/pki"
+)
+
+// NativePKI issues and revokes client certificates in the easy-rsa pki/
+// directory with crypto/x509, so neither easy-rsa nor openssl is needed.
+// The CA is loaded per operation so a CA rebuilt by openvpn.sh is picked up;
+// changes hold an exclusive flock on pki/ so that they cannot interleave with
+// those of another process or NativePKI.
+type NativePKI struct {
+	dir string // the pki/ directory
+}
+
+func NewNativePKI(dir string) *NativePKI {
+	return &NativePKI{dir: dir}
+}
+
+func (p *NativePKI) IssueClient(ctx context.Context, name string, days int) error {
+	return p.withAuthority(func(authority *pki.Authority) error {
+		_, err := authority.IssueClient(name, days)
+		if errors.Is(err, pki.ErrExists) {
+			return ErrClientExists
+		}
+		return err
+	})
+}
+
+func (p *NativePKI) RevokeClient(ctx context.Context, name string) error {
+	return p.withAuthority(func(authority *pki.Authority) error {
+		err := authority.Revoke(name, "")
+		if errors.Is(err, pki.ErrNotFound) {
+			return ErrClientNotFound
+		}
+		return err
+	})
+}
+
+func (p *NativePKI) GenerateCRL(ctx context.Context, days int) error {
+	return p.withAuthority(func(authority *pki.Authority) error {
+		_, err := authority.GenerateCRL(days)
+		return err
+	})
+}
+
+// withAuthority loads the CA and runs fn while holding the pki/ lock
+func (p *NativePKI) withAuthority(fn func(*pki.Authority) error) error {
+	dir, err := os.Open(p.dir)
+	if err != nil {
+		return fmt.Errorf("failed to open PKI directory: %w", err)
+	}
+	defer dir.Close()
+
+	if err := syscall.Flock(int(dir.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock PKI directory: %w", err)
+	}
+	defer syscall.Flock(int(dir.Fd()), syscall.LOCK_UN)
+
+	authority, err := pki.Load(p.dir)
+	if err != nil {
+		return err
+	}
+	return fn(authority)
+}
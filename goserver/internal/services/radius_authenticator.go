@@ -0,0 +1,196 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2869"
+)
+
+// pendingRequestTimeout is how long the authenticator of an Access-Request is
+// kept for its reply
+const pendingRequestTimeout = 30 * time.Second
+
+type pendingRequest struct {
+	authenticator [16]byte
+	receivedAt    time.Time
+}
+
+// messageAuthenticatorConn enforces Message-Authenticator (RFC 3579) on the
+// auth listener, below the RADIUS library, which neither checks nor places
+// it. Access-Requests with an invalid one, or without one when required, are
+// dropped, and every reply gets one as its first attribute. These are the
+// Blast-RADIUS (CVE-2024-3596) mitigations.
+type messageAuthenticatorConn struct {
+	net.PacketConn
+	secret  []byte
+	require bool
+	logger  *slog.Logger
+
+	mu       sync.Mutex
+	requests map[string]pendingRequest // key: remote address and identifier
+}
+
+func newMessageAuthenticatorConn(conn net.PacketConn, secret []byte, require bool, logger *slog.Logger) *messageAuthenticatorConn {
+	return &messageAuthenticatorConn{
+		PacketConn: conn,
+		secret:     secret,
+		require:    require,
+		logger:     logger,
+		requests:   make(map[string]pendingRequest),
+	}
+}
+
+func (c *messageAuthenticatorConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil || n < 20 || radius.Code(b[0]) != radius.CodeAccessRequest {
+			return n, addr, err
+		}
+
+		present, valid := checkMessageAuthenticator(b[:n], nil, c.secret)
+		if !valid || (!present && c.require) {
+			c.logger.Warn("RADIUS Access-Request dropped", "nas", addr.String(), "message_authenticator", present)
+			continue
+		}
+
+		c.mu.Lock()
+		c.prune(time.Now())
+		var authenticator [16]byte
+		copy(authenticator[:], b[4:20])
+		c.requests[pendingKey(addr, b[1])] = pendingRequest{authenticator: authenticator, receivedAt: time.Now()}
+		c.mu.Unlock()
+
+		return n, addr, nil
+	}
+}
+
+func (c *messageAuthenticatorConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if len(b) < 20 {
+		return c.PacketConn.WriteTo(b, addr)
+	}
+	switch radius.Code(b[0]) {
+	case radius.CodeAccessAccept, radius.CodeAccessReject, radius.CodeAccessChallenge:
+	default:
+		return c.PacketConn.WriteTo(b, addr)
+	}
+
+	c.mu.Lock()
+	request, ok := c.requests[pendingKey(addr, b[1])]
+	delete(c.requests, pendingKey(addr, b[1]))
+	c.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("no pending Access-Request %d from %s", b[1], addr)
+	}
+
+	reply, err := signAuthReply(b, request.authenticator, c.secret)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.PacketConn.WriteTo(reply, addr); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// prune forgets requests that were never answered. Caller must hold c.mu.
+func (c *messageAuthenticatorConn) prune(now time.Time) {
+	for key, request := range c.requests {
+		if now.Sub(request.receivedAt) > pendingRequestTimeout {
+			delete(c.requests, key)
+		}
+	}
+}
+
+func pendingKey(addr net.Addr, identifier byte) string {
+	return fmt.Sprintf("%s/%d", addr, identifier)
+}
+
+// checkMessageAuthenticator reports whether an encoded packet carries a
+// Message-Authenticator and whether the packet is valid in that respect: no
+// more than one, of the right size, matching the HMAC-MD5 of the packet. The
+// HMAC of a reply covers the request authenticator, passed as authenticator;
+// requests use their own.
+func checkMessageAuthenticator(packet, authenticator, secret []byte) (bool, bool) {
+	length := int(binary.BigEndian.Uint16(packet[2:4]))
+	if length < 20 || length > len(packet) {
+		return false, false
+	}
+	data := append([]byte(nil), packet[:length]...)
+	if authenticator != nil {
+		copy(data[4:20], authenticator)
+	}
+
+	offset := -1
+	for i := 20; i < length; {
+		if i+2 > length || data[i+1] < 2 || i+int(data[i+1]) > length {
+			return false, false
+		}
+		if radius.Type(data[i]) == rfc2869.MessageAuthenticator_Type {
+			if offset >= 0 || data[i+1] != 18 {
+				return true, false
+			}
+			offset = i + 2
+		}
+		i += int(data[i+1])
+	}
+	if offset < 0 {
+		return false, true
+	}
+
+	got := append([]byte(nil), data[offset:offset+16]...)
+	clear(data[offset : offset+16])
+	mac := hmac.New(md5.New, secret)
+	mac.Write(data)
+	return true, hmac.Equal(mac.Sum(nil), got)
+}
+
+// signAuthReply re-encodes a reply with Message-Authenticator as its first
+// attribute and recomputes the response authenticator
+func signAuthReply(encoded []byte, requestAuthenticator [16]byte, secret []byte) ([]byte, error) {
+	length := int(binary.BigEndian.Uint16(encoded[2:4]))
+	if length < 20 || length > len(encoded) {
+		return nil, fmt.Errorf("invalid RADIUS reply length %d", length)
+	}
+
+	attributes := make([]byte, 0, length-20)
+	for i := 20; i < length; {
+		if i+2 > length || encoded[i+1] < 2 || i+int(encoded[i+1]) > length {
+			return nil, fmt.Errorf("invalid RADIUS reply attribute at %d", i)
+		}
+		next := i + int(encoded[i+1])
+		if radius.Type(encoded[i]) != rfc2869.MessageAuthenticator_Type {
+			attributes = append(attributes, encoded[i:next]...)
+		}
+		i = next
+	}
+
+	size := 20 + 18 + len(attributes)
+	if size > radius.MaxPacketLength {
+		return nil, fmt.Errorf("RADIUS reply of %d bytes is too long", size)
+	}
+	reply := make([]byte, size)
+	copy(reply[:2], encoded[:2])
+	binary.BigEndian.PutUint16(reply[2:4], uint16(size))
+	copy(reply[4:20], requestAuthenticator[:])
+	reply[20] = byte(rfc2869.MessageAuthenticator_Type)
+	reply[21] = 18
+	copy(reply[38:], attributes)
+
+	mac := hmac.New(md5.New, secret)
+	mac.Write(reply)
+	mac.Sum(reply[22:22])
+
+	hash := md5.New()
+	hash.Write(reply)
+	hash.Write(secret)
+	hash.Sum(reply[4:4])
+	return reply, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"log/slog"
+	"net"
+	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2869"
+)
+
+// fakePacketConn serves queued packets and records what is written
+type fakePacketConn struct {
+	net.PacketConn
+	inbound [][]byte
+	written [][]byte
+}
+
+func (c *fakePacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	if len(c.inbound) == 0 {
+		return 0, nil, net.ErrClosed
+	}
+	n := copy(b, c.inbound[0])
+	c.inbound = c.inbound[1:]
+	return n, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1645}, nil
+}
+
+func (c *fakePacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.written = append(c.written, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestMessageAuthenticatorConn(t *testing.T) {
+	secret := []byte("testing123")
+
+	request := func(identifier byte, authenticator func(p *radius.Packet)) []byte {
+		p := radius.New(radius.CodeAccessRequest, secret)
+		p.Identifier = identifier
+		rfc2865.UserName_SetString(p, "alice")
+		if authenticator != nil {
+			authenticator(p)
+		}
+		encoded, err := p.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+	// signed fills the zeroed Message-Authenticator of an encoded request
+	signed := func(encoded []byte) []byte {
+		for i := 20; i < len(encoded); i += int(encoded[i+1]) {
+			if radius.Type(encoded[i]) == rfc2869.MessageAuthenticator_Type {
+				mac := hmac.New(md5.New, secret)
+				mac.Write(encoded)
+				mac.Sum(encoded[i+2 : i+2])
+			}
+		}
+		return encoded
+	}
+	withAuthenticator := func(p *radius.Packet) {
+		rfc2869.MessageAuthenticator_Set(p, make([]byte, 16))
+	}
+
+	forged := signed(request(2, withAuthenticator))
+	forged[len(forged)-1] ^= 1
+	valid := signed(request(3, withAuthenticator))
+
+	fake := &fakePacketConn{inbound: [][]byte{request(1, nil), forged, valid}}
+	conn := newMessageAuthenticatorConn(fake, secret, true, slog.New(slog.DiscardHandler))
+
+	buf := make([]byte, radius.MaxPacketLength)
+	n, addr, err := conn.ReadFrom(buf)
+	if err != nil || buf[1] != 3 {
+		t.Fatalf("expected only the signed request to pass, got identifier %d: %v", buf[1], err)
+	}
+	if present, ok := checkMessageAuthenticator(buf[:n], nil, secret); !present || !ok {
+		t.Fatalf("passed request does not verify")
+	}
+
+	parsed, err := radius.Parse(buf[:n], secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := parsed.Response(radius.CodeAccessAccept)
+	rfc2865.SessionTimeout_Set(reply, 3600)
+	rfc2865.ReplyMessage_SetString(reply, "welcome")
+	encoded, err := reply.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteTo(encoded, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := fake.written[0]
+	if radius.Type(sent[20]) != rfc2869.MessageAuthenticator_Type || sent[21] != 18 {
+		t.Fatalf("Message-Authenticator is not the first attribute: % x", sent[20:22])
+	}
+	if !radius.IsAuthenticResponse(sent, buf[:n], secret) {
+		t.Error("reply authenticator does not verify")
+	}
+	if present, ok := checkMessageAuthenticator(sent, buf[4:20], secret); !present || !ok {
+		t.Error("reply Message-Authenticator does not verify")
+	}
+	parsedReply, err := radius.Parse(sent, secret)
+	if err != nil || rfc2865.ReplyMessage_GetString(parsedReply) != "welcome" || rfc2865.SessionTimeout_Get(parsedReply) != 3600 {
+		t.Errorf("reply attributes were not kept: %v", err)
+	}
+
+	// Unsigned requests pass when not required
+	fake.inbound = [][]byte{request(4, nil)}
+	conn.require = false
+	if _, _, err := conn.ReadFrom(buf); err != nil || buf[1] != 4 {
+		t.Errorf("unsigned request dropped although not required: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"crypto/des"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// Microsoft vendor-specific attributes, RFC 2548
+const (
+	vendorMicrosoft = 311
+
+	msCHAPError     = 2
+	msCHAPChallenge = 11
+	msCHAP2Response = 25
+	msCHAP2Success  = 26
+)
+
+// chapMD5Valid checks a CHAP-Password against the stored password. The
+// challenge is CHAP-Challenge, or the request authenticator without one.
+func chapMD5Valid(p *radius.Packet, password string) bool {
+	response := rfc2865.CHAPPassword_Get(p)
+	if len(response) != 17 {
+		return false
+	}
+	challenge := rfc2865.CHAPChallenge_Get(p)
+	if challenge == nil {
+		challenge = p.Authenticator[:]
+	}
+
+	h := md5.New()
+	h.Write(response[:1])
+	h.Write([]byte(password))
+	h.Write(challenge)
+	return subtle.ConstantTimeCompare(h.Sum(nil), response[1:]) == 1
+}
+
+// mschapv2Response is the MS-CHAP2-Response attribute of an Access-Request
+type mschapv2Response struct {
+	ident         byte
+	peerChallenge []byte
+	ntResponse    []byte
+}
+
+func parseMSCHAPv2Response(value []byte) (*mschapv2Response, error) {
+	// Ident, Flags, Peer-Challenge (16), Reserved (8), Response (24)
+	if len(value) != 50 {
+		return nil, errors.New("invalid MS-CHAP2-Response length")
+	}
+	return &mschapv2Response{
+		ident:         value[0],
+		peerChallenge: value[2:18],
+		ntResponse:    value[26:50],
+	}, nil
+}
+
+// ntPasswordHash is MD4 over the UTF-16LE password, RFC 2759 section 8.3
+func ntPasswordHash(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	encoded := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(encoded[2*i:], unit)
+	}
+
+	h := md4.New()
+	h.Write(encoded)
+	return h.Sum(nil)
+}
+
+// mschapv2ChallengeHash is RFC 2759 section 8.2. The username is the one the
+// peer sent, without any domain prefix.
+func mschapv2ChallengeHash(peerChallenge, authChallenge []byte, username string) []byte {
+	if i := strings.LastIndexByte(username, '\\'); i >= 0 {
+		username = username[i+1:]
+	}
+
+	h := sha1.New()
+	h.Write(peerChallenge)
+	h.Write(authChallenge)
+	h.Write([]byte(username))
+	return h.Sum(nil)[:8]
+}
+
+// mschapv2NTResponse is GenerateNTResponse, RFC 2759 section 8.1
+func mschapv2NTResponse(authChallenge, peerChallenge []byte, username, password string) []byte {
+	challenge := mschapv2ChallengeHash(peerChallenge, authChallenge, username)
+
+	key := make([]byte, 21)
+	copy(key, ntPasswordHash(password))
+
+	response := make([]byte, 24)
+	for i := range 3 {
+		block, _ := des.NewCipher(desKey(key[7*i : 7*i+7]))
+		block.Encrypt(response[8*i:], challenge)
+	}
+	return response
+}
+
+// mschapv2AuthenticatorResponse is GenerateAuthenticatorResponse, RFC 2759
+// section 8.7, the "S=" string that proves the server knows the password
+func mschapv2AuthenticatorResponse(password string, ntResponse, peerChallenge, authChallenge []byte, username string) string {
+	const (
+		magic1 = "Magic server to client signing constant"
+		magic2 = "Pad to make it do more than one iteration"
+	)
+
+	hashHash := md4.New()
+	hashHash.Write(ntPasswordHash(password))
+
+	h := sha1.New()
+	h.Write(hashHash.Sum(nil))
+	h.Write(ntResponse)
+	h.Write([]byte(magic1))
+	digest := h.Sum(nil)
+
+	h = sha1.New()
+	h.Write(digest)
+	h.Write(mschapv2ChallengeHash(peerChallenge, authChallenge, username))
+	h.Write([]byte(magic2))
+
+	return "S=" + strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// desKey spreads 7 key bytes over the 8 bytes DES expects; the parity bits
+// are ignored
+func desKey(key []byte) []byte {
+	return []byte{
+		key[0],
+		key[0]<<7 | key[1]>>1,
+		key[1]<<6 | key[2]>>2,
+		key[2]<<5 | key[3]>>3,
+		key[3]<<4 | key[4]>>4,
+		key[4]<<3 | key[5]>>5,
+		key[5]<<2 | key[6]>>6,
+		key[6] << 1,
+	}
+}
+
+// microsoftAttribute returns the first Microsoft vendor attribute of type typ
+func microsoftAttribute(p *radius.Packet, typ byte) []byte {
+	for _, attr := range p.Attributes[rfc2865.VendorSpecific_Type] {
+		vendorID, value, err := radius.VendorSpecific(attr)
+		if err != nil || vendorID != vendorMicrosoft {
+			continue
+		}
+		// A vendor attribute may carry several sub-attributes
+		for len(value) >= 2 {
+			length := int(value[1])
+			if length < 2 || length > len(value) {
+				break
+			}
+			if value[0] == typ {
+				return value[2:length]
+			}
+			value = value[length:]
+		}
+	}
+	return nil
+}
+
+func addMicrosoftAttribute(p *radius.Packet, typ byte, data []byte) error {
+	value := append([]byte{typ, byte(2 + len(data))}, data...)
+	attr, err := radius.NewVendorSpecific(vendorMicrosoft, value)
+	if err != nil {
+		return err
+	}
+	p.Add(rfc2865.VendorSpecific_Type, attr)
+	return nil
+}
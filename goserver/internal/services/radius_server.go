@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2866"
+	"layeh.com/radius/rfc2869"
+)
+
+// RadiusOptions configures the embedded RADIUS server
+type RadiusOptions struct {
+	AuthAddress string // UDP address for Access-Request, empty to disable
+	AcctAddress string // UDP address for Accounting-Request, empty to disable
+	Secret      []byte // shared with every NAS
+	// RequireMessageAuthenticator drops Access-Requests without one; those
+	// that carry one are always verified
+	RequireMessageAuthenticator bool
+
+	Files     *FileService
+	Access    *AccessService
+	Bandwidth *BandwidthService
+	Journal   *SessionJournal
+}
+
+// RadiusServer authenticates pppd (xl2tpd) and Libreswan XAuth users against
+// chap-secrets and records their accounting in the bandwidth store and the
+// session journal. PAP, CHAP-MD5 and MS-CHAPv2 are supported; MS-CHAPv2
+// answers carry no MPPE keys, as L2TP is encrypted by IPsec.
+type RadiusServer struct {
+	opts   RadiusOptions
+	logger *slog.Logger
+
+	auth *radius.PacketServer
+	acct *radius.PacketServer
+}
+
+func NewRadiusServer(opts RadiusOptions, logger *slog.Logger) (*RadiusServer, error) {
+	if len(opts.Secret) == 0 {
+		return nil, errors.New("radius secret is required")
+	}
+	if opts.AuthAddress == "" && opts.AcctAddress == "" {
+		return nil, errors.New("radius needs an auth or accounting address")
+	}
+
+	s := &RadiusServer{
+		opts:   opts,
+		logger: logger,
+	}
+	s.auth = &radius.PacketServer{
+		SecretSource: radius.StaticSecretSource(opts.Secret),
+		Handler:      radius.HandlerFunc(s.serveAuth),
+	}
+	s.acct = &radius.PacketServer{
+		SecretSource: radius.StaticSecretSource(opts.Secret),
+		Handler:      radius.HandlerFunc(s.serveAcct),
+	}
+	return s, nil
+}
+
+// Start opens the configured UDP listeners and serves them in the background
+func (s *RadiusServer) Start() error {
+	var opened []net.PacketConn
+	listen := func(address string, server *radius.PacketServer, name string) error {
+		if address == "" {
+			return nil
+		}
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return fmt.Errorf("failed to listen for radius %s on %s: %w", name, address, err)
+		}
+		opened = append(opened, conn)
+		if server == s.auth {
+			conn = newMessageAuthenticatorConn(conn, s.opts.Secret, s.opts.RequireMessageAuthenticator, s.logger)
+		}
+
+		go func() {
+			if err := server.Serve(conn); err != nil && !errors.Is(err, radius.ErrServerShutdown) {
+				s.logger.Error("RADIUS server stopped", "listener", name, "error", err.Error())
+			}
+		}()
+		s.logger.Info("RADIUS server listening", "listener", name, "address", address)
+		return nil
+	}
+
+	if err := listen(s.opts.AuthAddress, s.auth, "auth"); err != nil {
+		return err
+	}
+	if err := listen(s.opts.AcctAddress, s.acct, "accounting"); err != nil {
+		for _, conn := range opened {
+			conn.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// Close stops both listeners and waits briefly for requests in flight
+func (s *RadiusServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return errors.Join(s.auth.Shutdown(ctx), s.acct.Shutdown(ctx))
+}
+
+func (s *RadiusServer) serveAuth(w radius.ResponseWriter, r *radius.Request) {
+	if r.Code != radius.CodeAccessRequest {
+		return
+	}
+
+	username := rfc2865.UserName_GetString(r.Packet)
+	user, success, mschap, err := s.authenticate(r.Packet, username)
+	if err == nil {
+		err = s.opts.Access.Check(username)
+	}
+
+	if err != nil {
+		s.logger.Warn("RADIUS access rejected", "user", username, "nas", r.RemoteAddr.String(), "error", err.Error())
+
+		reply := r.Response(radius.CodeAccessReject)
+		rfc2865.ReplyMessage_SetString(reply, rejectMessage(err))
+		if mschap != nil {
+			code := 691 // authentication failure
+			if !errors.Is(err, ErrInvalidCredentials) {
+				code = 649 // no dial-in permission
+			}
+			addMicrosoftAttribute(reply, msCHAPError, append([]byte{mschap.ident}, fmt.Sprintf("E=%d R=0 V=3", code)...))
+		}
+		s.write(w, reply)
+		return
+	}
+
+	reply := r.Response(radius.CodeAccessAccept)
+	if user.FramedIP != "" {
+		if addr, err := netip.ParseAddr(user.FramedIP); err == nil {
+			rfc2865.FramedIPAddress_Set(reply, addr.AsSlice())
+		}
+	}
+	if timeout := s.sessionTimeout(user); timeout > 0 {
+		rfc2865.SessionTimeout_Set(reply, rfc2865.SessionTimeout(timeout))
+	}
+	if mschap != nil {
+		addMicrosoftAttribute(reply, msCHAP2Success, append([]byte{mschap.ident}, success...))
+	}
+
+	s.logger.Info("RADIUS access accepted", "user", username, "nas", r.RemoteAddr.String())
+	s.write(w, reply)
+}
+
+// authenticate verifies whichever credential the request carries. For
+// MS-CHAPv2 it also returns the parsed response and the authenticator
+// response the peer expects in MS-CHAP2-Success.
+func (s *RadiusServer) authenticate(p *radius.Packet, username string) (*models.User, string, *mschapv2Response, error) {
+	if username == "" {
+		return nil, "", nil, ErrInvalidCredentials
+	}
+
+	if _, ok := p.Lookup(rfc2865.UserPassword_Type); ok {
+		user, err := s.opts.Files.Authenticate(username, rfc2865.UserPassword_GetString(p))
+		return user, "", nil, err
+	}
+
+	if _, ok := p.Lookup(rfc2865.CHAPPassword_Type); ok {
+		user, err := s.opts.Files.Verify(username, func(password string) bool {
+			return chapMD5Valid(p, password)
+		})
+		return user, "", nil, err
+	}
+
+	if value := microsoftAttribute(p, msCHAP2Response); value != nil {
+		response, err := parseMSCHAPv2Response(value)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		challenge := microsoftAttribute(p, msCHAPChallenge)
+		if len(challenge) != 16 {
+			return nil, "", response, errors.New("missing MS-CHAP-Challenge")
+		}
+
+		var success string
+		user, err := s.opts.Files.Verify(username, func(password string) bool {
+			expected := mschapv2NTResponse(challenge, response.peerChallenge, username, password)
+			if subtle.ConstantTimeCompare(expected, response.ntResponse) != 1 {
+				return false
+			}
+			success = mschapv2AuthenticatorResponse(password, response.ntResponse, response.peerChallenge, challenge, username)
+			return true
+		})
+		return user, success, response, err
+	}
+
+	return nil, "", nil, errors.New("unsupported authentication method")
+}
+
+// sessionTimeout is the configured limit, shortened so that the session
+// ends when the account expires
+func (s *RadiusServer) sessionTimeout(user *models.User) int {
+	timeout := user.SessionTimeout
+	if user.ExpiresAt != nil {
+		remaining := int(user.ExpiresAt.Sub(s.opts.Files.Now()).Seconds())
+		if timeout == 0 || remaining < timeout {
+			timeout = max(remaining, 1)
+		}
+	}
+	return timeout
+}
+
+func (s *RadiusServer) serveAcct(w radius.ResponseWriter, r *radius.Request) {
+	if r.Code != radius.CodeAccountingRequest {
+		return
+	}
+
+	nas := rfc2865.NASIdentifier_GetString(r.Packet)
+	if nas == "" {
+		if ip := rfc2865.NASIPAddress_Get(r.Packet); ip != nil {
+			nas = ip.String()
+		} else if addr, ok := r.RemoteAddr.(*net.UDPAddr); ok {
+			nas = addr.IP.String()
+		}
+	}
+
+	// Without a response the NAS retries, so nothing is answered until the
+	// record is stored
+	switch status := rfc2866.AcctStatusType_Get(r.Packet); status {
+	case rfc2866.AcctStatusType_Value_AccountingOn, rfc2866.AcctStatusType_Value_AccountingOff:
+		if err := s.opts.Bandwidth.ForgetRadiusSessions(nas); err != nil {
+			s.logger.Error("Failed to reset RADIUS sessions", "nas", nas, "error", err.Error())
+			return
+		}
+		s.logger.Info("RADIUS accounting restarted", "nas", nas, "status", status.String())
+
+	case rfc2866.AcctStatusType_Value_Start, rfc2866.AcctStatusType_Value_InterimUpdate, rfc2866.AcctStatusType_Value_Stop:
+		if err := s.account(r.Packet, nas, status == rfc2866.AcctStatusType_Value_Stop); err != nil {
+			s.logger.Error("Failed to record RADIUS accounting", "nas", nas, "status", status.String(), "error", err.Error())
+			return
+		}
+	}
+
+	s.write(w, r.Response(radius.CodeAccountingResponse))
+}
+
+// account credits an accounting record and journals the session on stop
+func (s *RadiusServer) account(p *radius.Packet, nas string, stop bool) error {
+	record := RadiusAccounting{
+		NAS:           nas,
+		SessionID:     rfc2866.AcctSessionID_GetString(p),
+		User:          rfc2865.UserName_GetString(p),
+		Stop:          stop,
+		BytesSent:     uint64(rfc2869.AcctOutputGigawords_Get(p))<<32 | uint64(rfc2866.AcctOutputOctets_Get(p)),
+		BytesReceived: uint64(rfc2869.AcctInputGigawords_Get(p))<<32 | uint64(rfc2866.AcctInputOctets_Get(p)),
+		SessionTime:   time.Duration(rfc2866.AcctSessionTime_Get(p)) * time.Second,
+	}
+	if record.SessionID == "" || record.User == "" {
+		return errors.New("missing Acct-Session-Id or User-Name")
+	}
+
+	recorded, err := s.opts.Bandwidth.RecordRadiusAccounting(record)
+	if err != nil {
+		return err
+	}
+	if !stop || !recorded {
+		return nil
+	}
+
+	protocol := models.ProtocolIPSecXAuth
+	if rfc2865.FramedProtocol_Get(p) == rfc2865.FramedProtocol_Value_PPP {
+		protocol = models.ProtocolL2TP
+	}
+
+	disconnectedAt := time.Now().UTC()
+	session := models.SessionRecord{
+		Protocol:        protocol,
+		User:            record.User,
+		RemoteAddress:   rfc2865.CallingStationID_GetString(p),
+		ConnectedAt:     disconnectedAt.Add(-record.SessionTime),
+		DisconnectedAt:  disconnectedAt,
+		DurationSeconds: int64(record.SessionTime.Seconds()),
+		BytesSent:       record.BytesSent,
+		BytesReceived:   record.BytesReceived,
+		Source:          models.SessionSourceRadius,
+	}
+	if ip := rfc2865.FramedIPAddress_Get(p); ip != nil {
+		session.VirtualAddress = ip.String()
+	}
+
+	s.logger.Info("RADIUS session ended", "user", record.User, "nas", nas, "session_id", record.SessionID,
+		"bytes_sent", record.BytesSent, "bytes_received", record.BytesReceived, "duration_seconds", session.DurationSeconds)
+
+	if err := s.opts.Journal.Append(session); err != nil {
+		s.logger.Error("Failed to journal RADIUS session", "user", record.User, "error", err.Error())
+	}
+	return nil
+}
+
+func (s *RadiusServer) write(w radius.ResponseWriter, reply *radius.Packet) {
+	if err := w.Write(reply); err != nil {
+		s.logger.Error("Failed to send RADIUS reply", "error", err.Error())
+	}
+}
+
+// rejectMessage keeps internal errors out of Reply-Message
+func rejectMessage(err error) string {
+	for _, known := range []error{ErrInvalidCredentials, ErrUserDisabled, ErrUserExpired, ErrQuotaExceeded} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return "access denied"
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2866"
+)
+
+func TestMSCHAPv2(t *testing.T) {
+	// RFC 2759 section 9.2
+	authChallenge, _ := hex.DecodeString("5B5D7C7D7B3F2F3E3C2C602132262628")
+	peerChallenge, _ := hex.DecodeString("21402324255E262A28295F2B3A337C7E")
+
+	ntResponse := mschapv2NTResponse(authChallenge, peerChallenge, "User", "clientPass")
+	if got := hex.EncodeToString(ntResponse); got != "82309ecd8d708b5ea08faa3981cd83544233114a3d85d6df" {
+		t.Fatalf("unexpected NT-Response %s", got)
+	}
+
+	success := mschapv2AuthenticatorResponse("clientPass", ntResponse, peerChallenge, authChallenge, "User")
+	if success != "S=407A5589115FD0D6209F510FE9C04566932CDA56" {
+		t.Fatalf("unexpected authenticator response %s", success)
+	}
+}
+
+// radiusRecorder captures the reply a handler writes
+type radiusRecorder struct {
+	reply *radius.Packet
+}
+
+func (r *radiusRecorder) Write(packet *radius.Packet) error {
+	r.reply = packet
+	return nil
+}
+
+func newTestRadiusServer(t *testing.T) (*RadiusServer, *FileService, *BandwidthService, *SessionJournal) {
+	t.Helper()
+
+	files := newTestFileService(t)
+	bandwidth := &BandwidthService{
+		store:       newAccumulatorStore(t.TempDir(), 0),
+		accumulator: newAccumulator(time.Now().UTC().Add(-time.Hour)),
+	}
+	journal, err := NewSessionJournal(filepath.Join(t.TempDir(), "sessions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewRadiusServer(RadiusOptions{
+		AuthAddress: ":1812",
+		Secret:      []byte("testing123"),
+		Files:       files,
+		Access:      NewAccessService(files, bandwidth),
+		Bandwidth:   bandwidth,
+		Journal:     journal,
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, files, bandwidth, journal
+}
+
+func radiusRequest(code radius.Code, build func(p *radius.Packet)) *radius.Request {
+	p := radius.New(code, []byte("testing123"))
+	build(p)
+	return &radius.Request{
+		RemoteAddr: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1645},
+		Packet:     p,
+	}
+}
+
+// setUserPassword pads the password to the 16 byte blocks this radius
+// version expects its callers to provide
+func setUserPassword(p *radius.Packet, password string) {
+	padded := make([]byte, (len(password)+15)/16*16)
+	copy(padded, password)
+	rfc2865.UserPassword_Set(p, padded)
+}
+
+func TestRadiusServerAccessRequest(t *testing.T) {
+	server, files, _, _ := newTestRadiusServer(t)
+	if _, err := files.SetAccount("alice", models.UserAccount{FramedIP: "fd00::1"}); !errors.Is(err, ErrInvalidAccount) {
+		t.Fatalf("expected ErrInvalidAccount for an IPv6 framed_ip, got %v", err)
+	}
+	expiry := time.Now().Add(time.Hour)
+	if _, err := files.SetAccount("alice", models.UserAccount{FramedIP: "192.168.42.50", SessionTimeout: 86400, ExpiresAt: &expiry}); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticate := func(build func(p *radius.Packet)) *radius.Packet {
+		t.Helper()
+		var w radiusRecorder
+		server.serveAuth(&w, radiusRequest(radius.CodeAccessRequest, build))
+		if w.reply == nil {
+			t.Fatal("no reply")
+		}
+		return w.reply
+	}
+
+	// PAP, with the account attributes; the timeout is cut to the expiry
+	reply := authenticate(func(p *radius.Packet) {
+		rfc2865.UserName_SetString(p, "alice")
+		setUserPassword(p, "alicepass")
+	})
+	if reply.Code != radius.CodeAccessAccept {
+		t.Fatalf("PAP: expected accept, got %v", reply.Code)
+	}
+	if ip := rfc2865.FramedIPAddress_Get(reply); !ip.Equal(net.IPv4(192, 168, 42, 50)) {
+		t.Errorf("unexpected Framed-IP-Address %v", ip)
+	}
+	if timeout := rfc2865.SessionTimeout_Get(reply); timeout < 3590 || timeout > 3600 {
+		t.Errorf("unexpected Session-Timeout %d", timeout)
+	}
+
+	// CHAP-MD5 with the challenge in the request authenticator
+	reply = authenticate(func(p *radius.Packet) {
+		rfc2865.UserName_SetString(p, "bob")
+		sum := md5.Sum(append(append([]byte{7}, "bobpass"...), p.Authenticator[:]...))
+		rfc2865.CHAPPassword_Set(p, append([]byte{7}, sum[:]...))
+	})
+	if reply.Code != radius.CodeAccessAccept {
+		t.Fatalf("CHAP: expected accept, got %v", reply.Code)
+	}
+	if _, ok := reply.Lookup(rfc2865.SessionTimeout_Type); ok {
+		t.Error("CHAP: unexpected Session-Timeout for a user without account")
+	}
+
+	// MS-CHAPv2, answered with the authenticator response
+	authChallenge := bytes.Repeat([]byte{0x11}, 16)
+	peerChallenge := bytes.Repeat([]byte{0x22}, 16)
+	mschap := func(password string) func(p *radius.Packet) {
+		return func(p *radius.Packet) {
+			rfc2865.UserName_SetString(p, "bob")
+			addMicrosoftAttribute(p, msCHAPChallenge, authChallenge)
+			response := append([]byte{9, 0}, peerChallenge...)
+			response = append(response, make([]byte, 8)...)
+			response = append(response, mschapv2NTResponse(authChallenge, peerChallenge, "bob", password)...)
+			addMicrosoftAttribute(p, msCHAP2Response, response)
+		}
+	}
+	reply = authenticate(mschap("bobpass"))
+	if reply.Code != radius.CodeAccessAccept {
+		t.Fatalf("MS-CHAPv2: expected accept, got %v", reply.Code)
+	}
+	success := microsoftAttribute(reply, msCHAP2Success)
+	want := mschapv2AuthenticatorResponse("bobpass", mschapv2NTResponse(authChallenge, peerChallenge, "bob", "bobpass"), peerChallenge, authChallenge, "bob")
+	if len(success) == 0 || success[0] != 9 || string(success[1:]) != want {
+		t.Errorf("unexpected MS-CHAP2-Success %q", success)
+	}
+
+	reply = authenticate(mschap("wrong"))
+	if reply.Code != radius.CodeAccessReject {
+		t.Fatalf("MS-CHAPv2 with wrong password: expected reject, got %v", reply.Code)
+	}
+	if errorValue := microsoftAttribute(reply, msCHAPError); string(errorValue[1:]) != "E=691 R=0 V=3" {
+		t.Errorf("unexpected MS-CHAP-Error %q", errorValue)
+	}
+
+	// Disabled accounts are rejected with a reason
+	if _, err := files.SetAccount("bob", models.UserAccount{Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	reply = authenticate(func(p *radius.Packet) {
+		rfc2865.UserName_SetString(p, "bob")
+		setUserPassword(p, "bobpass")
+	})
+	if reply.Code != radius.CodeAccessReject || rfc2865.ReplyMessage_GetString(reply) != ErrUserDisabled.Error() {
+		t.Fatalf("disabled: expected reject, got %v %q", reply.Code, rfc2865.ReplyMessage_GetString(reply))
+	}
+}
+
+func TestRadiusServerAccounting(t *testing.T) {
+	server, _, bandwidth, journal := newTestRadiusServer(t)
+
+	account := func(status rfc2866.AcctStatusType, sent, received, seconds uint32) {
+		t.Helper()
+		var w radiusRecorder
+		server.serveAcct(&w, radiusRequest(radius.CodeAccountingRequest, func(p *radius.Packet) {
+			rfc2865.UserName_SetString(p, "alice")
+			rfc2865.NASIdentifier_SetString(p, "l2tp")
+			rfc2865.FramedProtocol_Set(p, rfc2865.FramedProtocol_Value_PPP)
+			rfc2866.AcctStatusType_Set(p, status)
+			rfc2866.AcctSessionID_SetString(p, "0A1B")
+			rfc2866.AcctOutputOctets_Set(p, rfc2866.AcctOutputOctets(sent))
+			rfc2866.AcctInputOctets_Set(p, rfc2866.AcctInputOctets(received))
+			rfc2866.AcctSessionTime_Set(p, rfc2866.AcctSessionTime(seconds))
+		}))
+		if w.reply == nil || w.reply.Code != radius.CodeAccountingResponse {
+			t.Fatalf("%v: expected an accounting response", status)
+		}
+	}
+
+	account(rfc2866.AcctStatusType_Value_Start, 0, 0, 0)
+	account(rfc2866.AcctStatusType_Value_InterimUpdate, 1000, 100, 60)
+	if alice := bandwidth.UserUsage("alice"); alice.TotalBytesSent != 1000 || alice.TotalBytesReceived != 100 || alice.SessionCount != 0 {
+		t.Fatalf("unexpected usage after interim update: %+v", alice)
+	}
+
+	// The stop adds the remainder once, even when retransmitted
+	account(rfc2866.AcctStatusType_Value_Stop, 1500, 120, 90)
+	account(rfc2866.AcctStatusType_Value_Stop, 1500, 120, 90)
+	if alice := bandwidth.UserUsage("alice"); alice.TotalBytesSent != 1500 || alice.TotalBytesReceived != 120 || alice.SessionCount != 1 {
+		t.Fatalf("unexpected usage after stop: %+v", alice)
+	}
+	if len(bandwidth.accumulator.RadiusSessions) != 0 {
+		t.Errorf("session still open: %+v", bandwidth.accumulator.RadiusSessions)
+	}
+
+	records, err := journal.List(SessionJournalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one journaled session, got %d", len(records))
+	}
+	if record := records[0]; record.Protocol != models.ProtocolL2TP || record.User != "alice" || record.BytesSent != 1500 ||
+		record.DurationSeconds != 90 || record.Source != models.SessionSourceRadius {
+		t.Errorf("unexpected journal record %+v", record)
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// rateSmoothingWindow is the EWMA time constant. A sample contributes about 63%
+// of the smoothed value after this long, independent of the collection interval.
+const rateSmoothingWindow = 5 * time.Minute
+
+// updateRate folds the bytes moved over elapsed into the previous rate.
+// A zero elapsed (first tick after startup or reset) yields no sample and
+// keeps the smoothed values unchanged.
+func updateRate(previous models.ThroughputRate, sent, received uint64, elapsed time.Duration) models.ThroughputRate {
+	if elapsed <= 0 {
+		return models.ThroughputRate{
+			SmoothedSentPerSecond:     previous.SmoothedSentPerSecond,
+			SmoothedReceivedPerSecond: previous.SmoothedReceivedPerSecond,
+		}
+	}
+
+	seconds := elapsed.Seconds()
+	alpha := 1 - math.Exp(-seconds/rateSmoothingWindow.Seconds())
+
+	rate := models.ThroughputRate{
+		SentPerSecond:     float64(sent) / seconds,
+		ReceivedPerSecond: float64(received) / seconds,
+	}
+	rate.SmoothedSentPerSecond = previous.SmoothedSentPerSecond + alpha*(rate.SentPerSecond-previous.SmoothedSentPerSecond)
+	rate.SmoothedReceivedPerSecond = previous.SmoothedReceivedPerSecond + alpha*(rate.ReceivedPerSecond-previous.SmoothedReceivedPerSecond)
+
+	return rate
+}
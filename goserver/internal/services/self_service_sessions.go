@@ -0,0 +1,216 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManyAttempts is returned while logins are locked out after failures
+	ErrTooManyAttempts = errors.New("too many failed logins, try again later")
+	// ErrNotLoggedIn is returned for unknown, expired and revoked tokens
+	ErrNotLoggedIn = errors.New("log in first")
+)
+
+// Failed logins tolerated before a lockout of loginLockout: per existing user
+// from one remote address, and per remote address across all usernames, which
+// also stops one password being tried against many users. A user is only
+// locked out from the addresses that failed, so others cannot lock them out.
+const (
+	maxLoginFailures        = 5
+	maxAddressLoginFailures = 20
+	loginLockout            = 15 * time.Minute
+	loginFailuresTracked    = 10000
+)
+
+type selfServiceSession struct {
+	username  string
+	expiresAt time.Time
+	password  [sha256.Size]byte // hash of the password at login, to notice changes
+}
+
+// SelfServiceSessions logs VPN users into the self-service API with their
+// chap-secrets credentials and keeps their bearer tokens in memory
+type SelfServiceSessions struct {
+	files *FileService
+	ttl   time.Duration
+	Now   func() time.Time
+
+	userFailures    *FailureLimiter // key: username and remote address
+	addressFailures *FailureLimiter // key: remote address
+
+	mu       sync.Mutex
+	sessions map[string]selfServiceSession
+}
+
+func NewSelfServiceSessions(files *FileService, ttl time.Duration) *SelfServiceSessions {
+	s := &SelfServiceSessions{
+		files:           files,
+		ttl:             ttl,
+		Now:             time.Now,
+		userFailures:    NewFailureLimiter(maxLoginFailures, loginLockout, loginFailuresTracked),
+		addressFailures: NewFailureLimiter(maxAddressLoginFailures, loginLockout, loginFailuresTracked),
+		sessions:        make(map[string]selfServiceSession),
+	}
+	s.userFailures.Now = func() time.Time { return s.Now() }
+	s.addressFailures.Now = func() time.Time { return s.Now() }
+	return s
+}
+
+// Login checks the credentials of a login from remoteAddress and returns a new
+// token and its expiry. Expired accounts may log in to see their state;
+// disabled ones may not.
+func (s *SelfServiceSessions) Login(username, password, remoteAddress string) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authenticate(username, password, remoteAddress); err != nil {
+		return "", time.Time{}, err
+	}
+
+	credentials, err := s.files.Credentials(username)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := s.Now()
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	for key, session := range s.sessions {
+		if !now.Before(session.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	session := selfServiceSession{username: username, expiresAt: now.Add(s.ttl).UTC(), password: sha256.Sum256([]byte(credentials.Password))}
+	s.sessions[token] = session
+	return token, session.expiresAt, nil
+}
+
+// CheckPassword checks the current password of the user of token, e.g.
+// before a password change, under the same failure limits as Login. Once the
+// user is locked out, token is revoked.
+func (s *SelfServiceSessions) CheckPassword(token, password, remoteAddress string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[token]
+	if !ok {
+		return ErrNotLoggedIn
+	}
+
+	locked, err := s.authenticate(session.username, password, remoteAddress)
+	if locked {
+		delete(s.sessions, token)
+	}
+	return err
+}
+
+// authenticate checks a password and counts failures per existing user and
+// remote address, and per remote address. It reports whether the user is
+// locked out. Caller must hold s.mu.
+func (s *SelfServiceSessions) authenticate(username, password, remoteAddress string) (bool, error) {
+	host := remoteAddress
+	if h, _, err := net.SplitHostPort(remoteAddress); err == nil {
+		host = h
+	}
+	userKey := username + "@" + host
+	if s.addressFailures.Blocked(host) || s.userFailures.Blocked(userKey) {
+		return true, ErrTooManyAttempts
+	}
+
+	_, err := s.files.Authenticate(username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		locked := s.addressFailures.Fail(host)
+		if _, lookupErr := s.files.Credentials(username); lookupErr == nil {
+			locked = s.userFailures.Fail(userKey) || locked
+		}
+		return locked, err
+	}
+	if err != nil && !errors.Is(err, ErrUserExpired) {
+		return false, err
+	}
+	s.userFailures.Reset(userKey)
+	return false, nil
+}
+
+// User returns the username a token was issued to, if it is still valid. The
+// account is checked every time: all tokens of a user who was deleted or
+// disabled, or whose password was changed elsewhere, are revoked.
+func (s *SelfServiceSessions) User(token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[token]
+	if !ok {
+		return "", ErrNotLoggedIn
+	}
+	if !s.Now().Before(session.expiresAt) {
+		delete(s.sessions, token)
+		return "", ErrNotLoggedIn
+	}
+
+	credentials, err := s.files.Credentials(session.username)
+	if errors.Is(err, ErrUserNotFound) || (err == nil && (credentials.Disabled || sha256.Sum256([]byte(credentials.Password)) != session.password)) {
+		s.logoutUser(session.username, "")
+		return "", ErrNotLoggedIn
+	}
+	if err != nil {
+		return "", err
+	}
+	return session.username, nil
+}
+
+// Logout invalidates a token
+func (s *SelfServiceSessions) Logout(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+}
+
+// LogoutUser invalidates every token of a user, e.g. when an admin disables the account
+func (s *SelfServiceSessions) LogoutUser(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logoutUser(username, "")
+}
+
+// PasswordChanged invalidates every token of a user but keep, the token of
+// the session that changed the password, which stays valid
+func (s *SelfServiceSessions) PasswordChanged(username, keep string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logoutUser(username, keep)
+
+	credentials, err := s.files.Credentials(username)
+	if err != nil {
+		delete(s.sessions, keep)
+		return err
+	}
+	if session, ok := s.sessions[keep]; ok {
+		session.password = sha256.Sum256([]byte(credentials.Password))
+		s.sessions[keep] = session
+	}
+	return nil
+}
+
+// logoutUser invalidates every token of a user but keep. Caller must hold s.mu.
+func (s *SelfServiceSessions) logoutUser(username, keep string) {
+	for token, session := range s.sessions {
+		if session.username == username && token != keep {
+			delete(s.sessions, token)
+		}
+	}
+}
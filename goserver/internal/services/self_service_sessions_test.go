@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// newTestSelfServiceFiles is newTestFileService with ipsec.d/passwd, for password changes
+func newTestSelfServiceFiles(t *testing.T) *FileService {
+	t.Helper()
+
+	files := newTestFileService(t)
+	passwd := filepath.Join(files.storagePath, "ipsec.d", "passwd")
+	os.MkdirAll(filepath.Dir(passwd), 0755)
+	if err := os.WriteFile(passwd, []byte("alice:$1$old$hash:xauth-psk\nbob:$1$bob$hash:xauth-psk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestSelfServiceSessions(t *testing.T) {
+	files := newTestSelfServiceFiles(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	files.Now = func() time.Time { return now }
+	s := NewSelfServiceSessions(files, time.Hour)
+	s.Now = func() time.Time { return now }
+
+	token, expiresAt, err := s.Login("alice", "alicepass", "192.0.2.1:5000")
+	if err != nil || !expiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("login: %v, expiring %v", err, expiresAt)
+	}
+	if user, err := s.User(token); err != nil || user != "alice" {
+		t.Fatalf("unexpected token owner %q: %v", user, err)
+	}
+
+	// Expired accounts may still log in to see their state, disabled ones may not
+	expiry := now.Add(-time.Minute)
+	files.SetAccount("bob", models.UserAccount{ExpiresAt: &expiry})
+	if _, _, err := s.Login("bob", "bobpass", "192.0.2.1:5000"); err != nil {
+		t.Errorf("expired account: %v", err)
+	}
+	files.SetAccount("bob", models.UserAccount{Disabled: true})
+	if _, _, err := s.Login("bob", "bobpass", "192.0.2.1:5000"); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("expected ErrUserDisabled, got %v", err)
+	}
+
+	second, _, _ := s.Login("alice", "alicepass", "192.0.2.1:5000")
+	if err := files.SetPassword("alice", "n3w-Passw0rd", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PasswordChanged("alice", second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.User(token); !errors.Is(err, ErrNotLoggedIn) {
+		t.Error("other session survived the password change")
+	}
+	if _, err := s.User(second); err != nil {
+		t.Errorf("kept session was logged out: %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := s.User(second); !errors.Is(err, ErrNotLoggedIn) {
+		t.Error("expired token accepted")
+	}
+}
+
+func TestSelfServiceSessionsRevoked(t *testing.T) {
+	files := newTestSelfServiceFiles(t)
+	s := NewSelfServiceSessions(files, time.Hour)
+
+	alice, _, _ := s.Login("alice", "alicepass", "192.0.2.1:5000")
+	bob, _, _ := s.Login("bob", "bobpass", "192.0.2.1:5000")
+
+	// Disabled by an admin after login
+	files.SetAccount("alice", models.UserAccount{Disabled: true})
+	if _, err := s.User(alice); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("token of a disabled user accepted: %v", err)
+	}
+	files.SetAccount("alice", models.UserAccount{})
+	if _, err := s.User(alice); !errors.Is(err, ErrNotLoggedIn) {
+		t.Error("revoked token accepted again after re-enabling")
+	}
+
+	// Password changed outside the self-service session
+	if err := files.SetPassword("bob", "n3w-Passw0rd", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.User(bob); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("token survived an admin password change: %v", err)
+	}
+}
+
+func TestSelfServiceSessionsLockout(t *testing.T) {
+	files := newTestFileService(t)
+	s := NewSelfServiceSessions(files, time.Hour)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return now }
+
+	for range maxLoginFailures {
+		if _, _, err := s.Login("alice", "wrong", "192.0.2.1:5000"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+		}
+	}
+	if _, _, err := s.Login("alice", "alicepass", "192.0.2.1:5000"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+
+	// Others are not locked out of alice's account
+	if _, _, err := s.Login("alice", "alicepass", "198.51.100.2:5000"); err != nil {
+		t.Errorf("login from another address: %v", err)
+	}
+
+	now = now.Add(loginLockout)
+	if _, _, err := s.Login("alice", "alicepass", "192.0.2.1:5000"); err != nil {
+		t.Errorf("login after lockout: %v", err)
+	}
+}
+
+func TestSelfServiceSessionsAddressLockout(t *testing.T) {
+	files := newTestFileService(t)
+	s := NewSelfServiceSessions(files, time.Hour)
+
+	// Spraying usernames, real or not, locks out the address only
+	for i := range maxAddressLoginFailures {
+		if _, _, err := s.Login(fmt.Sprintf("user%d", i), "password", "203.0.113.5:6000"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+		}
+	}
+	if _, _, err := s.Login("alice", "alicepass", "203.0.113.5:6001"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if _, _, err := s.Login("alice", "alicepass", "192.0.2.1:5000"); err != nil {
+		t.Errorf("login from another address: %v", err)
+	}
+	if len(s.userFailures.entries) != 0 {
+		t.Errorf("failures of unknown users tracked: %v", s.userFailures.entries)
+	}
+}
+
+func TestSelfServiceSessionsCheckPassword(t *testing.T) {
+	files := newTestFileService(t)
+	s := NewSelfServiceSessions(files, time.Hour)
+
+	token, _, err := s.Login("alice", "alicepass", "192.0.2.1:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckPassword(token, "alicepass", "192.0.2.1:5000"); err != nil {
+		t.Fatalf("correct password: %v", err)
+	}
+
+	// A stolen token cannot be used to guess the password
+	for range maxLoginFailures {
+		if err := s.CheckPassword(token, "guess", "203.0.113.5:6000"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+		}
+	}
+	if _, err := s.User(token); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("token kept after repeated failures: %v", err)
+	}
+	if _, _, err := s.Login("alice", "alicepass", "203.0.113.5:6000"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("expected the failures to count for logins too, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"slices"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// SessionJournalFilter selects journal records; zero fields match everything
+type SessionJournalFilter struct {
+	User     string
+	Protocol string
+	Since    time.Time // disconnected at or after
+	Limit    int
+}
+
+// SessionJournal is an append-only JSON Lines log of ended sessions with
+// their exact durations and byte counts
+type SessionJournal struct {
+	log *jsonLinesLog[models.SessionRecord]
+}
+
+func NewSessionJournal(path string) (*SessionJournal, error) {
+	log, err := newJSONLinesLog[models.SessionRecord](path, "session journal", 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionJournal{log: log}, nil
+}
+
+// Append adds a record to the end of the journal
+func (j *SessionJournal) Append(record models.SessionRecord) error {
+	return j.log.Append(record)
+}
+
+// List returns the matching records, most recently disconnected first.
+// Lines that do not parse, such as one cut short by a crash, are skipped.
+func (j *SessionJournal) List(filter SessionJournalFilter) ([]models.SessionRecord, error) {
+	records, err := j.log.List(func(record models.SessionRecord) bool {
+		return (filter.User == "" || record.User == filter.User) &&
+			(filter.Protocol == "" || record.Protocol == filter.Protocol) &&
+			!record.DisconnectedAt.Before(filter.Since)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(records, func(a, b models.SessionRecord) int {
+		return b.DisconnectedAt.Compare(a.DisconnectedAt)
+	})
+
+	return limitRecords(records, filter.Limit), nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+func TestSessionJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goserver", "sessions.jsonl")
+	journal, err := NewSessionJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if records, err := journal.List(SessionJournalFilter{}); err != nil || len(records) != 0 {
+		t.Fatalf("empty journal: %v, %v", records, err)
+	}
+
+	base := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i, record := range []models.SessionRecord{
+		{Protocol: models.ProtocolL2TP, User: "alice", DisconnectedAt: base, BytesSent: 1},
+		{Protocol: models.ProtocolOpenVPN, User: "alice", DisconnectedAt: base.Add(2 * time.Hour), BytesSent: 2},
+		{Protocol: models.ProtocolL2TP, User: "bob", DisconnectedAt: base.Add(time.Hour), BytesSent: 3},
+	} {
+		if err := journal.Append(record); err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+
+	// A line cut short by a crash is skipped
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"protocol":"l2tp","us`)
+	file.Close()
+
+	records, err := journal.List(SessionJournalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].BytesSent != 2 || records[2].BytesSent != 1 {
+		t.Fatalf("want newest first, got %+v", records)
+	}
+
+	records, _ = journal.List(SessionJournalFilter{User: "alice", Protocol: models.ProtocolL2TP})
+	if len(records) != 1 || records[0].BytesSent != 1 {
+		t.Errorf("filter by user and protocol: %+v", records)
+	}
+
+	records, _ = journal.List(SessionJournalFilter{Since: base.Add(time.Minute), Limit: 1})
+	if len(records) != 1 || records[0].BytesSent != 2 {
+		t.Errorf("filter by since with limit: %+v", records)
+	}
+}
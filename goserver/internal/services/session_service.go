@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+	"github.com/docker/docker/client"
+)
+
+// ErrSessionNotFound is returned when a session ID does not match a connected session
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session ID prefixes
+const (
+	openvpnSessionPrefix = "openvpn-"
+	ipsecSessionPrefix   = "ipsec-"
+)
+
+// OpenVPNStatusSource provides the current OpenVPN server status
+type OpenVPNStatusSource interface {
+	Status(ctx context.Context) (*openvpn.Status, error)
+}
+
+// SessionService lists and disconnects active sessions across protocols
+type SessionService struct {
+	openvpnStatus OpenVPNStatusSource
+	management    *OpenVPNManagementCollector // nil when the management interface is disabled
+	dockerClient  *client.Client
+	containerName string
+	logger        *slog.Logger
+}
+
+func NewSessionService(openvpnStatus OpenVPNStatusSource, management *OpenVPNManagementCollector, containerName string, logger *slog.Logger) (*SessionService, error) {
+	if containerName == "" {
+		containerName = ipsecContainerName
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	return &SessionService{
+		openvpnStatus: openvpnStatus,
+		management:    management,
+		dockerClient:  cli,
+		containerName: containerName,
+		logger:        logger,
+	}, nil
+}
+
+// ListActive returns every connected session, OpenVPN first, each sorted by
+// user. A protocol whose sessions cannot be read is left out and reported in
+// the returned warnings, so that one failing source does not hide the other.
+func (s *SessionService) ListActive(ctx context.Context) ([]models.ActiveSession, []string) {
+	sessions := make([]models.ActiveSession, 0)
+	warnings := make([]string, 0)
+
+	status, err := s.openvpnStatus.Status(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to read OpenVPN sessions", "error", err.Error())
+		warnings = append(warnings, fmt.Sprintf("failed to read OpenVPN sessions: %s", err))
+	} else {
+		sessions = append(sessions, openvpnSessions(status)...)
+	}
+
+	connections, err := s.ipsecConnections(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to read IPsec sessions", "error", err.Error())
+		warnings = append(warnings, fmt.Sprintf("failed to read IPsec sessions: %s", err))
+	} else {
+		sessions = append(sessions, ipsecSessions(connections)...)
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if (sessions[i].Protocol == models.ProtocolOpenVPN) != (sessions[j].Protocol == models.ProtocolOpenVPN) {
+			return sessions[i].Protocol == models.ProtocolOpenVPN
+		}
+		return sessions[i].User < sessions[j].User
+	})
+
+	return sessions, warnings
+}
+
+// Disconnect ends a session using the mechanism of its protocol: client-kill
+// on the OpenVPN management interface, or deleting the IPsec state
+func (s *SessionService) Disconnect(ctx context.Context, id string) error {
+	switch {
+	case strings.HasPrefix(id, openvpnSessionPrefix):
+		clientID, err := strconv.ParseInt(strings.TrimPrefix(id, openvpnSessionPrefix), 10, 64)
+		if err != nil || clientID < 0 {
+			return ErrSessionNotFound
+		}
+		if s.management == nil {
+			return fmt.Errorf("%w: disconnecting OpenVPN sessions needs the management interface", ErrManagementUnavailable)
+		}
+
+		err = s.management.ClientKill(ctx, clientID, "")
+		var cmdErr *openvpn.CommandError
+		if errors.As(err, &cmdErr) {
+			return ErrSessionNotFound
+		}
+		return err
+
+	case strings.HasPrefix(id, ipsecSessionPrefix):
+		serial := strings.TrimPrefix(id, ipsecSessionPrefix)
+		if _, err := strconv.ParseUint(serial, 10, 64); err != nil {
+			return ErrSessionNotFound
+		}
+
+		connections, err := s.ipsecConnections(ctx)
+		if err != nil {
+			return err
+		}
+		if !hasIPSecSerial(connections, "#"+serial) {
+			return ErrSessionNotFound
+		}
+
+		if _, err := execInContainer(ctx, s.dockerClient, s.containerName, []string{"ipsec", "whack", "--deletestate", serial}); err != nil {
+			return fmt.Errorf("failed to delete IPsec state #%s: %w", serial, err)
+		}
+		s.logger.Info("IPsec session disconnected", "serial", serial)
+		return nil
+	}
+
+	return ErrSessionNotFound
+}
+
+// ipsecConnections lists established IPsec SAs; none when the container is not running
+func (s *SessionService) ipsecConnections(ctx context.Context) ([]models.IPSecConnection, error) {
+	info, err := inspectRunning(ctx, s.dockerClient, s.containerName)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	output, err := execInContainer(ctx, s.dockerClient, s.containerName, []string{"ipsec", "trafficstatus"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ipsec trafficstatus: %w", err)
+	}
+
+	return parseIPSecTrafficStatus(output), nil
+}
+
+func hasIPSecSerial(connections []models.IPSecConnection, serial string) bool {
+	for _, conn := range connections {
+		if conn.Serial == serial {
+			return true
+		}
+	}
+	return false
+}
+
+// openvpnSessions converts the OpenVPN client list. The status file counts
+// from the server's point of view, as do the session fields.
+func openvpnSessions(status *openvpn.Status) []models.ActiveSession {
+	sessions := make([]models.ActiveSession, 0, len(status.Clients))
+
+	for _, client := range status.Clients {
+		id := openvpnSessionPrefix + strconv.FormatInt(client.ClientID, 10)
+		if client.ClientID < 0 {
+			// status-version 1 has no client IDs; such sessions cannot be disconnected
+			id = ""
+		}
+
+		session := models.ActiveSession{
+			ID:             id,
+			Protocol:       models.ProtocolOpenVPN,
+			User:           client.CommonName,
+			RemoteAddress:  client.RealAddress,
+			VirtualAddress: client.VirtualAddress,
+			BytesSent:      client.BytesSent,
+			BytesReceived:  client.BytesReceived,
+		}
+		if !client.ConnectedSince.IsZero() {
+			connectedSince := client.ConnectedSince
+			session.ConnectedSince = &connectedSince
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+// ipsecSessions converts `ipsec trafficstatus` entries. inBytes is traffic
+// received from the peer, outBytes traffic sent to it.
+func ipsecSessions(connections []models.IPSecConnection) []models.ActiveSession {
+	sessions := make([]models.ActiveSession, 0, len(connections))
+
+	for _, conn := range connections {
+		user := conn.Username
+		if user == "" {
+			user = conn.PeerID
+		}
+
+		session := models.ActiveSession{
+			ID:             ipsecSessionPrefix + strings.TrimPrefix(conn.Serial, "#"),
+			Protocol:       ipsecProtocol(conn.Connection),
+			User:           user,
+			RemoteAddress:  conn.RemoteAddress,
+			VirtualAddress: conn.VirtualIP,
+			BytesSent:      conn.BytesOut,
+			BytesReceived:  conn.BytesIn,
+			Connection:     conn.Connection,
+		}
+		if !conn.AddedAt.IsZero() {
+			addedAt := conn.AddedAt
+			session.ConnectedSince = &addedAt
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+// ipsecProtocol classifies a connection by the names used in the IPsec container
+func ipsecProtocol(connection string) string {
+	name, _, _ := strings.Cut(connection, "[")
+
+	switch {
+	case strings.HasPrefix(name, "l2tp"):
+		return models.ProtocolL2TP
+	case strings.HasPrefix(name, "xauth"):
+		return models.ProtocolIPSecXAuth
+	case strings.HasPrefix(name, "ikev2"):
+		return models.ProtocolIKEv2
+	}
+	return models.ProtocolIPSec
+}
+
+// Close releases the Docker client
+func (s *SessionService) Close() error {
+	return s.dockerClient.Close()
+}
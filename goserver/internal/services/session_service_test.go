@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+	"github.com/LevanPro/server/internal/openvpn"
+)
+
+func TestActiveSessionConversion(t *testing.T) {
+	status := &openvpn.Status{Clients: []openvpn.Client{
+		{CommonName: "alice", RealAddress: "203.0.113.10:50123", VirtualAddress: "10.8.0.2", BytesSent: 20, BytesReceived: 10, ClientID: 4, ConnectedSince: time.Unix(1715338800, 0)},
+		{CommonName: "legacy", ClientID: -1},
+	}}
+
+	sessions := openvpnSessions(status)
+	if sessions[0].ID != "openvpn-4" || sessions[0].User != "alice" || sessions[0].BytesSent != 20 || sessions[0].ConnectedSince == nil {
+		t.Errorf("unexpected OpenVPN session: %+v", sessions[0])
+	}
+	if sessions[1].ID != "" {
+		t.Errorf("sessions without a client ID must not be addressable, got %q", sessions[1].ID)
+	}
+
+	connections := parseIPSecTrafficStatus(trafficStatusSample)
+	ipsec := ipsecSessions(connections)
+	if len(ipsec) != len(connections) {
+		t.Fatalf("got %d IPsec sessions for %d connections", len(ipsec), len(connections))
+	}
+	for i, session := range ipsec {
+		conn := connections[i]
+		if session.ID != "ipsec-"+conn.Serial[1:] || session.BytesReceived != conn.BytesIn || session.BytesSent != conn.BytesOut {
+			t.Errorf("unexpected IPsec session %+v for %+v", session, conn)
+		}
+	}
+}
+
+func TestIPSecProtocol(t *testing.T) {
+	tests := map[string]string{
+		"l2tp-psk[1]":  models.ProtocolL2TP,
+		"xauth-psk[2]": models.ProtocolIPSecXAuth,
+		"ikev2-cp[3]":  models.ProtocolIKEv2,
+		"site-to-site": models.ProtocolIPSec,
+	}
+	for connection, want := range tests {
+		if got := ipsecProtocol(connection); got != want {
+			t.Errorf("ipsecProtocol(%q) = %q, want %q", connection, got, want)
+		}
+	}
+}
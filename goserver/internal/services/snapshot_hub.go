@@ -0,0 +1,94 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+// snapshotHub fans bandwidth snapshots out to stream subscribers. Each subscriber
+// owns a single-slot buffer; when it has not drained the previous snapshot yet,
+// the stale one is replaced so that a slow consumer never blocks the publisher.
+type snapshotHub struct {
+	mu     sync.Mutex
+	subs   map[chan *models.BandwidthSnapshot]struct{}
+	last   *models.BandwidthSnapshot
+	closed bool
+}
+
+func newSnapshotHub() *snapshotHub {
+	return &snapshotHub{
+		subs: make(map[chan *models.BandwidthSnapshot]struct{}),
+	}
+}
+
+func (h *snapshotHub) subscribe() (<-chan *models.BandwidthSnapshot, func()) {
+	ch := make(chan *models.BandwidthSnapshot, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	h.subs[ch] = struct{}{}
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+
+	return ch, cancel
+}
+
+func (h *snapshotHub) publish(snapshot *models.BandwidthSnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.last = snapshot
+
+	for ch := range h.subs {
+		select {
+		case ch <- snapshot:
+		default:
+			// Drop the stale snapshot and deliver the fresh one instead
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- snapshot:
+			default:
+			}
+		}
+	}
+}
+
+func (h *snapshotHub) latest() *models.BandwidthSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.last
+}
+
+func (h *snapshotHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
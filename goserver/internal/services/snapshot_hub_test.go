@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LevanPro/server/internal/models"
+)
+
+func TestSnapshotHubSlowConsumerGetsLatest(t *testing.T) {
+	hub := newSnapshotHub()
+	ch, cancel := hub.subscribe()
+	defer cancel()
+
+	first := &models.BandwidthSnapshot{Timestamp: time.Unix(1, 0)}
+	second := &models.BandwidthSnapshot{Timestamp: time.Unix(2, 0)}
+
+	// Nobody is reading: publish must not block and must keep only the newest value
+	hub.publish(first)
+	hub.publish(second)
+
+	got := <-ch
+	if got != second {
+		t.Fatalf("expected latest snapshot, got %v", got.Timestamp)
+	}
+	if hub.latest() != second {
+		t.Fatalf("latest() did not return the last published snapshot")
+	}
+}
+
+func TestSnapshotHubCloseReleasesSubscribers(t *testing.T) {
+	hub := newSnapshotHub()
+	ch, cancel := hub.subscribe()
+
+	hub.close()
+	if _, ok := <-ch; ok {
+		t.Fatalf("expected channel to be closed")
+	}
+
+	// cancel after close must be a no-op
+	cancel()
+
+	late, _ := hub.subscribe()
+	if _, ok := <-late; ok {
+		t.Fatalf("expected subscription after close to be closed immediately")
+	}
+}
@@ -1001,6 +1001,9 @@ server 10.8.0.0 255.255.255.0" > "$OVPN_CONF"
 	fi
 	echo 'push "redirect-gateway def1 ipv6 bypass-dhcp"' >> "$OVPN_CONF"
 	echo 'ifconfig-pool-persist ipp.txt' >> "$OVPN_CONF"
+	# Per-client settings managed by the goserver API
+	mkdir -p /etc/openvpn/server/ccd
+	echo 'client-config-dir ccd' >> "$OVPN_CONF"
 	create_dns_config
 	echo 'push "block-outside-dns"' >> "$OVPN_CONF"
 	echo "keepalive 10 120
@@ -1012,7 +1015,8 @@ persist-tun
 verb 3
 crl-verify crl.pem
 status /var/log/openvpn/status.log
-status-version 2" >> "$OVPN_CONF"
+status-version 2
+management /etc/openvpn/server/management.sock unix" >> "$OVPN_CONF"
 	if [[ "$protocol" = "udp" ]]; then
 		echo "explicit-exit-notify" >> "$OVPN_CONF"
 	fi
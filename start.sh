@@ -7,6 +7,8 @@ mkdir -p etc/ppp
 touch etc/ipsec.d/passwd
 touch etc/ppp/chap-secrets
 touch etc/ipsec.secrets
+touch etc/ppp/goserver-hook.conf
+mkdir -p run/ppp
 
 
 # Initialize OpenVPN if not already configured